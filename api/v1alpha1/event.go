@@ -21,6 +21,16 @@ const (
 	ReasonExecute = "Execute"
 	// ReasonGenerate is the reason for generating a workflow
 	ReasonGenerate = "Generate"
+	// ReasonSchedule is the reason for scheduling a workflow run by cron workflow
+	ReasonSchedule = "Schedule"
+	// ReasonConcurrency is the reason for the concurrency group of a workflow run
+	ReasonConcurrency = "Concurrency"
+	// ReasonRestart is the reason for restarting a workflow run
+	ReasonRestart = "Restart"
+	// ReasonControl is the reason for the controls in the spec of a workflow run
+	ReasonControl = "Control"
+	// ReasonContinue is the reason for continuing the steps paused at the breakpoints
+	ReasonContinue = "Continue"
 )
 
 const (
@@ -34,4 +44,12 @@ const (
 	MessageFailedGenerate = "fail to generate workflow runners"
 	// MessageFailedExecute is the message for failed to execute
 	MessageFailedExecute = "fail to execute"
+	// MessageFailedSchedule is the message for failed to schedule
+	MessageFailedSchedule = "fail to schedule workflow run"
+	// MessageFailedConcurrency is the message for failed to handle the concurrency group
+	MessageFailedConcurrency = "fail to handle the concurrency group"
+	// MessageFailedRestart is the message for failed to restart
+	MessageFailedRestart = "fail to restart workflow run"
+	// MessageFailedContinue is the message for failed to continue the steps at the breakpoints
+	MessageFailedContinue = "fail to continue the steps at the breakpoints"
 )
@@ -50,7 +50,14 @@ var (
 	WorkflowRunGroupVersionKind = SchemeGroupVersion.WithKind(WorkflowRunKind)
 )
 
+// CronWorkflow meta
+var (
+	CronWorkflowKind             = "CronWorkflow"
+	CronWorkflowGroupVersionKind = SchemeGroupVersion.WithKind(CronWorkflowKind)
+)
+
 func init() {
 	SchemeBuilder.Register(&Workflow{}, &WorkflowList{})
 	SchemeBuilder.Register(&WorkflowRun{}, &WorkflowRunList{})
+	SchemeBuilder.Register(&CronWorkflow{}, &CronWorkflowList{})
 }
@@ -74,6 +74,62 @@ type WorkflowRunSpec struct {
 	Mode         *WorkflowExecuteMode `json:"mode,omitempty"`
 	WorkflowSpec *WorkflowSpec        `json:"workflowSpec,omitempty"`
 	WorkflowRef  string               `json:"workflowRef,omitempty"`
+	// Timeout is the timeout of the whole workflow run, e.g. 1h
+	Timeout string `json:"timeout,omitempty"`
+	// Context is the initial variables in the context of the workflow run
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Context *runtime.RawExtension `json:"context,omitempty"`
+	// Concurrency is the concurrency group of the workflow run, the runs in the same group won't run at the same time
+	Concurrency *ConcurrencyGroup `json:"concurrency,omitempty"`
+	// DryRun renders the steps and records the calls of the kube, http and email providers in the ConfigMap
+	// <name>-dryrun instead of executing them
+	DryRun bool `json:"dryRun,omitempty"`
+	// TTLSecondsAfterFinished is the ttl of the workflow run after it finished, the finished run is deleted
+	// together with its context after the ttl
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+	// Suspend suspends the workflow run if it's true, the run suspended by it is resumed after it's set to false
+	Suspend bool `json:"suspend,omitempty"`
+	// Terminate terminates the workflow run if it's true
+	Terminate bool `json:"terminate,omitempty"`
+	// ResumeSteps resumes or rejects the suspend steps, each suspend step is resumed independently
+	ResumeSteps []StepResume `json:"resumeSteps,omitempty"`
+}
+
+// StepResume is the decision to resume a suspend step, the payload of the decision becomes the outputs of the step
+type StepResume struct {
+	// Name is the name or the id of the suspend step
+	Name string `json:"name"`
+	// Approver is the one who made the decision
+	Approver string `json:"approver,omitempty"`
+	// Comment is the comment of the decision
+	Comment string `json:"comment,omitempty"`
+	// Data is the structured data of the decision
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Data *runtime.RawExtension `json:"data,omitempty"`
+	// Reject fails the suspend step instead of resuming it
+	Reject bool `json:"reject,omitempty"`
+}
+
+// ConcurrencyGroupPolicy describes how to handle the workflow run if the concurrency group is held by another run
+// +kubebuilder:validation:Enum=Queue;CancelInProgress;Reject
+type ConcurrencyGroupPolicy string
+
+const (
+	// ConcurrencyQueue queues the workflow run until the concurrency group is released
+	ConcurrencyQueue ConcurrencyGroupPolicy = "Queue"
+	// ConcurrencyCancelInProgress terminates the workflow run which holds the concurrency group
+	ConcurrencyCancelInProgress ConcurrencyGroupPolicy = "CancelInProgress"
+	// ConcurrencyReject fails the workflow run if the concurrency group is held by another run
+	ConcurrencyReject ConcurrencyGroupPolicy = "Reject"
+)
+
+// ConcurrencyGroup defines the concurrency group of the workflow run
+type ConcurrencyGroup struct {
+	// Group is the name of the concurrency group in the namespace, it's a go template which can reference
+	// the name, namespace, labels and annotations of the run, e.g. "deploy-{{ .labels.env }}"
+	Group string `json:"group"`
+	// Policy is the policy when the group is held by another run, default to Queue
+	Policy ConcurrencyGroupPolicy `json:"policy,omitempty"`
 }
 
 // WorkflowRunStatus record the status of workflow run
@@ -92,20 +148,34 @@ type WorkflowRunStatus struct {
 
 	ContextBackend *corev1.ObjectReference `json:"contextBackend,omitempty"`
 	Steps          []WorkflowStepStatus    `json:"steps,omitempty"`
+	// ExitHandlers records the status of the exit handler steps
+	ExitHandlers []WorkflowStepStatus `json:"exitHandlers,omitempty"`
 
 	StartTime metav1.Time `json:"startTime,omitempty"`
 	EndTime   metav1.Time `json:"endTime,omitempty"`
+
+	// RestartCount is the number of times the workflow run is restarted
+	RestartCount int `json:"restartCount,omitempty"`
 }
 
 // WorkflowSpec defines workflow steps and other attributes
 type WorkflowSpec struct {
 	Steps []WorkflowStep `json:"steps,omitempty"`
+	// OnSuccess is the steps to run after the workflow steps succeeded
+	OnSuccess []WorkflowStep `json:"onSuccess,omitempty"`
+	// OnFailure is the steps to run after the workflow steps failed or terminated
+	OnFailure []WorkflowStep `json:"onFailure,omitempty"`
+	// Finally is the steps to run after the workflow steps finished, whatever the outcome is
+	Finally []WorkflowStep `json:"finally,omitempty"`
 }
 
 // WorkflowExecuteMode defines the mode of workflow execution
 type WorkflowExecuteMode struct {
 	Steps    WorkflowMode `json:"steps,omitempty"`
 	SubSteps WorkflowMode `json:"subSteps,omitempty"`
+	// MaxParallelism is the max number of the steps running concurrently in DAG mode, default to the one
+	// configured in the controller
+	MaxParallelism int `json:"maxParallelism,omitempty"`
 }
 
 // WorkflowRunPhase is a label for the condition of a WorkflowRun at the current time
@@ -114,6 +184,8 @@ type WorkflowRunPhase string
 const (
 	// WorkflowStateInitializing means the workflow run is initializing
 	WorkflowStateInitializing WorkflowRunPhase = "initializing"
+	// WorkflowStateQueued means the workflow run is waiting for its concurrency group
+	WorkflowStateQueued WorkflowRunPhase = "queued"
 	// WorkflowStateExecuting means the workflow run is executing
 	WorkflowStateExecuting WorkflowRunPhase = "executing"
 	// WorkflowStateSuspending means the workflow run is suspending
@@ -152,10 +224,94 @@ type WorkflowList struct {
 	Items           []Workflow `json:"items"`
 }
 
+// +kubebuilder:object:root=true
+
+// CronWorkflow is the Schema for the cronWorkflow API, it creates WorkflowRuns on a schedule
+// +kubebuilder:storageversion
+// +kubebuilder:resource:categories={oam},shortName={cwf}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="SCHEDULE",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="LAST-SCHEDULE",type=date,JSONPath=".status.lastScheduleTime"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CronWorkflow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              CronWorkflowSpec   `json:"spec,omitempty"`
+	Status            CronWorkflowStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CronWorkflowList contains a list of CronWorkflow
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CronWorkflowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CronWorkflow `json:"items"`
+}
+
+// ConcurrencyPolicy describes how the WorkflowRuns created by a CronWorkflow are handled when they overlap
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows the WorkflowRuns to run concurrently
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the new WorkflowRun if the previous one hasn't finished yet
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent deletes the running WorkflowRuns and creates the new one
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// CronWorkflowSpec is the spec for the CronWorkflow
+type CronWorkflowSpec struct {
+	// Schedule is the schedule in cron format, e.g. "0 2 * * *"
+	Schedule string `json:"schedule"`
+	// TimeZone is the time zone name of the schedule, e.g. "Asia/Shanghai", default to the time zone of the controller
+	TimeZone *string `json:"timeZone,omitempty"`
+	// StartingDeadlineSeconds is the deadline in seconds for starting the WorkflowRun if it misses the scheduled time,
+	// the missed runs are not started if it's not set
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// ConcurrencyPolicy specifies how to handle the concurrent WorkflowRuns, default to Allow
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// SuccessfulRunsHistoryLimit is the number of the succeeded WorkflowRuns to keep, default to 3
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+	// FailedRunsHistoryLimit is the number of the failed or terminated WorkflowRuns to keep, default to 1
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+	// RunTemplate is the template of the WorkflowRuns to create
+	RunTemplate WorkflowRunTemplateSpec `json:"runTemplate"`
+}
+
+// WorkflowRunTemplateSpec is the template of the WorkflowRuns created by CronWorkflow
+type WorkflowRunTemplateSpec struct {
+	// Labels is the labels of the WorkflowRuns
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations is the annotations of the WorkflowRuns
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Spec        WorkflowRunSpec   `json:"spec"`
+}
+
+// CronWorkflowStatus record the status of the CronWorkflow
+type CronWorkflowStatus struct {
+	// Active is the running WorkflowRuns created by the CronWorkflow
+	Active []corev1.ObjectReference `json:"active,omitempty"`
+	// LastScheduleTime is the last time the WorkflowRun is scheduled
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastSuccessfulTime is the last time the WorkflowRun succeeded
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
 // WorkflowStep defines how to execute a workflow step.
 type WorkflowStep struct {
 	WorkflowStepBase `json:",inline"`
-	SubSteps         []WorkflowStepBase `json:"subSteps,omitempty"`
+	// Mode is the mode of the sub steps of the step group, default to the subSteps mode of the workflow
+	Mode WorkflowMode `json:"mode,omitempty"`
+	// SubSteps is the sub steps of the step group, the sub steps can be step groups as well
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	SubSteps []WorkflowStep `json:"subSteps,omitempty"`
 }
 
 // WorkflowStepMeta contains the meta data of a workflow step
@@ -181,12 +337,48 @@ type WorkflowStepBase struct {
 	Inputs StepInputs `json:"inputs,omitempty"`
 	// Outputs is the outputs of the step
 	Outputs StepOutputs `json:"outputs,omitempty"`
+	// Retry is the retry policy of the step
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Cache caches the outputs of the succeeded step by the hash of its type, template and rendered parameters
+	Cache *StepCache `json:"cache,omitempty"`
 
 	// Properties is the properties of the step
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Properties *runtime.RawExtension `json:"properties,omitempty"`
 }
 
+// StepCache defines how to cache the result of a workflow step
+type StepCache struct {
+	// TTL is how long the cached result is valid, e.g. 24h, the result never expires if it's empty
+	TTL string `json:"ttl,omitempty"`
+}
+
+// RetryPolicy defines how to retry a failed workflow step
+type RetryPolicy struct {
+	// Limit is the max failed attempts of the step, default to one more than the max retry times of the controller
+	Limit *int `json:"limit,omitempty"`
+	// Backoff is the backoff kind between retries, default to exponential
+	Backoff RetryBackoffKind `json:"backoff,omitempty"`
+	// Delay is the base delay between retries, e.g. 10s
+	Delay string `json:"delay,omitempty"`
+	// MaxDelay is the max delay between retries, e.g. 5m
+	MaxDelay string `json:"maxDelay,omitempty"`
+	// RetryOn is the failed reasons to retry on, support Execute and Rendering, default to Execute
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+// RetryBackoffKind describes how the delay grows between retries
+type RetryBackoffKind string
+
+const (
+	// RetryBackoffFixed waits the same delay between retries
+	RetryBackoffFixed RetryBackoffKind = "fixed"
+	// RetryBackoffLinear increases the delay linearly between retries
+	RetryBackoffLinear RetryBackoffKind = "linear"
+	// RetryBackoffExponential doubles the delay between retries
+	RetryBackoffExponential RetryBackoffKind = "exponential"
+)
+
 // WorkflowMode describes the mode of workflow
 type WorkflowMode string
 
@@ -207,6 +399,8 @@ type StepStatus struct {
 	Message string `json:"message,omitempty"`
 	// A brief CamelCase message indicating details about why the workflowStep is in this state.
 	Reason string `json:"reason,omitempty"`
+	// Retrying is true if the failed step is retried later, the reason of the failure is kept
+	Retrying bool `json:"retrying,omitempty"`
 	// FirstExecuteTime is the first time this step execution.
 	FirstExecuteTime metav1.Time `json:"firstExecuteTime,omitempty"`
 	// LastExecuteTime is the last time this step execution.
@@ -215,8 +409,11 @@ type StepStatus struct {
 
 // WorkflowStepStatus record the status of a workflow step, include step status and subStep status
 type WorkflowStepStatus struct {
-	StepStatus     `json:",inline"`
-	SubStepsStatus []StepStatus `json:"subSteps,omitempty"`
+	StepStatus `json:",inline"`
+	// SubStepsStatus is the status of the sub steps, the status of the nested step groups includes their sub steps
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	SubStepsStatus []WorkflowStepStatus `json:"subSteps,omitempty"`
 }
 
 // SetConditions set condition to workflow run
@@ -232,6 +429,9 @@ func (wr *WorkflowRun) GetCondition(t condition.ConditionType) condition.Conditi
 // WorkflowRunConditionType is a valid condition type for a WorkflowRun
 const WorkflowRunConditionType string = "WorkflowRun"
 
+// WorkflowRunControlConditionType is the condition type which records the last control from the spec of a WorkflowRun
+const WorkflowRunControlConditionType string = "Control"
+
 // WorkflowStepPhase describes the phase of a workflow step.
 type WorkflowStepPhase string
 
@@ -258,10 +458,23 @@ type StepInputs []inputItem
 
 type inputItem struct {
 	ParameterKey string `json:"parameterKey"`
-	From         string `json:"from"`
+	From         string `json:"from,omitempty"`
+	// FromSecret reads the input from the secret, the value is not kept in the workflow context
+	FromSecret *SecretKeySelector `json:"fromSecret,omitempty"`
+}
+
+// SecretKeySelector selects a key of the secret
+type SecretKeySelector struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Namespace is the namespace of the secret, default to the namespace of the workflow run, the user must be able
+	// to get the secret in the other namespace
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type outputItem struct {
 	ValueFrom string `json:"valueFrom"`
 	Name      string `json:"name"`
+	// Artifact writes the output to the artifact store and keeps the reference in the context
+	Artifact bool `json:"artifact,omitempty"`
 }
@@ -26,12 +26,207 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConcurrencyGroup) DeepCopyInto(out *ConcurrencyGroup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConcurrencyGroup.
+func (in *ConcurrencyGroup) DeepCopy() *ConcurrencyGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ConcurrencyGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CronWorkflow) DeepCopyInto(out *CronWorkflow) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CronWorkflow.
+func (in *CronWorkflow) DeepCopy() *CronWorkflow {
+	if in == nil {
+		return nil
+	}
+	out := new(CronWorkflow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CronWorkflow) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CronWorkflowList) DeepCopyInto(out *CronWorkflowList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CronWorkflow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CronWorkflowList.
+func (in *CronWorkflowList) DeepCopy() *CronWorkflowList {
+	if in == nil {
+		return nil
+	}
+	out := new(CronWorkflowList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CronWorkflowList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CronWorkflowSpec) DeepCopyInto(out *CronWorkflowSpec) {
+	*out = *in
+	if in.TimeZone != nil {
+		in, out := &in.TimeZone, &out.TimeZone
+		*out = new(string)
+		**out = **in
+	}
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SuccessfulRunsHistoryLimit != nil {
+		in, out := &in.SuccessfulRunsHistoryLimit, &out.SuccessfulRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedRunsHistoryLimit != nil {
+		in, out := &in.FailedRunsHistoryLimit, &out.FailedRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	in.RunTemplate.DeepCopyInto(&out.RunTemplate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CronWorkflowSpec.
+func (in *CronWorkflowSpec) DeepCopy() *CronWorkflowSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CronWorkflowSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CronWorkflowStatus) DeepCopyInto(out *CronWorkflowStatus) {
+	*out = *in
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = make([]v1.ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CronWorkflowStatus.
+func (in *CronWorkflowStatus) DeepCopy() *CronWorkflowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CronWorkflowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int)
+		**out = **in
+	}
+	if in.RetryOn != nil {
+		in, out := &in.RetryOn, &out.RetryOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeySelector.
+func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepCache) DeepCopyInto(out *StepCache) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepCache.
+func (in *StepCache) DeepCopy() *StepCache {
+	if in == nil {
+		return nil
+	}
+	out := new(StepCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in StepInputs) DeepCopyInto(out *StepInputs) {
 	{
 		in := &in
 		*out = make(StepInputs, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -64,6 +259,26 @@ func (in StepOutputs) DeepCopy() StepOutputs {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepResume) DeepCopyInto(out *StepResume) {
+	*out = *in
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepResume.
+func (in *StepResume) DeepCopy() *StepResume {
+	if in == nil {
+		return nil
+	}
+	out := new(StepResume)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepStatus) DeepCopyInto(out *StepStatus) {
 	*out = *in
@@ -226,6 +441,28 @@ func (in *WorkflowRunSpec) DeepCopyInto(out *WorkflowRunSpec) {
 		*out = new(WorkflowSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Context != nil {
+		in, out := &in.Context, &out.Context
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(ConcurrencyGroup)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ResumeSteps != nil {
+		in, out := &in.ResumeSteps, &out.ResumeSteps
+		*out = make([]StepResume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRunSpec.
@@ -255,6 +492,13 @@ func (in *WorkflowRunStatus) DeepCopyInto(out *WorkflowRunStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ExitHandlers != nil {
+		in, out := &in.ExitHandlers, &out.ExitHandlers
+		*out = make([]WorkflowStepStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.StartTime.DeepCopyInto(&out.StartTime)
 	in.EndTime.DeepCopyInto(&out.EndTime)
 }
@@ -269,6 +513,36 @@ func (in *WorkflowRunStatus) DeepCopy() *WorkflowRunStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowRunTemplateSpec) DeepCopyInto(out *WorkflowRunTemplateSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRunTemplateSpec.
+func (in *WorkflowRunTemplateSpec) DeepCopy() *WorkflowRunTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowRunTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkflowSpec) DeepCopyInto(out *WorkflowSpec) {
 	*out = *in
@@ -279,6 +553,27 @@ func (in *WorkflowSpec) DeepCopyInto(out *WorkflowSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.OnSuccess != nil {
+		in, out := &in.OnSuccess, &out.OnSuccess
+		*out = make([]WorkflowStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OnFailure != nil {
+		in, out := &in.OnFailure, &out.OnFailure
+		*out = make([]WorkflowStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Finally != nil {
+		in, out := &in.Finally, &out.Finally
+		*out = make([]WorkflowStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowSpec.
@@ -297,7 +592,7 @@ func (in *WorkflowStep) DeepCopyInto(out *WorkflowStep) {
 	in.WorkflowStepBase.DeepCopyInto(&out.WorkflowStepBase)
 	if in.SubSteps != nil {
 		in, out := &in.SubSteps, &out.SubSteps
-		*out = make([]WorkflowStepBase, len(*in))
+		*out = make([]WorkflowStep, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -330,13 +625,25 @@ func (in *WorkflowStepBase) DeepCopyInto(out *WorkflowStepBase) {
 	if in.Inputs != nil {
 		in, out := &in.Inputs, &out.Inputs
 		*out = make(StepInputs, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Outputs != nil {
 		in, out := &in.Outputs, &out.Outputs
 		*out = make(StepOutputs, len(*in))
 		copy(*out, *in)
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(StepCache)
+		**out = **in
+	}
 	if in.Properties != nil {
 		in, out := &in.Properties, &out.Properties
 		*out = new(runtime.RawExtension)
@@ -375,7 +682,7 @@ func (in *WorkflowStepStatus) DeepCopyInto(out *WorkflowStepStatus) {
 	in.StepStatus.DeepCopyInto(&out.StepStatus)
 	if in.SubStepsStatus != nil {
 		in, out := &in.SubStepsStatus, &out.SubStepsStatus
-		*out = make([]StepStatus, len(*in))
+		*out = make([]WorkflowStepStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -391,3 +698,23 @@ func (in *WorkflowStepStatus) DeepCopy() *WorkflowStepStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *inputItem) DeepCopyInto(out *inputItem) {
+	*out = *in
+	if in.FromSecret != nil {
+		in, out := &in.FromSecret, &out.FromSecret
+		*out = new(SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new inputItem.
+func (in *inputItem) DeepCopy() *inputItem {
+	if in == nil {
+		return nil
+	}
+	out := new(inputItem)
+	in.DeepCopyInto(out)
+	return out
+}
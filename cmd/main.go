@@ -34,9 +34,12 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/util/feature"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/metadata"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	velaclient "github.com/kubevela/pkg/controller/client"
@@ -44,11 +47,17 @@ import (
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/controllers"
+	"github.com/kubevela/workflow/pkg/artifact"
 	"github.com/kubevela/workflow/pkg/common"
+	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/packages"
 	"github.com/kubevela/workflow/pkg/features"
+	"github.com/kubevela/workflow/pkg/gc"
 	"github.com/kubevela/workflow/pkg/monitor/watcher"
+	"github.com/kubevela/workflow/pkg/sharding"
 	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/wakeup"
+	"github.com/kubevela/workflow/pkg/webhook"
 	"github.com/kubevela/workflow/version"
 	//+kubebuilder:scaffold:imports
 )
@@ -65,14 +74,17 @@ func init() {
 }
 
 func main() {
-	var metricsAddr, logFilePath, probeAddr, pprofAddr, leaderElectionResourceLock string
+	var metricsAddr, logFilePath, probeAddr, pprofAddr, leaderElectionResourceLock, certDir string
 	var backupStrategy, backupIgnoreStrategy, backupPersistType, groupByLabel string
-	var enableLeaderElection, logDebug, backupCleanOnBackup bool
+	var artifactStore, artifactDir string
+	var enableLeaderElection, logDebug, backupCleanOnBackup, useWebhook bool
 	var qps float64
 	var logFileMaxSize uint64
 	var burst, webhookPort int
 	var leaseDuration, renewDeadline, retryPeriod time.Duration
 	var controllerArgs controllers.Args
+	var gcArgs gc.Args
+	var shardingArgs sharding.Args
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -89,7 +101,9 @@ func main() {
 		"The duration that the acting controlplane will retry refreshing leadership before giving up")
 	flag.DurationVar(&retryPeriod, "leader-election-retry-period", 2*time.Second,
 		"The duration the LeaderElector clients should wait between tries of actions")
+	flag.BoolVar(&useWebhook, "use-webhook", false, "Enable the admission webhooks of Workflow, WorkflowRun and CronWorkflow")
 	flag.IntVar(&webhookPort, "webhook-port", 9443, "admission webhook listen address")
+	flag.StringVar(&certDir, "webhook-cert-dir", "/k8s-webhook-server/serving-certs", "The directory of the admission webhook cert and key")
 	flag.IntVar(&controllerArgs.ConcurrentReconciles, "concurrent-reconciles", 4, "concurrent-reconciles is the concurrent reconcile number of the controller. The default value is 4")
 	flag.Float64Var(&qps, "kube-api-qps", 50, "the qps for reconcile clients. Low qps may lead to low throughput. High qps may give stress to api-server. Raise this value if concurrent-reconciles is set to be high.")
 	flag.IntVar(&burst, "kube-api-burst", 100, "the burst for reconcile clients. Recommend setting it qps*2.")
@@ -97,11 +111,24 @@ func main() {
 	flag.IntVar(&types.MaxWorkflowWaitBackoffTime, "max-workflow-wait-backoff-time", 60, "Set the max workflow wait backoff time, default is 60")
 	flag.IntVar(&types.MaxWorkflowFailedBackoffTime, "max-workflow-failed-backoff-time", 300, "Set the max workflow wait backoff time, default is 300")
 	flag.IntVar(&types.MaxWorkflowStepErrorRetryTimes, "max-workflow-step-error-retry-times", 10, "Set the max workflow step error retry times, default is 10")
+	flag.IntVar(&types.MaxWorkflowStepParallelism, "max-workflow-step-parallelism", 1, "Set the default max number of the steps running concurrently in DAG mode, the steps run one by one if it's not larger than 1, default is 1")
 	flag.StringVar(&backupStrategy, "backup-strategy", "RemainLatestFailedRecord", "Set the strategy for backup workflow records, default is RemainLatestFailedRecord")
 	flag.StringVar(&backupIgnoreStrategy, "backup-ignore-strategy", "IgnoreLatestFailedRecord", "Set the strategy for ignore backup workflow records, default is IgnoreLatestFailedRecord")
 	flag.StringVar(&backupPersistType, "backup-persist-type", "", "Set the persist type for backup workflow records, default is empty")
 	flag.StringVar(&groupByLabel, "backup-group-by-label", "", "Set the label for group by, default is empty")
 	flag.BoolVar(&backupCleanOnBackup, "backup-clean-on-backup", false, "Set the auto clean for backup workflow records, default is false")
+	flag.DurationVar(&gcArgs.Interval, "gc-interval", time.Minute, "Set the interval of the garbage collection of finished workflow runs, default is 1m")
+	flag.IntVar(&gcArgs.SucceededHistoryLimit, "gc-succeeded-history-limit", -1, "Set the number of succeeded workflow runs to keep in each group, negative means no limit, default is -1")
+	flag.IntVar(&gcArgs.FailedHistoryLimit, "gc-failed-history-limit", -1, "Set the number of failed workflow runs to keep in each group, negative means no limit, default is -1")
+	flag.StringVar(&gcArgs.GroupByLabel, "gc-group-by-label", "", "Set the label to group workflow runs for the history limits, the runs are grouped by the referenced workflow if it's empty")
+	flag.StringVar(&artifactStore, "artifact-store", "", "Set the store of the large outputs, support configmap and file, the outputs are kept in the workflow context if it's empty")
+	flag.StringVar(&artifactDir, "artifact-dir", "/var/lib/workflow/artifacts", "Set the directory of the file artifact store, e.g. the mount path of a PVC")
+	flag.IntVar(&wfContext.ArtifactSizeThreshold, "artifact-size-threshold", 256*1024, "Set the size in bytes above which the outputs are written to the artifact store, default is 256KiB")
+	flag.BoolVar(&shardingArgs.EnableSharding, "enable-sharding", false, "Enable the sharding of workflow runs across the controller replicas, each replica only reconciles the workflow runs in its shard")
+	flag.StringVar(&shardingArgs.ShardID, "shard-id", sharding.MasterShardID, "Set the shard id of the replica, the master shard schedules the workflow runs to the shards, default is master")
+	flag.StringVar(&shardingArgs.Namespace, "shard-lease-namespace", "vela-system", "Set the namespace of the heartbeat leases of the shards, default is vela-system")
+	flag.DurationVar(&shardingArgs.LeaseDuration, "shard-lease-duration", 30*time.Second, "Set the duration after which a shard is considered unhealthy if its lease is not renewed, default is 30s")
+	flag.DurationVar(&shardingArgs.ScheduleInterval, "shard-schedule-interval", 5*time.Second, "Set the interval of scheduling the unassigned workflow runs to the shards, default is 5s")
 	multicluster.AddClusterGatewayClientFlags(flag.CommandLine)
 	feature.DefaultMutableFeatureGate.AddFlag(flag.CommandLine)
 
@@ -166,10 +193,17 @@ func main() {
 	)
 
 	leaderElectionID := fmt.Sprintf("workflow-%s", strings.ToLower(strings.ReplaceAll(version.VelaVersion, ".", "-")))
+	var newCache cache.NewCacheFunc
+	if shardingArgs.EnableSharding {
+		klog.InfoS("Enable the sharding of workflow runs", "shard", shardingArgs.ShardID)
+		leaderElectionID = fmt.Sprintf("%s-%s", leaderElectionID, shardingArgs.ShardID)
+		newCache = shardingArgs.NewCacheFunc()
+	}
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                     scheme,
 		MetricsBindAddress:         metricsAddr,
 		Port:                       webhookPort,
+		CertDir:                    certDir,
 		HealthProbeBindAddress:     probeAddr,
 		LeaderElection:             enableLeaderElection,
 		LeaderElectionID:           leaderElectionID,
@@ -178,12 +212,24 @@ func main() {
 		RenewDeadline:              &renewDeadline,
 		RetryPeriod:                &retryPeriod,
 		NewClient:                  velaclient.DefaultNewControllerClient,
+		NewCache:                   newCache,
 	})
 	if err != nil {
 		klog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	switch artifactStore {
+	case "":
+	case "configmap":
+		wfContext.DefaultArtifactStore = artifact.NewConfigMapStore(mgr.GetClient())
+	case "file":
+		wfContext.DefaultArtifactStore = artifact.NewFileStore(artifactDir)
+	default:
+		klog.ErrorS(nil, "Unsupported artifact store", "store", artifactStore)
+		os.Exit(1)
+	}
+
 	pd, err := packages.NewPackageDiscover(mgr.GetConfig())
 	if err != nil {
 		klog.Error(err, "Failed to create CRD discovery for CUE package client")
@@ -192,32 +238,85 @@ func main() {
 		}
 	}
 
+	// the workflow runs in the other shards, e.g. the ones in the same concurrency group, are read from the api
+	// server, the others are read from the cache
+	var runReader client.Reader
+	if shardingArgs.EnableSharding {
+		runReader = mgr.GetAPIReader()
+	}
+
+	var objectWatcher *wakeup.Watcher
+	if feature.DefaultMutableFeatureGate.Enabled(features.EnableWatchWaitingResources) {
+		metadataClient, err := metadata.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			klog.Error(err, "unable to create the metadata client to watch the waiting resources")
+			os.Exit(1)
+		}
+		objectWatcher = wakeup.New(metadataClient, mgr.GetRESTMapper())
+	}
 	if err = (&controllers.WorkflowRunReconciler{
 		Client:          mgr.GetClient(),
 		Scheme:          mgr.GetScheme(),
 		PackageDiscover: pd,
 		Recorder:        event.NewAPIRecorder(mgr.GetEventRecorderFor("WorkflowRun")),
+		Watcher:         objectWatcher,
+		Sharding:        shardingArgs,
+		APIReader:       runReader,
 		Args:            controllerArgs,
 	}).SetupWithManager(mgr); err != nil {
 		klog.Error(err, "unable to create controller", "controller", "WorkflowRun")
 		os.Exit(1)
 	}
 
-	if feature.DefaultMutableFeatureGate.Enabled(features.EnableBackupWorkflowRecord) {
-		if err = (&controllers.BackupReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
-			BackupArgs: controllers.BackupArgs{
-				BackupStrategy: backupStrategy,
-				IgnoreStrategy: backupIgnoreStrategy,
-				CleanOnBackup:  backupCleanOnBackup,
-				GroupByLabel:   groupByLabel,
-			},
-			Args: controllerArgs,
+	if shardingArgs.EnableSharding {
+		if err = mgr.Add(sharding.NewHeartbeat(mgr.GetClient(), shardingArgs)); err != nil {
+			klog.Error(err, "unable to add the heartbeat of the shard")
+			os.Exit(1)
+		}
+	}
+
+	// the controllers below need the workflow runs in all the shards, they only run in the master shard
+	if shardingArgs.IsMaster() {
+		if err = (&controllers.CronWorkflowReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: event.NewAPIRecorder(mgr.GetEventRecorderFor("CronWorkflow")),
+			Args:     controllerArgs,
 		}).SetupWithManager(mgr); err != nil {
-			klog.Error(err, "unable to create controller", "controller", "backup")
+			klog.Error(err, "unable to create controller", "controller", "CronWorkflow")
+			os.Exit(1)
+		}
+
+		if feature.DefaultMutableFeatureGate.Enabled(features.EnableBackupWorkflowRecord) {
+			if err = (&controllers.BackupReconciler{
+				Client: mgr.GetClient(),
+				Scheme: mgr.GetScheme(),
+				BackupArgs: controllers.BackupArgs{
+					BackupStrategy: backupStrategy,
+					IgnoreStrategy: backupIgnoreStrategy,
+					CleanOnBackup:  backupCleanOnBackup,
+					GroupByLabel:   groupByLabel,
+				},
+				Args: controllerArgs,
+			}).SetupWithManager(mgr); err != nil {
+				klog.Error(err, "unable to create controller", "controller", "backup")
+				os.Exit(1)
+			}
+		}
+		if err = mgr.Add(gc.NewCollector(mgr.GetClient(), mgr.GetAPIReader(), gcArgs)); err != nil {
+			klog.Error(err, "unable to add the garbage collector of workflow runs")
 			os.Exit(1)
 		}
+		if shardingArgs.EnableSharding {
+			if err = mgr.Add(sharding.NewScheduler(mgr.GetClient(), mgr.GetAPIReader(), shardingArgs)); err != nil {
+				klog.Error(err, "unable to add the scheduler of the shards")
+				os.Exit(1)
+			}
+		}
+	}
+	if useWebhook {
+		klog.InfoS("Enable the admission webhooks", "port", webhookPort)
+		webhook.Register(mgr)
 	}
 	//+kubebuilder:scaffold:builder
 
@@ -230,12 +329,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	klog.Info("Start the vela workflow monitor")
-	informer, err := mgr.GetCache().GetInformer(context.Background(), &v1alpha1.WorkflowRun{})
-	if err != nil {
-		klog.ErrorS(err, "Unable to get informer for application")
+	// the master watches the workflow runs in all the shards, so the runs are only counted by the master if the
+	// sharding is enabled
+	if shardingArgs.IsMaster() {
+		klog.Info("Start the vela workflow monitor")
+		informer, err := mgr.GetCache().GetInformer(context.Background(), &v1alpha1.WorkflowRun{})
+		if err != nil {
+			klog.ErrorS(err, "Unable to get informer for application")
+		}
+		watcher.StartWorkflowRunMetricsWatcher(informer)
 	}
-	watcher.StartWorkflowRunMetricsWatcher(informer)
 
 	klog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
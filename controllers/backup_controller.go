@@ -35,6 +35,7 @@ import (
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/backup"
+	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -137,6 +138,9 @@ func (r *BackupReconciler) backup(ctx monitorContext.Context, cli client.Client,
 		}
 	}
 	if r.CleanOnBackup {
+		if err := wfContext.DeleteArtifacts(ctx, cli, run.Namespace, run.Name); err != nil {
+			return err
+		}
 		if err := cli.Delete(ctx, run); err != nil && !kerrors.IsNotFound(err) {
 			return err
 		}
@@ -0,0 +1,313 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlEvent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cron"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	defaultSuccessfulRunsHistoryLimit int32 = 3
+	defaultFailedRunsHistoryLimit     int32 = 1
+)
+
+// CronWorkflowReconciler reconciles a CronWorkflow object
+type CronWorkflowReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder event.Recorder
+	Args
+	// now returns the current time, it's replaced in tests
+	now func() time.Time
+}
+
+// Reconcile reconciles the CronWorkflow object
+// +kubebuilder:rbac:groups=core.oam.dev,resources=cronworkflows,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.oam.dev,resources=cronworkflows/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core.oam.dev,resources=workflowruns,verbs=get;list;watch;create;update;patch;delete
+func (r *CronWorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReconcileTimeout)
+	defer cancel()
+
+	logCtx := monitorContext.NewTraceContext(ctx, "").AddTag("cronworkflow", req.String())
+	logCtx.Info("Start reconcile cronworkflow")
+	defer logCtx.Commit("End reconcile cronworkflow")
+	cw := new(v1alpha1.CronWorkflow)
+	if err := r.Get(ctx, client.ObjectKey{
+		Name:      req.Name,
+		Namespace: req.Namespace,
+	}, cw); err != nil {
+		if !kerrors.IsNotFound(err) {
+			logCtx.Error(err, "get cronworkflow")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !cw.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	oldStatus := cw.Status.DeepCopy()
+
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := r.List(ctx, runs, client.InNamespace(cw.Namespace), client.MatchingLabels{types.LabelCronWorkflowName: cw.Name}); err != nil {
+		logCtx.Error(err, "list workflowruns")
+		return ctrl.Result{}, err
+	}
+	active := r.syncRunsStatus(cw, runs.Items)
+	if err := r.cleanupFinishedRuns(logCtx, cw, runs.Items); err != nil {
+		logCtx.Error(err, "cleanup finished workflowruns")
+		return ctrl.Result{}, err
+	}
+
+	schedule, err := cron.Parse(cw.Spec.Schedule)
+	if err != nil {
+		logCtx.Error(err, "parse schedule", "schedule", cw.Spec.Schedule)
+		r.Recorder.Event(cw, event.Warning(v1alpha1.ReasonSchedule, errors.WithMessage(err, v1alpha1.MessageFailedSchedule)))
+		return ctrl.Result{}, r.updateStatus(ctx, cw, oldStatus)
+	}
+	loc := time.Local
+	if cw.Spec.TimeZone != nil {
+		if loc, err = time.LoadLocation(*cw.Spec.TimeZone); err != nil {
+			logCtx.Error(err, "load time zone", "timeZone", *cw.Spec.TimeZone)
+			r.Recorder.Event(cw, event.Warning(v1alpha1.ReasonSchedule, errors.WithMessage(err, v1alpha1.MessageFailedSchedule)))
+			return ctrl.Result{}, r.updateStatus(ctx, cw, oldStatus)
+		}
+	}
+	now := r.getNow().In(loc)
+	scheduledTime, next := getScheduleTimes(cw, schedule, now)
+	result := ctrl.Result{}
+	if !next.IsZero() {
+		// add a small buffer to make sure the schedule time has come when requeued
+		result.RequeueAfter = next.Sub(now) + 100*time.Millisecond
+	}
+	if scheduledTime == nil {
+		return result, r.updateStatus(ctx, cw, oldStatus)
+	}
+
+	if deadline := cw.Spec.StartingDeadlineSeconds; deadline != nil && scheduledTime.Add(time.Duration(*deadline)*time.Second).Before(now) {
+		logCtx.Info("Missed the starting deadline of the scheduled time", "scheduledTime", scheduledTime)
+		r.Recorder.Event(cw, event.Warning(v1alpha1.ReasonSchedule, fmt.Errorf("missed the starting deadline of the scheduled time %s", scheduledTime.Format(time.RFC3339))))
+		return result, r.updateStatus(ctx, cw, oldStatus)
+	}
+	switch cw.Spec.ConcurrencyPolicy {
+	case v1alpha1.ForbidConcurrent:
+		if len(active) > 0 {
+			logCtx.Info("Skip the scheduled time since the previous workflowrun is still running", "scheduledTime", scheduledTime)
+			return result, r.updateStatus(ctx, cw, oldStatus)
+		}
+	case v1alpha1.ReplaceConcurrent:
+		for i := range active {
+			if err := wfContext.DeleteArtifacts(ctx, r.Client, active[i].Namespace, active[i].Name); err != nil {
+				logCtx.Error(err, "delete artifacts of active workflowrun", "workflowrun", active[i].Name)
+			}
+			if err := r.Delete(ctx, &active[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !kerrors.IsNotFound(err) {
+				logCtx.Error(err, "delete active workflowrun", "workflowrun", active[i].Name)
+				return ctrl.Result{}, err
+			}
+		}
+		cw.Status.Active = nil
+	}
+
+	run := generateScheduledRun(cw, *scheduledTime)
+	if err := r.Create(ctx, run); err != nil && !kerrors.IsAlreadyExists(err) {
+		logCtx.Error(err, "create workflowrun", "workflowrun", run.Name)
+		r.Recorder.Event(cw, event.Warning(v1alpha1.ReasonSchedule, errors.WithMessage(err, v1alpha1.MessageFailedSchedule)))
+		return ctrl.Result{}, err
+	}
+	logCtx.Info("Successfully create workflowrun", "workflowrun", run.Name)
+	r.Recorder.Event(cw, event.Normal(v1alpha1.ReasonSchedule, fmt.Sprintf("Created WorkflowRun %s", run.Name)))
+	cw.Status.Active = append(cw.Status.Active, getRunReference(run))
+	cw.Status.LastScheduleTime = &metav1.Time{Time: *scheduledTime}
+	return result, r.updateStatus(ctx, cw, oldStatus)
+}
+
+// syncRunsStatus syncs the active runs and last successful time into the status, and returns the active runs.
+func (r *CronWorkflowReconciler) syncRunsStatus(cw *v1alpha1.CronWorkflow, runs []v1alpha1.WorkflowRun) []v1alpha1.WorkflowRun {
+	var active []v1alpha1.WorkflowRun
+	cw.Status.Active = nil
+	for i, run := range runs {
+		if !run.Status.Finished {
+			active = append(active, run)
+			cw.Status.Active = append(cw.Status.Active, getRunReference(&runs[i]))
+			continue
+		}
+		if run.Status.Phase == v1alpha1.WorkflowStateSucceeded {
+			if cw.Status.LastSuccessfulTime == nil || cw.Status.LastSuccessfulTime.Before(&run.Status.EndTime) {
+				cw.Status.LastSuccessfulTime = run.Status.EndTime.DeepCopy()
+			}
+		}
+	}
+	return active
+}
+
+// cleanupFinishedRuns deletes the finished runs which exceed the history limits.
+func (r *CronWorkflowReconciler) cleanupFinishedRuns(ctx context.Context, cw *v1alpha1.CronWorkflow, runs []v1alpha1.WorkflowRun) error {
+	var succeeded, failed []v1alpha1.WorkflowRun
+	for _, run := range runs {
+		if !run.Status.Finished {
+			continue
+		}
+		if run.Status.Phase == v1alpha1.WorkflowStateSucceeded {
+			succeeded = append(succeeded, run)
+		} else {
+			failed = append(failed, run)
+		}
+	}
+	successfulLimit := pointer.Int32Deref(cw.Spec.SuccessfulRunsHistoryLimit, defaultSuccessfulRunsHistoryLimit)
+	failedLimit := pointer.Int32Deref(cw.Spec.FailedRunsHistoryLimit, defaultFailedRunsHistoryLimit)
+	for _, item := range append(getRunsOverLimit(succeeded, successfulLimit), getRunsOverLimit(failed, failedLimit)...) {
+		run := item
+		if err := wfContext.DeleteArtifacts(ctx, r.Client, run.Namespace, run.Name); err != nil {
+			return errors.WithMessagef(err, "delete artifacts of workflowrun %s", run.Name)
+		}
+		if err := r.Delete(ctx, &run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !kerrors.IsNotFound(err) {
+			return errors.WithMessagef(err, "delete workflowrun %s", run.Name)
+		}
+	}
+	return nil
+}
+
+func (r *CronWorkflowReconciler) updateStatus(ctx context.Context, cw *v1alpha1.CronWorkflow, oldStatus *v1alpha1.CronWorkflowStatus) error {
+	if reflect.DeepEqual(oldStatus, &cw.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, cw); err != nil {
+		return errors.WithMessage(err, "failed to update cronworkflow status")
+	}
+	return nil
+}
+
+func (r *CronWorkflowReconciler) getNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// getRunsOverLimit returns the oldest runs which exceed the limit.
+func getRunsOverLimit(runs []v1alpha1.WorkflowRun, limit int32) []v1alpha1.WorkflowRun {
+	if len(runs) <= int(limit) {
+		return nil
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].CreationTimestamp.Equal(&runs[j].CreationTimestamp) {
+			return runs[i].Name < runs[j].Name
+		}
+		return runs[i].CreationTimestamp.Before(&runs[j].CreationTimestamp)
+	})
+	return runs[:len(runs)-int(limit)]
+}
+
+// getScheduleTimes returns the latest unmet scheduled time before now, and the next scheduled time after now.
+func getScheduleTimes(cw *v1alpha1.CronWorkflow, schedule *cron.Schedule, now time.Time) (*time.Time, time.Time) {
+	earliest := cw.CreationTimestamp.Time
+	if cw.Status.LastScheduleTime != nil {
+		earliest = cw.Status.LastScheduleTime.Time
+	}
+	if deadline := cw.Spec.StartingDeadlineSeconds; deadline != nil {
+		if start := now.Add(-time.Duration(*deadline) * time.Second); start.After(earliest) {
+			earliest = start
+		}
+	}
+	var scheduledTime *time.Time
+	t := schedule.Next(earliest.In(now.Location()))
+	for ; !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		missed := t
+		scheduledTime = &missed
+	}
+	return scheduledTime, t
+}
+
+func generateScheduledRun(cw *v1alpha1.CronWorkflow, scheduledTime time.Time) *v1alpha1.WorkflowRun {
+	labels := make(map[string]string)
+	for k, v := range cw.Spec.RunTemplate.Labels {
+		labels[k] = v
+	}
+	labels[types.LabelCronWorkflowName] = cw.Name
+	annotations := make(map[string]string)
+	for k, v := range cw.Spec.RunTemplate.Annotations {
+		annotations[k] = v
+	}
+	annotations[types.AnnotationCronWorkflowScheduledTime] = scheduledTime.Format(time.RFC3339)
+	return &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			// use the scheduled time in minutes as the suffix to avoid creating duplicated runs
+			Name:        fmt.Sprintf("%s-%d", cw.Name, scheduledTime.Unix()/60),
+			Namespace:   cw.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cw, v1alpha1.CronWorkflowGroupVersionKind),
+			},
+		},
+		Spec: *cw.Spec.RunTemplate.Spec.DeepCopy(),
+	}
+}
+
+func getRunReference(run *v1alpha1.WorkflowRun) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		Kind:       v1alpha1.WorkflowRunKind,
+		Name:       run.Name,
+		Namespace:  run.Namespace,
+		UID:        run.UID,
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CronWorkflowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.ConcurrentReconciles,
+		}).
+		// ignore the status changes of the cron workflow itself
+		For(&v1alpha1.CronWorkflow{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// only handle the workflow runs when they are created, finished or deleted
+		Owns(&v1alpha1.WorkflowRun{}, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e ctrlEvent.UpdateEvent) bool {
+				new := e.ObjectNew.(*v1alpha1.WorkflowRun)
+				old := e.ObjectOld.(*v1alpha1.WorkflowRun)
+				return new.Status.Finished != old.Status.Finished
+			},
+		})).
+		Complete(r)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+var _ = Describe("Test CronWorkflow", func() {
+	ctx := context.Background()
+	namespace := "test-cron-ns"
+
+	BeforeEach(func() {
+		setupNamespace(ctx, namespace)
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.DeleteAllOf(ctx, &v1alpha1.CronWorkflow{}, client.InNamespace(namespace))).Should(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &v1alpha1.WorkflowRun{}, client.InNamespace(namespace))).Should(Succeed())
+	})
+
+	It("schedule workflow runs with forbid concurrency policy and history limit", func() {
+		cw := &v1alpha1.CronWorkflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cron",
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.CronWorkflowSpec{
+				Schedule:                   "*/5 * * * *",
+				TimeZone:                   pointer.String("UTC"),
+				ConcurrencyPolicy:          v1alpha1.ForbidConcurrent,
+				SuccessfulRunsHistoryLimit: pointer.Int32(1),
+				RunTemplate: v1alpha1.WorkflowRunTemplateSpec{
+					Labels: map[string]string{"app": "backup"},
+					Spec: v1alpha1.WorkflowRunSpec{
+						WorkflowSpec: &v1alpha1.WorkflowSpec{
+							Steps: []v1alpha1.WorkflowStep{
+								{
+									WorkflowStepBase: v1alpha1.WorkflowStepBase{
+										Name: "step-1",
+										Type: "suspend",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cw)).Should(BeNil())
+		created := cw.CreationTimestamp.Time
+		now := created.Add(6 * time.Minute)
+		cronReconciler := &CronWorkflowReconciler{
+			Client:   k8sClient,
+			Scheme:   testScheme,
+			Recorder: event.NewAPIRecorder(recorder),
+			now:      func() time.Time { return now },
+		}
+
+		By("create the first run")
+		tryReconcileCron(cronReconciler, cw.Name, cw.Namespace)
+		runs := listCronRuns(ctx, cw)
+		Expect(len(runs)).Should(BeEquivalentTo(1))
+		first := runs[0]
+		Expect(first.Labels["app"]).Should(BeEquivalentTo("backup"))
+		Expect(first.OwnerReferences[0].Name).Should(BeEquivalentTo(cw.Name))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cw), cw)).Should(BeNil())
+		Expect(len(cw.Status.Active)).Should(BeEquivalentTo(1))
+		Expect(cw.Status.LastScheduleTime).ShouldNot(BeNil())
+
+		By("skip the schedule since the first run is still running")
+		now = created.Add(11 * time.Minute)
+		tryReconcileCron(cronReconciler, cw.Name, cw.Namespace)
+		Expect(len(listCronRuns(ctx, cw))).Should(BeEquivalentTo(1))
+
+		By("create the second run after the first run finished")
+		finishRun(ctx, &first)
+		tryReconcileCron(cronReconciler, cw.Name, cw.Namespace)
+		runs = listCronRuns(ctx, cw)
+		Expect(len(runs)).Should(BeEquivalentTo(2))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cw), cw)).Should(BeNil())
+		Expect(cw.Status.LastSuccessfulTime).ShouldNot(BeNil())
+
+		By("prune the first run after the second run finished")
+		for i := range runs {
+			if runs[i].Name != first.Name {
+				finishRun(ctx, &runs[i])
+			}
+		}
+		now = created.Add(16 * time.Minute)
+		tryReconcileCron(cronReconciler, cw.Name, cw.Namespace)
+		runs = listCronRuns(ctx, cw)
+		Expect(len(runs)).Should(BeEquivalentTo(2))
+		for _, run := range runs {
+			Expect(run.Name).ShouldNot(BeEquivalentTo(first.Name))
+		}
+	})
+
+	It("invalid schedule", func() {
+		cw := &v1alpha1.CronWorkflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "invalid-cron",
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.CronWorkflowSpec{
+				Schedule: "invalid",
+				RunTemplate: v1alpha1.WorkflowRunTemplateSpec{
+					Spec: v1alpha1.WorkflowRunSpec{WorkflowRef: "not-exist"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cw)).Should(BeNil())
+		cronReconciler := &CronWorkflowReconciler{
+			Client:   k8sClient,
+			Scheme:   testScheme,
+			Recorder: event.NewAPIRecorder(recorder),
+		}
+		tryReconcileCron(cronReconciler, cw.Name, cw.Namespace)
+		Expect(len(listCronRuns(ctx, cw))).Should(BeEquivalentTo(0))
+	})
+})
+
+func tryReconcileCron(r *CronWorkflowReconciler, name, ns string) {
+	_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKey{
+		Name:      name,
+		Namespace: ns,
+	}})
+	Expect(err).Should(BeNil())
+}
+
+func listCronRuns(ctx context.Context, cw *v1alpha1.CronWorkflow) []v1alpha1.WorkflowRun {
+	runs := &v1alpha1.WorkflowRunList{}
+	Expect(k8sClient.List(ctx, runs, client.InNamespace(cw.Namespace), client.MatchingLabels{types.LabelCronWorkflowName: cw.Name})).Should(BeNil())
+	return runs.Items
+}
+
+func finishRun(ctx context.Context, run *v1alpha1.WorkflowRun) {
+	run.Status.Finished = true
+	run.Status.Phase = v1alpha1.WorkflowStateSucceeded
+	run.Status.EndTime = metav1.Now()
+	Expect(k8sClient.Status().Update(ctx, run)).Should(BeNil())
+}
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	sysruntime "runtime"
 	"strings"
@@ -28,6 +29,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -39,7 +41,10 @@ import (
 
 	"github.com/kubevela/pkg/util/test/definition"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/artifact"
+	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/debug"
 	"github.com/kubevela/workflow/pkg/features"
 	wfTypes "github.com/kubevela/workflow/pkg/types"
@@ -656,17 +661,17 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "step2",
 						Type:       "test-apply",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "step3",
 						Type:       "test-apply",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 		}
@@ -730,18 +735,18 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "step2",
 						Type:       "test-apply",
 						DependsOn:  []string{"step3"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "step3",
 						Type:       "test-apply",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 		}
@@ -910,25 +915,25 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub1",
 						Type:       "test-apply",
 						If:         "always",
 						DependsOn:  []string{"sub2-failed"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub2-failed",
 						Type:       "apply-object",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"value":[{"apiVersion":"v1","kind":"invalid","metadata":{"name":"test1"}}]}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub3",
 						Type:       "test-apply",
 						DependsOn:  []string{"sub1"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 			{
@@ -1075,15 +1080,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub1",
 						Type:       "test-apply",
 						If:         "status.sub2.timeout",
 						DependsOn:  []string{"sub2"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub2",
 						Type:       "suspend",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"duration":"1s"}`)},
@@ -1093,8 +1098,8 @@ var _ = Describe("Test Workflow", func() {
 								ValueFrom: "context.name",
 							},
 						},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:      "sub3",
 						Type:      "test-apply",
 						DependsOn: []string{"sub1"},
@@ -1106,7 +1111,7 @@ var _ = Describe("Test Workflow", func() {
 						},
 						If:         `status.sub1.timeout || inputs["suspend-output"] == "wr-if-expressions-substeps"`,
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 			{
@@ -1129,12 +1134,12 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:    "group2-sub",
 						Type:    "suspend",
 						Timeout: "1s",
-					},
+					}},
 				},
 			},
 		}
@@ -1260,26 +1265,26 @@ var _ = Describe("Test Workflow", func() {
 					Name: "group",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub1",
 						Type:       "test-apply",
 						If:         "always",
 						DependsOn:  []string{"sub2"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub2",
 						Type:       "test-apply",
 						Timeout:    "1s",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "sub3",
 						Type:       "test-apply",
 						DependsOn:  []string{"sub1"},
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 			{
@@ -1304,11 +1309,11 @@ var _ = Describe("Test Workflow", func() {
 					Type:    "step-group",
 					Timeout: "1s",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "group2-sub",
 						Type: "suspend",
-					},
+					}},
 				},
 			},
 		}
@@ -1409,6 +1414,315 @@ var _ = Describe("Test Workflow", func() {
 		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateTerminated))
 	})
 
+	It("test the finalizer of the artifacts", func() {
+		dir, err := os.MkdirTemp("", "artifacts")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		wfContext.DefaultArtifactStore = artifact.NewFileStore(dir)
+		wfContext.ArtifactSizeThreshold = 16
+		defer func() {
+			wfContext.DefaultArtifactStore = nil
+			wfContext.ArtifactSizeThreshold = 0
+		}()
+
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "wr-artifacts-finalizer"
+		wr.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "step1",
+					Type: "suspend",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, wr)).Should(BeNil())
+		wrKey := types.NamespacedName{Namespace: wr.Namespace, Name: wr.Name}
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+
+		checkRun := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Finalizers).Should(ContainElement(wfTypes.FinalizerWorkflowRunArtifacts))
+
+		// the large var is written to the artifact store
+		wfCtx, err := wfContext.LoadContext(k8sClient, wr.Namespace, wr.Name)
+		Expect(err).Should(BeNil())
+		v, err := wfCtx.MakeParameter(map[string]interface{}{"data": strings.Repeat("x", 64)})
+		Expect(err).Should(BeNil())
+		Expect(wfCtx.SetVar(v, "large")).Should(BeNil())
+		Expect(wfCtx.Commit()).Should(BeNil())
+		files, err := os.ReadDir(filepath.Join(dir, wr.Namespace))
+		Expect(err).Should(BeNil())
+		Expect(len(files)).Should(Equal(1))
+
+		// the artifacts are deleted before the finalizer is removed
+		Expect(k8sClient.Delete(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		files, err = os.ReadDir(filepath.Join(dir, wr.Namespace))
+		Expect(err).Should(BeNil())
+		Expect(len(files)).Should(Equal(0))
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(utils.NotFoundMatcher{})
+	})
+
+	It("test restart from the failed step", func() {
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "wr-restart"
+		wr.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "step1",
+					Type:       "test-apply",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "step2",
+					Type:       "test-apply",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
+				},
+			},
+		}
+
+		Expect(k8sClient.Create(context.Background(), wr)).Should(BeNil())
+		wrKey := types.NamespacedName{Namespace: wr.Namespace, Name: wr.Name}
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+
+		expDeployment := &appsv1.Deployment{}
+		step1Key := types.NamespacedName{Namespace: wr.Namespace, Name: "step1"}
+		Expect(k8sClient.Get(ctx, step1Key, expDeployment)).Should(BeNil())
+		expDeployment.Status.Replicas = 1
+		expDeployment.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, expDeployment)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+
+		By("terminate the run when the second step is running")
+		checkRun := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+		terminateWorkflowRun(ctx, checkRun, 1)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Finished).Should(BeTrue())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateTerminated))
+
+		By("restart from the first failed step")
+		checkRun.Annotations = map[string]string{wfTypes.AnnotationWorkflowRunRestart: ""}
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Annotations).ShouldNot(HaveKey(wfTypes.AnnotationWorkflowRunRestart))
+		Expect(checkRun.Status.RestartCount).Should(Equal(1))
+		Expect(checkRun.Status.Finished).Should(BeFalse())
+		Expect(checkRun.Status.Terminated).Should(BeFalse())
+		Expect(len(checkRun.Status.Steps)).Should(Equal(1))
+		Expect(checkRun.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+
+		step2Key := types.NamespacedName{Namespace: wr.Namespace, Name: "step2"}
+		Expect(k8sClient.Get(ctx, step2Key, expDeployment)).Should(BeNil())
+		expDeployment.Status.Replicas = 1
+		expDeployment.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, expDeployment)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSucceeded))
+		Expect(checkRun.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+	})
+
+	It("test suspend, resume and terminate from spec", func() {
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "wr-spec-controls"
+		wr.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "step1",
+					Type: "suspend",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "step2",
+					Type:       "test-apply",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
+				},
+			},
+		}
+
+		Expect(k8sClient.Create(context.Background(), wr)).Should(BeNil())
+		wrKey := types.NamespacedName{Namespace: wr.Namespace, Name: wr.Name}
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		checkRun := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+
+		By("resume the suspend step")
+		checkRun.Spec.ResumeSteps = []v1alpha1.StepResume{{Name: "step1", Approver: "admin"}}
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		cond := checkRun.Status.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunControlConditionType))
+		Expect(string(cond.Reason)).Should(Equal("ResumeStep"))
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Suspend).Should(BeFalse())
+		Expect(checkRun.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(checkRun.Status.Steps[0].Message).Should(Equal("resumed by admin"))
+		expDeployment := &appsv1.Deployment{}
+		step2Key := types.NamespacedName{Namespace: wr.Namespace, Name: "step2"}
+		Expect(k8sClient.Get(ctx, step2Key, expDeployment)).Should(BeNil())
+
+		By("suspend and resume the run")
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		checkRun.Spec.Suspend = true
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Suspend).Should(BeTrue())
+		Expect(checkRun.Status.SuspendState).Should(Equal(wfTypes.SuspendStateManual))
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		checkRun.Spec.Suspend = false
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Suspend).Should(BeFalse())
+		cond = checkRun.Status.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunControlConditionType))
+		Expect(string(cond.Reason)).Should(Equal("Resume"))
+
+		By("terminate the run")
+		checkRun.Spec.Terminate = true
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Terminated).Should(BeTrue())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateTerminated))
+		Expect(checkRun.Status.Steps[1].Reason).Should(Equal(wfTypes.StatusReasonTerminate))
+
+		By("restart the terminated run from the approved suspend step")
+		checkRun.Annotations = map[string]string{wfTypes.AnnotationWorkflowRunRestart: "step1"}
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Spec.Terminate).Should(BeFalse())
+		Expect(checkRun.Spec.ResumeSteps).Should(BeEmpty())
+		Expect(checkRun.Status.Terminated).Should(BeFalse())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		// the restarted suspend step waits for a new approval
+		Expect(checkRun.Status.Terminated).Should(BeFalse())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		Expect(checkRun.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+	})
+
+	It("test resume and reject suspend steps independently", func() {
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "wr-approval"
+		wr.Spec.Mode = &v1alpha1.WorkflowExecuteMode{
+			Steps: v1alpha1.WorkflowModeDAG,
+		}
+		wr.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:    "approve-1",
+					Type:    "suspend",
+					Outputs: v1alpha1.StepOutputs{{Name: "approver", ValueFrom: "approver"}},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "approve-2",
+					Type: "suspend",
+				},
+			},
+		}
+
+		Expect(k8sClient.Create(context.Background(), wr)).Should(BeNil())
+		wrKey := types.NamespacedName{Namespace: wr.Namespace, Name: wr.Name}
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		checkRun := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+
+		By("resume the first suspend step with payload")
+		checkRun.Spec.ResumeSteps = []v1alpha1.StepResume{{Name: "approve-1", Approver: "admin"}}
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Suspend).Should(BeTrue())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		Expect(checkRun.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(checkRun.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+		wfCtx, err := wfContext.LoadContext(k8sClient, wr.Namespace, wr.Name)
+		Expect(err).Should(BeNil())
+		v, err := wfCtx.GetVar("approver")
+		Expect(err).Should(BeNil())
+		approver, err := v.CueValue().String()
+		Expect(err).Should(BeNil())
+		Expect(approver).Should(Equal("admin"))
+
+		By("reject the second suspend step")
+		checkRun.Spec.ResumeSteps = append(checkRun.Spec.ResumeSteps, v1alpha1.StepResume{Name: "approve-2", Reject: true})
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
+		Expect(checkRun.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseFailed))
+		Expect(checkRun.Status.Steps[1].Reason).Should(Equal(wfTypes.StatusReasonReject))
+	})
+
+	It("test concurrency group", func() {
+		newRun := func(name string, policy v1alpha1.ConcurrencyGroupPolicy) *v1alpha1.WorkflowRun {
+			wr := wrTemplate.DeepCopy()
+			wr.Name = name
+			wr.Labels = map[string]string{"env": "prod"}
+			wr.Spec.Concurrency = &v1alpha1.ConcurrencyGroup{
+				Group:  "deploy-{{ .labels.env }}",
+				Policy: policy,
+			}
+			Expect(k8sClient.Create(ctx, wr)).Should(BeNil())
+			return wr
+		}
+		getRun := func(name string) *v1alpha1.WorkflowRun {
+			run := &v1alpha1.WorkflowRun{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, run)).Should(BeNil())
+			return run
+		}
+
+		newRun("wr-concurrency-1", "")
+		tryReconcile(reconciler, "wr-concurrency-1", namespace)
+		Expect(getRun("wr-concurrency-1").Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+
+		By("the run with queue policy is queued")
+		newRun("wr-concurrency-2", v1alpha1.ConcurrencyQueue)
+		tryReconcile(reconciler, "wr-concurrency-2", namespace)
+		Expect(getRun("wr-concurrency-2").Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateQueued))
+
+		By("the run with reject policy is failed")
+		newRun("wr-concurrency-3", v1alpha1.ConcurrencyReject)
+		tryReconcile(reconciler, "wr-concurrency-3", namespace)
+		rejected := getRun("wr-concurrency-3")
+		Expect(rejected.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
+		Expect(rejected.Status.Finished).Should(BeTrue())
+
+		By("the queued run is admitted after the holder finished")
+		terminateWorkflowRun(ctx, getRun("wr-concurrency-1"), 0)
+		tryReconcile(reconciler, "wr-concurrency-1", namespace)
+		Expect(getRun("wr-concurrency-1").Status.Finished).Should(BeTrue())
+		tryReconcile(reconciler, "wr-concurrency-2", namespace)
+		Expect(getRun("wr-concurrency-2").Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+
+		By("the run with cancel-in-progress policy terminates the holder")
+		newRun("wr-concurrency-4", v1alpha1.ConcurrencyCancelInProgress)
+		tryReconcile(reconciler, "wr-concurrency-4", namespace)
+		Expect(getRun("wr-concurrency-4").Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSuspending))
+		Expect(getRun("wr-concurrency-2").Status.Terminated).Should(BeTrue())
+
+		Expect(k8sClient.DeleteAllOf(ctx, &coordinationv1.Lease{}, client.InNamespace(namespace))).Should(Succeed())
+	})
+
 	It("test debug", func() {
 		wr := wrTemplate.DeepCopy()
 		wr.Name = "wr-debug"
@@ -1425,12 +1739,12 @@ var _ = Describe("Test Workflow", func() {
 					Name: "step2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:       "step2-sub",
 						Type:       "test-apply",
 						Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
-					},
+					}},
 				},
 			},
 		}
@@ -1477,6 +1791,77 @@ var _ = Describe("Test Workflow", func() {
 			Namespace: wr.Namespace,
 		}, debugCM)).Should(BeNil())
 	})
+
+	It("test breakpoints", func() {
+		wr := wrTemplate.DeepCopy()
+		wr.Name = "wr-breakpoints"
+		wr.Spec.WorkflowSpec.Steps = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "step1",
+					Type:       "test-apply",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:       "step2",
+					Type:       "test-apply",
+					Properties: &runtime.RawExtension{Raw: []byte(`{"cmd":["sleep","1000"],"image":"busybox"}`)},
+				},
+			},
+		}
+		wr.Annotations = map[string]string{
+			wfTypes.AnnotationWorkflowRunBreakpoints: "step2",
+		}
+		Expect(k8sClient.Create(ctx, wr)).Should(BeNil())
+		wrKey := types.NamespacedName{Namespace: wr.Namespace, Name: wr.Name}
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+
+		expDeployment := &appsv1.Deployment{}
+		step1Key := types.NamespacedName{Namespace: wr.Namespace, Name: "step1"}
+		Expect(k8sClient.Get(ctx, step1Key, expDeployment)).Should(BeNil())
+		expDeployment.Status.Replicas = 1
+		expDeployment.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, expDeployment)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+
+		By("Check the step is paused at the breakpoint")
+		checkRun := &v1alpha1.WorkflowRun{}
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(checkRun.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+		Expect(checkRun.Status.Steps[1].Reason).Should(Equal(wfTypes.StatusReasonBreakpoint))
+		debugCM := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name:      debug.GenerateContextName(wr.Name, "step2"),
+			Namespace: wr.Namespace,
+		}, debugCM)).Should(BeNil())
+		Expect(debugCM.Data[debug.DataKeyBreakpoint]).Should(ContainSubstring("busybox"))
+
+		By("Continue the step with the overridden parameter")
+		checkRun.Annotations[wfTypes.AnnotationWorkflowRunContinue] = `[{"name":"step2","parameter":{"image":"nginx"}}]`
+		Expect(k8sClient.Update(ctx, checkRun)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Annotations).ShouldNot(HaveKey(wfTypes.AnnotationWorkflowRunContinue))
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		step2Key := types.NamespacedName{Namespace: wr.Namespace, Name: "step2"}
+		Expect(k8sClient.Get(ctx, step2Key, expDeployment)).Should(BeNil())
+		Expect(expDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("nginx"))
+		expDeployment.Status.Replicas = 1
+		expDeployment.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, expDeployment)).Should(BeNil())
+		tryReconcile(reconciler, wr.Name, wr.Namespace)
+		Expect(k8sClient.Get(ctx, wrKey, checkRun)).Should(BeNil())
+		Expect(checkRun.Status.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStateSucceeded))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{
+			Name:      debug.GenerateContextName(wr.Name, "step2"),
+			Namespace: wr.Namespace,
+		}, debugCM)).Should(BeNil())
+		Expect(debugCM.Data).ShouldNot(HaveKey(debug.DataKeyBreakpoint))
+		Expect(debugCM.Data[debug.AttemptKey(1)]).Should(ContainSubstring("nginx"))
+	})
 })
 
 func reconcileWithReturn(r *WorkflowRunReconciler, name, ns string) error {
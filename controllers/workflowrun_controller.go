@@ -18,31 +18,41 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlBuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	ctrlEvent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
 	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/concurrency"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/packages"
 	"github.com/kubevela/workflow/pkg/executor"
 	"github.com/kubevela/workflow/pkg/generator"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/sharding"
 	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/utils"
+	"github.com/kubevela/workflow/pkg/wakeup"
 )
 
 // Args args used by controller
@@ -57,12 +67,25 @@ type WorkflowRunReconciler struct {
 	Scheme          *runtime.Scheme
 	PackageDiscover *packages.PackageDiscover
 	Recorder        event.Recorder
+	// Watcher wakes up the workflow runs by the changes of the objects read by the waiting steps, the waiting
+	// steps are polled in the backoff if it's nil
+	Watcher *wakeup.Watcher
+	// Hooks are the factories of the extra hooks of the steps, which run after the built-in hooks
+	Hooks []executor.HookFactory
+	// Sharding filters the workflow runs in the shard of the replica, the cache of the master has the runs in all
+	// the shards
+	Sharding sharding.Args
+	// APIReader reads the workflow runs which may be in the other shards, e.g. the ones in the same concurrency
+	// group, the cached client is used if it's nil
+	APIReader client.Reader
 	Args
 }
 
 var (
 	// ReconcileTimeout timeout for controller to reconcile
 	ReconcileTimeout = time.Minute * 3
+	// QueuedRequeueInterval is the interval to check whether the queued workflow run can be admitted
+	QueuedRequeueInterval = time.Second * 5
 )
 
 // Reconcile reconciles the WorkflowRun object
@@ -87,17 +110,57 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			logCtx.Error(err, "get workflowrun")
 			return ctrl.Result{}, err
 		}
+		r.unwatch(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if !r.Sharding.InShard(run) {
+		// the run is scheduled to another shard
+		r.unwatch(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	if run.DeletionTimestamp != nil && controllerutil.ContainsFinalizer(run, types.FinalizerWorkflowRunArtifacts) {
+		return r.finalize(logCtx, run)
+	}
+	if wfContext.DefaultArtifactStore != nil && run.DeletionTimestamp == nil && !controllerutil.ContainsFinalizer(run, types.FinalizerWorkflowRunArtifacts) {
+		if err := r.patchFinalizer(logCtx, run, controllerutil.AddFinalizer); err != nil {
+			logCtx.Error(err, "add the finalizer of workflowrun")
+			return ctrl.Result{}, err
+		}
+	}
 
 	timeReporter := timeReconcile(run)
 	defer timeReporter()
 
+	if step, ok := run.Annotations[types.AnnotationWorkflowRunRestart]; ok {
+		return r.restart(logCtx, run, step)
+	}
+
 	if run.Status.Finished {
 		logCtx.Info("WorkflowRun is finished, skip reconcile")
+		r.unwatch(req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
 
+	if data, ok := run.Annotations[types.AnnotationWorkflowRunContinue]; ok {
+		return r.continueSteps(logCtx, run, data)
+	}
+
+	controls, err := r.applySpecControls(logCtx, run)
+	if err != nil {
+		logCtx.Error(err, "failed to apply the controls in spec")
+		return ctrl.Result{}, err
+	}
+	if len(controls) > 0 {
+		return r.handleSpecControls(logCtx, run, controls)
+	}
+
+	if run.Spec.Concurrency != nil {
+		if admitted, result, err := r.admitConcurrency(logCtx, run); !admitted {
+			return result, err
+		}
+	}
+
 	instance, err := generator.GenerateWorkflowInstance(ctx, r.Client, run)
 	if err != nil {
 		logCtx.Error(err, "[generate workflow instance]")
@@ -118,7 +181,7 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return r.endWithNegativeCondition(logCtx, run, condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
 	}
 
-	executor := executor.New(instance, r.Client)
+	executor := executor.New(instance, r.Client, executor.WithHooks(r.Hooks...))
 	state, err := executor.ExecuteRunners(logCtx, runners)
 	if err != nil {
 		logCtx.Error(err, "[execute runners]")
@@ -129,6 +192,13 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	isUpdate = isUpdate && instance.Status.Message == ""
 	run.Status = instance.Status
 	run.Status.Phase = state
+	if r.Watcher != nil {
+		if state == v1alpha1.WorkflowStateExecuting {
+			r.Watcher.Watch(req.NamespacedName, executor.GetWatchedObjects())
+		} else {
+			r.Watcher.Unwatch(req.NamespacedName)
+		}
+	}
 	switch state {
 	case v1alpha1.WorkflowStateSuspending:
 		logCtx.Info("Workflow return state=Suspend")
@@ -138,12 +208,12 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateFailed:
 		logCtx.Info("Workflow return state=Failed")
-		r.doWorkflowFinish(run)
+		r.doWorkflowFinish(logCtx, run)
 		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageFailed))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateTerminated:
 		logCtx.Info("Workflow return state=Terminated")
-		r.doWorkflowFinish(run)
+		r.doWorkflowFinish(logCtx, run)
 		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageTerminated))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateExecuting:
@@ -151,7 +221,7 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{RequeueAfter: executor.GetBackoffWaitTime()}, r.patchStatus(logCtx, run, isUpdate)
 	case v1alpha1.WorkflowStateSucceeded:
 		logCtx.Info("Workflow return state=Succeeded")
-		r.doWorkflowFinish(run)
+		r.doWorkflowFinish(logCtx, run)
 		run.Status.SetConditions(condition.ReadyCondition(v1alpha1.WorkflowRunConditionType))
 		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonExecute, v1alpha1.MessageSuccessfully))
 		return ctrl.Result{}, r.patchStatus(logCtx, run, isUpdate)
@@ -165,7 +235,11 @@ func (r *WorkflowRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr)
+	if r.Watcher != nil {
+		builder = builder.Watches(r.Watcher.Source(), &handler.EnqueueRequestForObject{})
+	}
+	return builder.
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: r.ConcurrentReconciles,
 		}).
@@ -176,9 +250,10 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				new := e.ObjectNew.DeepCopyObject().(*v1alpha1.WorkflowRun)
 				old := e.ObjectOld.DeepCopyObject().(*v1alpha1.WorkflowRun)
 
-				// if the workflow is finished, skip the reconcile
+				// if the workflow is finished, skip the reconcile unless it's restarted
 				if new.Status.Finished {
-					return false
+					_, restart := new.Annotations[types.AnnotationWorkflowRunRestart]
+					return restart
 				}
 
 				// filter managedFields changes
@@ -195,6 +270,7 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 				// ignore the changes in step status
 				old.Status.Steps = new.Status.Steps
+				old.Status.ExitHandlers = new.Status.ExitHandlers
 
 				return !reflect.DeepEqual(old, new)
 			},
@@ -202,10 +278,46 @@ func (r *WorkflowRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				return true
 			},
 		}).
-		For(&v1alpha1.WorkflowRun{}).
+		For(&v1alpha1.WorkflowRun{}, ctrlBuilder.WithPredicates(r.Sharding.ShardPredicate())).
 		Complete(r)
 }
 
+// runReader returns the reader of the workflow runs which may be in the other shards
+func (r *WorkflowRunReconciler) runReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func (r *WorkflowRunReconciler) unwatch(run ktypes.NamespacedName) {
+	if r.Watcher != nil {
+		r.Watcher.Unwatch(run)
+	}
+}
+
+// finalize deletes the artifacts of the deleted run before its context is garbage collected and removes the finalizer
+func (r *WorkflowRunReconciler) finalize(ctx monitorContext.Context, run *v1alpha1.WorkflowRun) (ctrl.Result, error) {
+	r.unwatch(client.ObjectKeyFromObject(run))
+	if err := wfContext.DeleteArtifacts(ctx, r.Client, run.Namespace, run.Name); err != nil {
+		ctx.Error(err, "delete artifacts of workflowrun")
+		return ctrl.Result{}, err
+	}
+	if err := r.patchFinalizer(ctx, run, controllerutil.RemoveFinalizer); err != nil {
+		ctx.Error(err, "remove the finalizer of workflowrun")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// patchFinalizer adds or removes the artifacts finalizer of the run, the patch fails on conflict so that the
+// finalizers of the others are kept.
+func (r *WorkflowRunReconciler) patchFinalizer(ctx context.Context, run *v1alpha1.WorkflowRun, op func(client.Object, string)) error {
+	base := run.DeepCopy()
+	op(run, types.FinalizerWorkflowRunArtifacts)
+	return r.Patch(ctx, run, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+}
+
 func (r *WorkflowRunReconciler) endWithNegativeCondition(ctx context.Context, wr *v1alpha1.WorkflowRun, condition condition.Condition) (ctrl.Result, error) {
 	wr.SetConditions(condition)
 	if err := r.patchStatus(ctx, wr, false); err != nil {
@@ -229,12 +341,236 @@ func (r *WorkflowRunReconciler) patchStatus(ctx context.Context, wr *v1alpha1.Wo
 	return nil
 }
 
-func (r *WorkflowRunReconciler) doWorkflowFinish(wr *v1alpha1.WorkflowRun) {
+func (r *WorkflowRunReconciler) doWorkflowFinish(ctx monitorContext.Context, wr *v1alpha1.WorkflowRun) {
 	wr.Status.Finished = true
 	wr.Status.EndTime = metav1.Now()
 	metrics.WorkflowRunFinishedTimeHistogram.WithLabelValues(string(wr.Status.Phase)).Observe(wr.Status.EndTime.Sub(wr.Status.StartTime.Time).Seconds())
 	executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", wr.Name, wr.Namespace))
 	wfContext.CleanupMemoryStore(wr.Name, wr.Namespace)
+	// the child workflow runs of the sub-workflow steps are terminated with the parent, e.g. it's terminated or
+	// timed out
+	if err := utils.TerminateSubWorkflows(ctx, r.Client, wr); err != nil {
+		ctx.Error(err, "terminate sub workflow runs")
+	}
+	if wr.Spec.Concurrency != nil {
+		// the lock is taken over by the queued runs even if it fails to be released here
+		if lock, err := concurrency.NewLock(r.Client, r.runReader(), wr); err == nil {
+			if err := lock.Release(ctx); err != nil {
+				ctx.Error(err, "release concurrency group")
+			}
+		}
+	}
+}
+
+// admitConcurrency checks whether the workflow run can hold its concurrency group and start to execute,
+// the run is queued, rejected or terminates the holder according to the policy if the group is held by another run.
+func (r *WorkflowRunReconciler) admitConcurrency(ctx monitorContext.Context, run *v1alpha1.WorkflowRun) (bool, ctrl.Result, error) {
+	lock, err := concurrency.NewLock(r.Client, r.runReader(), run)
+	if err != nil {
+		ctx.Error(err, "[concurrency group]")
+		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonConcurrency, errors.WithMessage(err, v1alpha1.MessageFailedConcurrency)))
+		result, err := r.endWithNegativeCondition(ctx, run, condition.ErrorCondition(v1alpha1.WorkflowRunConditionType, err))
+		return false, result, err
+	}
+	holder, acquired, err := lock.Acquire(ctx, false)
+	if err != nil {
+		ctx.Error(err, "[acquire concurrency group]")
+		return false, ctrl.Result{}, err
+	}
+	if acquired {
+		return true, ctrl.Result{}, nil
+	}
+	if holder != "" {
+		switch run.Spec.Concurrency.Policy {
+		case v1alpha1.ConcurrencyReject:
+			ctx.Info("WorkflowRun is rejected by the concurrency group", "group", lock.Group(), "holder", holder)
+			run.Status.Phase = v1alpha1.WorkflowStateFailed
+			run.Status.Message = fmt.Sprintf("rejected since the concurrency group %s is held by %s", lock.Group(), holder)
+			run.Status.Finished = true
+			run.Status.EndTime = metav1.Now()
+			r.Recorder.Event(run, event.Warning(v1alpha1.ReasonConcurrency, errors.New(run.Status.Message)))
+			return false, ctrl.Result{}, r.patchStatus(ctx, run, false)
+		case v1alpha1.ConcurrencyCancelInProgress:
+			ctx.Info("Terminate the WorkflowRun which holds the concurrency group", "group", lock.Group(), "holder", holder)
+			running := &v1alpha1.WorkflowRun{}
+			if err := r.runReader().Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: holder}, running); err == nil {
+				if err := utils.TerminateWorkflow(ctx, r.Client, running); err != nil {
+					ctx.Error(err, "[terminate the holder of concurrency group]")
+					return false, ctrl.Result{}, err
+				}
+				r.Recorder.Event(running, event.Normal(v1alpha1.ReasonConcurrency, fmt.Sprintf("Terminated by WorkflowRun %s in the concurrency group %s", run.Name, lock.Group())))
+			} else if !kerrors.IsNotFound(err) {
+				return false, ctrl.Result{}, err
+			}
+			if _, acquired, err = lock.Acquire(ctx, true); err != nil || acquired {
+				return acquired, ctrl.Result{}, err
+			}
+		}
+	}
+	ctx.Info("WorkflowRun is queued by the concurrency group", "group", lock.Group(), "holder", holder)
+	run.Status.Phase = v1alpha1.WorkflowStateQueued
+	run.Status.Message = fmt.Sprintf("waiting for the concurrency group %s", lock.Group())
+	return false, ctrl.Result{RequeueAfter: QueuedRequeueInterval}, r.patchStatus(ctx, run, false)
+}
+
+// restart restarts the workflow run from the step in the restart annotation, the annotation is removed
+// afterwards so that the run is restarted only once. The controls in the spec applied before are cleared together.
+func (r *WorkflowRunReconciler) restart(ctx monitorContext.Context, run *v1alpha1.WorkflowRun, step string) (ctrl.Result, error) {
+	restarted := false
+	if err := utils.RestartWorkflowFromStep(ctx, r.Client, run, step); err != nil {
+		ctx.Error(err, "[restart]")
+		// retry the restart if it fails to get or update the resources
+		if _, ok := errors.Cause(err).(kerrors.APIStatus); ok {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonRestart, errors.WithMessage(err, v1alpha1.MessageFailedRestart)))
+	} else {
+		ctx.Info("Restart WorkflowRun", "step", step, "restartCount", run.Status.RestartCount)
+		executor.StepStatusCache.Delete(fmt.Sprintf("%s-%s", run.Name, run.Namespace))
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonRestart, fmt.Sprintf("WorkflowRun restarted, restart count: %d", run.Status.RestartCount)))
+		restarted = true
+	}
+	patch := client.MergeFrom(run.DeepCopy())
+	delete(run.Annotations, types.AnnotationWorkflowRunRestart)
+	if restarted {
+		utils.ClearSpecControls(run)
+	}
+	if err := r.Patch(ctx, run, patch); err != nil {
+		return ctrl.Result{}, errors.WithMessage(err, "failed to remove the restart annotation")
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// continueSteps stores the decisions in the continue annotation for the steps paused at the breakpoints, the
+// annotation is removed after the decisions are stored so that they're applied once.
+func (r *WorkflowRunReconciler) continueSteps(ctx monitorContext.Context, run *v1alpha1.WorkflowRun, data string) (ctrl.Result, error) {
+	var decisions []types.StepContinue
+	if err := json.Unmarshal([]byte(data), &decisions); err != nil {
+		ctx.Error(err, "[continue]")
+		r.Recorder.Event(run, event.Warning(v1alpha1.ReasonContinue, errors.WithMessage(err, v1alpha1.MessageFailedContinue)))
+	}
+	for _, decision := range decisions {
+		stored, err := utils.ContinueWorkflowStep(ctx, r.Client, run, decision)
+		if err != nil {
+			ctx.Error(err, "[continue]", "step", decision.Name)
+			return ctrl.Result{}, err
+		}
+		if !stored {
+			ctx.Info("Skip the decision of the step which is not paused at the breakpoint", "step", decision.Name)
+			continue
+		}
+		action := "continued"
+		if decision.Skip {
+			action = "skipped"
+		}
+		ctx.Info("Continue the step at the breakpoint", "step", decision.Name, "skip", decision.Skip)
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonContinue, fmt.Sprintf("Step %s %s at the breakpoint", decision.Name, action)))
+	}
+	patch := client.MergeFrom(run.DeepCopy())
+	delete(run.Annotations, types.AnnotationWorkflowRunContinue)
+	if err := r.Patch(ctx, run, patch); err != nil {
+		return ctrl.Result{}, errors.WithMessage(err, "failed to remove the continue annotation")
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// specControl is a control in the spec which is applied to the status of the workflow run
+type specControl struct {
+	field   string
+	action  string
+	message string
+}
+
+// applySpecControls translates the terminate, resume and suspend controls in the spec into the status of the workflow
+// run, the controls are level triggered so that the status follows the spec. The decisions of the suspend steps are
+// stored in the workflow context for the steps to consume. It returns the applied controls.
+func (r *WorkflowRunReconciler) applySpecControls(ctx context.Context, run *v1alpha1.WorkflowRun) ([]specControl, error) {
+	status := &run.Status
+	if run.Spec.Terminate {
+		if status.Terminated {
+			return nil, nil
+		}
+		utils.TerminateWorkflowStatus(status)
+		return []specControl{{field: "terminate", action: "Terminate", message: "terminated"}}, nil
+	}
+	var controls []specControl
+	for _, resume := range run.Spec.ResumeSteps {
+		stored, err := utils.ResumeWorkflowStep(ctx, r.Client, run, resume)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to resume step %s", resume.Name)
+		}
+		if !stored {
+			continue
+		}
+		c := specControl{field: "resumeSteps", action: "ResumeStep", message: fmt.Sprintf("step %s resumed", resume.Name)}
+		if resume.Reject {
+			c.action, c.message = "RejectStep", fmt.Sprintf("step %s rejected", resume.Name)
+		}
+		controls = append(controls, c)
+	}
+	switch {
+	case run.Spec.Suspend && status.SuspendState != types.SuspendStateManual:
+		utils.SuspendWorkflowStatus(status)
+		controls = append(controls, specControl{field: "suspend", action: "Suspend", message: "suspended"})
+	case !run.Spec.Suspend && status.SuspendState == types.SuspendStateManual:
+		utils.ResumeWorkflowStatus(status)
+		controls = append(controls, specControl{field: "suspend", action: "Resume", message: "resumed"})
+	}
+	return controls, nil
+}
+
+// handleSpecControls records who applied the controls and when in the condition and events, and updates the status.
+func (r *WorkflowRunReconciler) handleSpecControls(ctx monitorContext.Context, run *v1alpha1.WorkflowRun, controls []specControl) (ctrl.Result, error) {
+	for _, c := range controls {
+		manager, at := getSpecFieldManager(run, c.field)
+		// the user stamped by the webhook is preferred, the field manager may be shared by the users of a client
+		if user := run.Annotations[types.AnnotationWorkflowRunControlUser]; user != "" {
+			manager = user
+		}
+		message := fmt.Sprintf("WorkflowRun %s by %s", c.message, manager)
+		ctx.Info("Apply the control in spec", "action", c.action, "manager", manager)
+		r.Recorder.Event(run, event.Normal(v1alpha1.ReasonControl, message))
+		run.Status.SetConditions(condition.Condition{
+			Type:               condition.ConditionType(v1alpha1.WorkflowRunControlConditionType),
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: at,
+			Reason:             condition.ConditionReason(c.action),
+			Message:            message,
+		})
+	}
+	// update the status with the resource version to avoid overriding the changes from the executor
+	if err := r.Status().Update(ctx, run); err != nil {
+		return ctrl.Result{}, errors.WithMessage(err, "failed to update workflowrun status")
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// getSpecFieldManager returns the manager which updated the field in the spec lastly and the time of the update,
+// the manager which updated the spec lastly is returned if the field is unset.
+func getSpecFieldManager(run *v1alpha1.WorkflowRun, field string) (string, metav1.Time) {
+	manager, at := "unknown", metav1.Now()
+	var latest, latestField *metav1.Time
+	for _, entry := range run.ManagedFields {
+		if entry.FieldsV1 == nil || entry.Time == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		spec, ok := fields["f:spec"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := spec["f:"+field]; ok {
+			if latestField == nil || latestField.Before(entry.Time) {
+				latestField, manager, at = entry.Time, entry.Manager, *entry.Time
+			}
+		} else if latestField == nil && (latest == nil || latest.Before(entry.Time)) {
+			latest, manager, at = entry.Time, entry.Manager, *entry.Time
+		}
+	}
+	return manager, at
 }
 
 func timeReconcile(wr *v1alpha1.WorkflowRun) func() {
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	chunkSize := ChunkSize
+	ChunkSize = 4
+	defer func() {
+		ChunkSize = chunkSize
+	}()
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	store := NewConfigMapStore(cli)
+	ref := wfContext.ArtifactRef{Namespace: "default", Name: "workflow-test-context-abc"}
+
+	_, err := store.Get(ctx, ref)
+	r.True(kerrors.IsNotFound(err))
+
+	r.NoError(store.Put(ctx, ref, []byte("0123456789")))
+	data, err := store.Get(ctx, ref)
+	r.NoError(err)
+	r.Equal("0123456789", string(data))
+	cms := &corev1.ConfigMapList{}
+	r.NoError(cli.List(ctx, cms, client.MatchingLabels{LabelArtifactName: ref.Name}))
+	r.Equal(3, len(cms.Items))
+
+	// the chunks left by the larger artifact are deleted
+	r.NoError(store.Put(ctx, ref, []byte("abc")))
+	data, err = store.Get(ctx, ref)
+	r.NoError(err)
+	r.Equal("abc", string(data))
+	r.NoError(cli.List(ctx, cms, client.MatchingLabels{LabelArtifactName: ref.Name}))
+	r.Equal(1, len(cms.Items))
+
+	r.NoError(store.Put(ctx, ref, []byte("0123456789")))
+	r.NoError(store.Delete(ctx, ref))
+	r.NoError(cli.List(ctx, cms, client.MatchingLabels{LabelArtifactName: ref.Name}))
+	r.Equal(0, len(cms.Items))
+	r.NoError(store.Delete(ctx, ref))
+}
+
+func TestFileStore(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+	ref := wfContext.ArtifactRef{Namespace: "default", Name: "workflow-test-context-abc"}
+
+	_, err := store.Get(ctx, ref)
+	r.Error(err)
+
+	r.NoError(store.Put(ctx, ref, []byte("0123456789")))
+	r.NoError(store.Put(ctx, ref, []byte("abc")))
+	data, err := store.Get(ctx, ref)
+	r.NoError(err)
+	r.Equal("abc", string(data))
+
+	r.NoError(store.Delete(ctx, ref))
+	_, err = store.Get(ctx, ref)
+	r.Error(err)
+	r.NoError(store.Delete(ctx, ref))
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+const (
+	// AnnotationArtifactChunks is the annotation of the number of the chunks on the first chunk of the artifact
+	AnnotationArtifactChunks = "workflow.oam.dev/artifact-chunks"
+	// LabelArtifactName is the label of the artifact name on the chunks
+	LabelArtifactName = "workflow.oam.dev/artifact"
+	// ConfigMapKeyArtifact is the key of the chunk data in the ConfigMap
+	ConfigMapKeyArtifact = "data"
+)
+
+// ChunkSize is the max size in bytes of a chunk, it's kept well below the size limit of the object in etcd
+var ChunkSize = 512 * 1024
+
+type configMapStore struct {
+	cli client.Client
+}
+
+// NewConfigMapStore returns the artifact store which splits the artifact into a set of ConfigMaps, the ConfigMaps
+// are owned by the owners of the workflow context so that they are deleted together with the workflow run.
+func NewConfigMapStore(cli client.Client) wfContext.ArtifactStore {
+	return &configMapStore{cli: cli}
+}
+
+// Put writes the artifact into the chunks, the chunks left by the larger artifact before are deleted
+func (s *configMapStore) Put(ctx context.Context, ref wfContext.ArtifactRef, data []byte) error {
+	previous, err := s.getChunks(ctx, ref)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	chunks := 0
+	for offset := 0; offset < len(data) || chunks == 0; offset += ChunkSize {
+		end := offset + ChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		cm := &corev1.ConfigMap{}
+		cm.Name = chunkName(ref.Name, chunks)
+		cm.Namespace = ref.Namespace
+		cm.Labels = map[string]string{LabelArtifactName: ref.Name}
+		cm.SetOwnerReferences(ref.Owners)
+		cm.BinaryData = map[string][]byte{ConfigMapKeyArtifact: data[offset:end]}
+		chunks++
+		if offset == 0 {
+			cm.Annotations = map[string]string{AnnotationArtifactChunks: strconv.Itoa((len(data) + ChunkSize - 1) / ChunkSize)}
+		}
+		if err := s.apply(ctx, cm); err != nil {
+			return errors.WithMessagef(err, "write chunk %s", cm.Name)
+		}
+	}
+	for i := chunks; i < previous; i++ {
+		cm := &corev1.ConfigMap{}
+		cm.Name = chunkName(ref.Name, i)
+		cm.Namespace = ref.Namespace
+		if err := s.cli.Delete(ctx, cm); err != nil && !kerrors.IsNotFound(err) {
+			return errors.WithMessagef(err, "delete chunk %s", cm.Name)
+		}
+	}
+	return nil
+}
+
+// Get reads the artifact from the chunks
+func (s *configMapStore) Get(ctx context.Context, ref wfContext.ArtifactRef) ([]byte, error) {
+	chunks, err := s.getChunks(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for i := 0; i < chunks; i++ {
+		cm := &corev1.ConfigMap{}
+		if err := s.cli.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: chunkName(ref.Name, i)}, cm); err != nil {
+			return nil, errors.WithMessagef(err, "read chunk %d", i)
+		}
+		buf.Write(cm.BinaryData[ConfigMapKeyArtifact])
+	}
+	return buf.Bytes(), nil
+}
+
+// Delete deletes the chunks of the artifact
+func (s *configMapStore) Delete(ctx context.Context, ref wfContext.ArtifactRef) error {
+	chunks, err := s.getChunks(ctx, ref)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	// the first chunk is deleted at last, so that the rest are deleted again on retry
+	for i := chunks - 1; i >= 0; i-- {
+		cm := &corev1.ConfigMap{}
+		cm.Name = chunkName(ref.Name, i)
+		cm.Namespace = ref.Namespace
+		if err := s.cli.Delete(ctx, cm); err != nil && !kerrors.IsNotFound(err) {
+			return errors.WithMessagef(err, "delete chunk %s", cm.Name)
+		}
+	}
+	return nil
+}
+
+func (s *configMapStore) getChunks(ctx context.Context, ref wfContext.ArtifactRef) (int, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.cli.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: chunkName(ref.Name, 0)}, cm); err != nil {
+		return 0, err
+	}
+	chunks, err := strconv.Atoi(cm.Annotations[AnnotationArtifactChunks])
+	if err != nil {
+		return 0, errors.WithMessagef(err, "invalid chunks of artifact %s", ref.Name)
+	}
+	return chunks, nil
+}
+
+func (s *configMapStore) apply(ctx context.Context, cm *corev1.ConfigMap) error {
+	existing := &corev1.ConfigMap{}
+	if err := s.cli.Get(ctx, client.ObjectKeyFromObject(cm), existing); err != nil {
+		if kerrors.IsNotFound(err) {
+			return s.cli.Create(ctx, cm)
+		}
+		return err
+	}
+	cm.ResourceVersion = existing.ResourceVersion
+	return s.cli.Update(ctx, cm)
+}
+
+func chunkName(name string, index int) string {
+	return fmt.Sprintf("%s-%d", name, index)
+}
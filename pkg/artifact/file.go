@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore returns the artifact store in the directory, e.g. the mount path of a PVC. The artifacts are
+// stored in the sub directories of the namespaces, they are deleted when they are overwritten or the workflow runs
+// are deleted by the controller.
+func NewFileStore(dir string) wfContext.ArtifactStore {
+	return &fileStore{dir: dir}
+}
+
+// Put writes the artifact into the file, the file is replaced atomically
+func (s *fileStore) Put(_ context.Context, ref wfContext.ArtifactRef, data []byte) error {
+	path := s.path(ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads the artifact from the file
+func (s *fileStore) Get(_ context.Context, ref wfContext.ArtifactRef) ([]byte, error) {
+	return os.ReadFile(s.path(ref))
+}
+
+// Delete deletes the file of the artifact
+func (s *fileStore) Delete(_ context.Context, ref wfContext.ArtifactRef) error {
+	if err := os.Remove(s.path(ref)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) path(ref wfContext.ArtifactRef) string {
+	return filepath.Join(s.dir, filepath.Base(ref.Namespace), filepath.Base(ref.Name))
+}
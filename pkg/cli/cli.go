@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	goflag "flag"
+	"io"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+// NewCommand creates the root command of the workflow cli
+func NewCommand(out io.Writer) *cobra.Command {
+	var logDebug bool
+	f := &factory{}
+	cmd := &cobra.Command{
+		Use:           "workflow",
+		Short:         "Run and operate the workflows",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if !logDebug {
+				discardLogs()
+			}
+		},
+	}
+	cmd.SetOut(out)
+	cmd.PersistentFlags().BoolVar(&logDebug, "log-debug", false, "Print the logs of the workflow engine")
+	cmd.PersistentFlags().StringVarP(&f.namespace, "namespace", "n", "default", "The namespace of the workflow runs")
+	// the kubeconfig flag is registered by the controller runtime
+	if kubeconfig := goflag.CommandLine.Lookup("kubeconfig"); kubeconfig != nil {
+		cmd.PersistentFlags().AddGoFlag(kubeconfig)
+	}
+	cmd.AddCommand(
+		newRunCommand(f, out),
+		newListCommand(f, out),
+		newStatusCommand(f, out),
+		newSuspendCommand(f, out),
+		newResumeCommand(f, out),
+		newTerminateCommand(f, out),
+		newRestartCommand(f, out),
+		newContextCommand(f, out),
+		newLogsCommand(f, out),
+		newDebugCommand(f, out),
+	)
+	return cmd
+}
+
+// discardLogs discards the logs of the workflow engine, which are written to the stderr by default
+func discardLogs() {
+	fs := goflag.NewFlagSet("klog", goflag.ContinueOnError)
+	klog.InitFlags(fs)
+	_ = fs.Set("logtostderr", "false")
+	_ = fs.Set("alsologtostderr", "false")
+	_ = fs.Set("stderrthreshold", "FATAL")
+	klog.SetOutput(io.Discard)
+}
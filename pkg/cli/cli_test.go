@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/debug"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func newTestCommand(t *testing.T, objs ...client.Object) (*cobra.Command, *bytes.Buffer, client.Client) {
+	scheme, err := newScheme()
+	require.NoError(t, err)
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	out := &bytes.Buffer{}
+	f := &factory{namespace: "default", client: cli}
+	cmd := &cobra.Command{Use: "workflow"}
+	cmd.AddCommand(
+		newListCommand(f, out),
+		newStatusCommand(f, out),
+		newSuspendCommand(f, out),
+		newResumeCommand(f, out),
+		newTerminateCommand(f, out),
+		newRestartCommand(f, out),
+		newContextCommand(f, out),
+		newLogsCommand(f, out),
+		newDebugCommand(f, out),
+	)
+	cmd.SilenceUsage, cmd.SilenceErrors = true, true
+	return cmd, out, cli
+}
+
+func execute(cmd *cobra.Command, out *bytes.Buffer, args ...string) (string, error) {
+	out.Reset()
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+func TestListAndStatus(t *testing.T) {
+	r := require.New(t)
+	current := time.Date(2022, 10, 1, 0, 10, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+	at := func(minute, second int) metav1.Time {
+		return metav1.NewTime(time.Date(2022, 10, 1, 0, minute, second, 0, time.UTC))
+	}
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:     v1alpha1.WorkflowStateExecuting,
+			StartTime: at(5, 0),
+			Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus: v1alpha1.StepStatus{ID: "s1", Name: "apply", Type: "apply-object", Phase: v1alpha1.WorkflowStepPhaseSucceeded, FirstExecuteTime: at(5, 0), LastExecuteTime: at(5, 3)},
+			}, {
+				StepStatus: v1alpha1.StepStatus{ID: "s2", Name: "group", Type: "step-group", Phase: v1alpha1.WorkflowStepPhaseRunning, FirstExecuteTime: at(5, 3), LastExecuteTime: at(5, 3)},
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{{
+					StepStatus: v1alpha1.StepStatus{ID: "s3", Name: "wait", Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning, Reason: "Suspend", Message: "waiting for approval", FirstExecuteTime: at(5, 3), LastExecuteTime: at(5, 3)},
+				}, {
+					StepStatus: v1alpha1.StepStatus{ID: "s4", Name: "notify", Type: "notification", Phase: v1alpha1.WorkflowStepPhasePending},
+				}},
+			}},
+		},
+	}
+	finished := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "finished", Namespace: "other"},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:     v1alpha1.WorkflowStateSucceeded,
+			Finished:  true,
+			StartTime: at(0, 0),
+			EndTime:   at(2, 0),
+		},
+	}
+	cmd, out, _ := newTestCommand(t, run, finished)
+
+	s, err := execute(cmd, out, "list")
+	r.NoError(err)
+	r.Equal("NAME      PHASE       STARTED   DURATION\nrunning   executing   5m ago    5m\n", s)
+	s, err = execute(cmd, out, "list", "-A")
+	r.NoError(err)
+	r.Contains(s, "NAMESPACE   NAME")
+	r.Contains(s, "other       finished   succeeded   10m ago   2m")
+
+	s, err = execute(cmd, out, "status", "running")
+	r.NoError(err)
+	r.Equal(`Name:      running
+Namespace: default
+Phase:     executing
+Started:   2022-10-01T00:05:00Z
+Duration:  5m
+Steps:
+├── apply [apply-object] succeeded 3s
+└── group [step-group] running 4m57s
+    ├── wait [suspend] running (Suspend) 4m57s: waiting for approval
+    └── notify [notification] pending
+`, s)
+	_, err = execute(cmd, out, "status", "not-found")
+	r.Error(err)
+}
+
+func TestOperations(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cmd, out, cli := newTestCommand(t,
+		&v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"}},
+		&v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "finished", Namespace: "default"}, Status: v1alpha1.WorkflowRunStatus{Finished: true}},
+	)
+	get := func() *v1alpha1.WorkflowRun {
+		run := &v1alpha1.WorkflowRun{}
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run"}, run))
+		return run
+	}
+
+	_, err := execute(cmd, out, "resume", "run")
+	r.Error(err)
+	s, err := execute(cmd, out, "suspend", "run")
+	r.NoError(err)
+	r.Equal("WorkflowRun default/run suspended\n", s)
+	r.True(get().Spec.Suspend)
+	_, err = execute(cmd, out, "resume", "run")
+	r.NoError(err)
+	r.False(get().Spec.Suspend)
+
+	s, err = execute(cmd, out, "resume", "run", "--step", "approve", "--reject", "--comment", "not now")
+	r.NoError(err)
+	r.Equal("WorkflowRun default/run step approve rejected\n", s)
+	r.Equal([]v1alpha1.StepResume{{Name: "approve", Reject: true, Comment: "not now"}}, get().Spec.ResumeSteps)
+
+	_, err = execute(cmd, out, "restart", "run", "--step", "apply")
+	r.NoError(err)
+	r.Equal("apply", get().Annotations[types.AnnotationWorkflowRunRestart])
+
+	_, err = execute(cmd, out, "terminate", "run")
+	r.NoError(err)
+	r.True(get().Spec.Terminate)
+	_, err = execute(cmd, out, "terminate", "finished")
+	r.Error(err)
+	_, err = execute(cmd, out, "suspend", "not-found")
+	r.Error(err)
+}
+
+func TestContextAndLogs(t *testing.T) {
+	r := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "line 1\nline 2\n")
+	}))
+	defer server.Close()
+	cmd, out, _ := newTestCommand(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "workflow-run-context", Namespace: "default"},
+		Data: map[string]string{
+			"vars":      `{"message": "hello", "outputs": {"code": 200}}`,
+			"logConfig": fmt.Sprintf(`{"request": {"source": {"url": %q}}, "print": {"data": true}}`, server.URL),
+		},
+	})
+
+	s, err := execute(cmd, out, "context", "run")
+	r.NoError(err)
+	r.Contains(s, `message: "hello"`)
+	r.Contains(s, "code: 200")
+	s, err = execute(cmd, out, "context", "run", "outputs.code")
+	r.NoError(err)
+	r.Equal("200\n", s)
+	_, err = execute(cmd, out, "context", "not-found")
+	r.Error(err)
+
+	s, err = execute(cmd, out, "logs", "run", "--step", "request")
+	r.NoError(err)
+	r.Equal("line 1\nline 2\n", s)
+	s, err = execute(cmd, out, "logs", "run", "--step", "print")
+	r.NoError(err)
+	r.Contains(s, "logs of the workflow controller")
+	_, err = execute(cmd, out, "logs", "run", "--step", "not-found")
+	r.Error(err)
+}
+
+func TestDebug(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	paused := v1alpha1.StepStatus{ID: "apply-id", Name: "apply", Type: "apply-object", Phase: v1alpha1.WorkflowStepPhaseRunning, Reason: types.StatusReasonBreakpoint}
+	cmd, out, cli := newTestCommand(t,
+		&v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+			Status: v1alpha1.WorkflowRunStatus{
+				Steps: []v1alpha1.WorkflowStepStatus{{StepStatus: paused}},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: debug.GenerateContextName("run", "apply"), Namespace: "default"},
+			Data: map[string]string{
+				debug.DataKeyDebug:      "parameter: replicas: 2\n",
+				debug.AttemptKey(1):     "parameter: replicas: 1\n",
+				debug.AttemptKey(2):     "parameter: replicas: 2\n",
+				debug.DataKeyBreakpoint: "parameter: replicas: 3\n",
+			},
+		},
+	)
+	get := func() *v1alpha1.WorkflowRun {
+		run := &v1alpha1.WorkflowRun{}
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run"}, run))
+		return run
+	}
+
+	s, err := execute(cmd, out, "debug", "break", "run", "apply", "deploy")
+	r.NoError(err)
+	r.Equal("WorkflowRun default/run breakpoints set at apply, deploy\n", s)
+	_, err = execute(cmd, out, "debug", "break", "run", "deploy", "check")
+	r.NoError(err)
+	r.Equal("apply,deploy,check", get().Annotations[types.AnnotationWorkflowRunBreakpoints])
+	_, err = execute(cmd, out, "debug", "break", "run")
+	r.Error(err)
+
+	s, err = execute(cmd, out, "debug", "show", "run", "apply")
+	r.NoError(err)
+	r.Equal("parameter: replicas: 3\n", s)
+	s, err = execute(cmd, out, "debug", "show", "run", "apply", "--attempt", "1")
+	r.NoError(err)
+	r.Equal("parameter: replicas: 1\n", s)
+	_, err = execute(cmd, out, "debug", "show", "run", "apply", "--attempt", "3")
+	r.Error(err)
+	r.Contains(err.Error(), "[1, 2]")
+	_, err = execute(cmd, out, "debug", "show", "run", "deploy")
+	r.Error(err)
+
+	s, err = execute(cmd, out, "debug", "continue", "run", "apply-id", "--skip")
+	r.NoError(err)
+	r.Equal("WorkflowRun default/run step apply-id skipped\n", s)
+	s, err = execute(cmd, out, "debug", "continue", "run", "apply", "--skip=false", "--parameter", `{"replicas":4}`)
+	r.NoError(err)
+	r.Equal("WorkflowRun default/run step apply continued\n", s)
+	var decisions []types.StepContinue
+	r.NoError(json.Unmarshal([]byte(get().Annotations[types.AnnotationWorkflowRunContinue]), &decisions))
+	r.Equal([]types.StepContinue{
+		{Name: "apply-id", Skip: true},
+		{Name: "apply", Parameter: &runtime.RawExtension{Raw: []byte(`{"replicas":4}`)}},
+	}, decisions)
+	_, err = execute(cmd, out, "debug", "continue", "run", "deploy")
+	r.Error(err)
+	_, err = execute(cmd, out, "debug", "continue", "run", "apply", "--parameter", "[1]")
+	r.Error(err)
+
+	_, err = execute(cmd, out, "debug", "break", "run", "--clear")
+	r.NoError(err)
+	_, ok := get().Annotations[types.AnnotationWorkflowRunBreakpoints]
+	r.False(ok)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/kubevela/workflow/pkg/utils"
+)
+
+// newContextCommand creates the command to dump the variables in the context of a workflow run
+func newContextCommand(f *factory, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:     "context NAME [PATH]",
+		Short:   "Dump the variables in the context of a workflow run",
+		Example: "  workflow context my-run\n  workflow context my-run outputs.message",
+		Args:    cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := f.Client()
+			if err != nil {
+				return err
+			}
+			var paths []string
+			if len(args) > 1 {
+				paths = append(paths, args[1])
+			}
+			v, err := utils.GetDataFromContext(cmd.Context(), cli, args[0], f.namespace, paths...)
+			if err != nil {
+				return errors.WithMessagef(err, "get the context of workflow run %s/%s", f.namespace, args[0])
+			}
+			s, err := v.String()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, strings.TrimSpace(s))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/debug"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// newDebugCommand creates the commands to pause the steps of a workflow run at the breakpoints, inspect and continue
+// them
+func newDebugCommand(f *factory, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Pause the steps of a workflow run at the breakpoints, inspect and continue them",
+	}
+	cmd.AddCommand(
+		newBreakCommand(f, out),
+		newShowCommand(f, out),
+		newContinueCommand(f, out),
+	)
+	return cmd
+}
+
+// newBreakCommand creates the command to set the breakpoints of a workflow run
+func newBreakCommand(f *factory, out io.Writer) *cobra.Command {
+	var all, clearBreakpoints bool
+	cmd := &cobra.Command{
+		Use:   "break NAME [STEP...]",
+		Short: "Set the breakpoints at the steps of a workflow run, the steps are paused before they run",
+		Long: "Set the breakpoints at the steps of a workflow run, the steps are paused before they run.\n" +
+			"The paused steps run without pausing once the breakpoints are cleared.",
+		Example: "  workflow debug break my-run apply deploy\n  workflow debug break my-run --all\n  workflow debug break my-run --clear",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			steps := args[1:]
+			if all {
+				steps = []string{types.BreakpointAll}
+			}
+			if !clearBreakpoints && len(steps) == 0 {
+				return errors.New("specify the steps or --all to set the breakpoints")
+			}
+			action := "breakpoints cleared"
+			if !clearBreakpoints {
+				action = fmt.Sprintf("breakpoints set at %s", strings.Join(steps, ", "))
+			}
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], action, func(run *v1alpha1.WorkflowRun) error {
+				if run.Status.Finished {
+					return errors.Errorf("workflow run %s is finished", run.Name)
+				}
+				if clearBreakpoints {
+					delete(run.Annotations, types.AnnotationWorkflowRunBreakpoints)
+					return nil
+				}
+				if run.Annotations == nil {
+					run.Annotations = map[string]string{}
+				}
+				run.Annotations[types.AnnotationWorkflowRunBreakpoints] = mergeBreakpoints(run.Annotations[types.AnnotationWorkflowRunBreakpoints], steps)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "Set the breakpoints at every step")
+	cmd.Flags().BoolVar(&clearBreakpoints, "clear", false, "Clear the breakpoints")
+	return cmd
+}
+
+// mergeBreakpoints adds the steps to the comma separated breakpoints
+func mergeBreakpoints(breakpoints string, steps []string) string {
+	var merged []string
+	seen := map[string]bool{}
+	for _, step := range append(strings.Split(breakpoints, ","), steps...) {
+		if step = strings.TrimSpace(step); step != "" && !seen[step] {
+			seen[step] = true
+			merged = append(merged, step)
+		}
+	}
+	return strings.Join(merged, ",")
+}
+
+// newShowCommand creates the command to show the snapshot of a step
+func newShowCommand(f *factory, out io.Writer) *cobra.Command {
+	var attempt int
+	cmd := &cobra.Command{
+		Use:     "show NAME STEP",
+		Short:   "Show the parameter, context and inputs of the step paused at the breakpoint, or the snapshot of an attempt of it",
+		Example: "  workflow debug show my-run apply\n  workflow debug show my-run apply --attempt 2",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := f.Client()
+			if err != nil {
+				return err
+			}
+			cm := &corev1.ConfigMap{}
+			if err := cli.Get(cmd.Context(), client.ObjectKey{Namespace: f.namespace, Name: debug.GenerateContextName(args[0], args[1])}, cm); err != nil {
+				return errors.WithMessagef(err, "get the snapshot of step %s, is the workflow run debugged", args[1])
+			}
+			key := debug.DataKeyBreakpoint
+			switch {
+			case attempt > 0:
+				key = debug.AttemptKey(attempt)
+			case cm.Data[key] == "":
+				key = debug.DataKeyDebug
+			}
+			snapshot, ok := cm.Data[key]
+			if !ok {
+				var attempts []string
+				for _, a := range debug.ListAttempts(cm.Data) {
+					attempts = append(attempts, fmt.Sprint(a))
+				}
+				return errors.Errorf("no snapshot of attempt %d of step %s, the kept attempts are [%s]", attempt, args[1], strings.Join(attempts, ", "))
+			}
+			fmt.Fprintln(out, strings.TrimSpace(snapshot))
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&attempt, "attempt", 0, "The attempt of the step to show, the paused or the latest one is shown if it's not set")
+	return cmd
+}
+
+// newContinueCommand creates the command to continue a step paused at the breakpoint
+func newContinueCommand(f *factory, out io.Writer) *cobra.Command {
+	var skip bool
+	var parameter string
+	cmd := &cobra.Command{
+		Use:     "continue NAME STEP",
+		Short:   "Continue or skip a step paused at the breakpoint, the values in its parameter can be overridden",
+		Example: "  workflow debug continue my-run apply\n  workflow debug continue my-run apply --parameter '{\"replicas\":2}'\n  workflow debug continue my-run apply --skip",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decision := types.StepContinue{Name: args[1], Skip: skip}
+			if parameter != "" {
+				if err := json.Unmarshal([]byte(parameter), &map[string]interface{}{}); err != nil {
+					return errors.WithMessage(err, "the parameter must be a json object")
+				}
+				decision.Parameter = &runtime.RawExtension{Raw: []byte(parameter)}
+			}
+			action := fmt.Sprintf("step %s continued", decision.Name)
+			if decision.Skip {
+				action = fmt.Sprintf("step %s skipped", decision.Name)
+			}
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], action, func(run *v1alpha1.WorkflowRun) error {
+				if !isPausedStep(run.Status, decision.Name) {
+					return errors.Errorf("step %s of workflow run %s is not paused at the breakpoint", decision.Name, run.Name)
+				}
+				// the pending decisions which are not stored by the controller yet are kept
+				var decisions []types.StepContinue
+				if data := run.Annotations[types.AnnotationWorkflowRunContinue]; data != "" {
+					if err := json.Unmarshal([]byte(data), &decisions); err != nil {
+						return errors.WithMessage(err, "parse the pending decisions")
+					}
+				}
+				b, err := json.Marshal(append(decisions, decision))
+				if err != nil {
+					return err
+				}
+				if run.Annotations == nil {
+					run.Annotations = map[string]string{}
+				}
+				run.Annotations[types.AnnotationWorkflowRunContinue] = string(b)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&skip, "skip", false, "Skip the step instead of running it")
+	cmd.Flags().StringVar(&parameter, "parameter", "", "The json object of the values to override in the parameter of the step")
+	return cmd
+}
+
+// isPausedStep returns true if the step whose name or id is the given one is paused at the breakpoint
+func isPausedStep(status v1alpha1.WorkflowRunStatus, name string) bool {
+	return !types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		return (step.Name != name && step.ID != name) || step.Phase != v1alpha1.WorkflowStepPhaseRunning || step.Reason != types.StatusReasonBreakpoint
+	})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// fieldManager is the field manager of the changes made by the cli, the controller records it as the operator of
+// the controls in the spec
+const fieldManager = "workflow-cli"
+
+// factory creates the clients of the cluster in the kubeconfig, the clients are created once and shared by the
+// commands
+type factory struct {
+	namespace string
+	config    *rest.Config
+	client    client.Client
+}
+
+// Config returns the rest config of the cluster
+func (f *factory) Config() (*rest.Config, error) {
+	if f.config != nil {
+		return f.config, nil
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.WithMessage(err, "get kubeconfig")
+	}
+	f.config = cfg
+	return cfg, nil
+}
+
+// Client returns the client of the cluster
+func (f *factory) Client() (client.Client, error) {
+	if f.client != nil {
+		return f.client, nil
+	}
+	cfg, err := f.Config()
+	if err != nil {
+		return nil, err
+	}
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, errors.WithMessage(err, "create client")
+	}
+	f.client = cli
+	return cli, nil
+}
+
+// GetWorkflowRun gets the workflow run in the namespace
+func (f *factory) GetWorkflowRun(ctx context.Context, name string) (*v1alpha1.WorkflowRun, error) {
+	cli, err := f.Client()
+	if err != nil {
+		return nil, err
+	}
+	run := &v1alpha1.WorkflowRun{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: f.namespace, Name: name}, run); err != nil {
+		return nil, errors.WithMessagef(err, "get workflow run %s/%s", f.namespace, name)
+	}
+	return run, nil
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/utils"
+)
+
+// LogsOptions is the options of streaming the logs of a step
+type LogsOptions struct {
+	// Step is the name of the step
+	Step string
+	// Container is the container of the pods, the default container is used if it's empty
+	Container string
+	// Follow streams the logs of the pods until they're closed
+	Follow bool
+
+	factory *factory
+	out     io.Writer
+}
+
+// newLogsCommand creates the command to stream the logs of a step
+func newLogsCommand(f *factory, out io.Writer) *cobra.Command {
+	o := &LogsOptions{factory: f, out: out}
+	cmd := &cobra.Command{
+		Use:   "logs NAME --step STEP",
+		Short: "Stream the logs of a step from the source in its op.#Log, either the url or the pods",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVarP(&o.Step, "step", "s", "", "The name of the step")
+	cmd.Flags().StringVarP(&o.Container, "container", "c", "", "The container of the pods")
+	cmd.Flags().BoolVarP(&o.Follow, "follow", "f", false, "Follow the logs of the pods")
+	_ = cmd.MarkFlagRequired("step")
+	return cmd
+}
+
+// Run streams the logs of the step in the workflow run
+func (o *LogsOptions) Run(ctx context.Context, name string) error {
+	cli, err := o.factory.Client()
+	if err != nil {
+		return err
+	}
+	config, err := utils.GetLogConfigFromStep(ctx, cli, name, o.factory.namespace, o.Step)
+	if err != nil {
+		return errors.WithMessagef(err, "get the log config of step %s", o.Step)
+	}
+	if config.Source == nil {
+		if config.Data {
+			fmt.Fprintf(o.out, "The data of step %s is printed in the logs of the workflow controller\n", o.Step)
+			return nil
+		}
+		return errors.Errorf("no log source found for step %s", o.Step)
+	}
+	if config.Source.URL != "" {
+		rc, err := utils.GetLogsFromURL(ctx, config.Source.URL)
+		if err != nil {
+			return errors.WithMessagef(err, "get logs from %s", config.Source.URL)
+		}
+		//nolint:errcheck
+		defer rc.Close()
+		_, err = io.Copy(o.out, rc)
+		return err
+	}
+	return o.streamPodLogs(ctx, config.Source.Resources)
+}
+
+type podLogSource struct {
+	pod     corev1.Pod
+	cluster string
+}
+
+// streamPodLogs streams the logs of the pods in the resources, the lines are prefixed with the pod names if there
+// are more than one pods
+func (o *LogsOptions) streamPodLogs(ctx context.Context, resources []types.Resource) error {
+	cli, err := o.factory.Client()
+	if err != nil {
+		return err
+	}
+	cfg, err := o.factory.Config()
+	if err != nil {
+		return err
+	}
+	var sources []podLogSource
+	for _, resource := range resources {
+		pods, err := utils.GetPodListFromResources(ctx, cli, []types.Resource{resource})
+		if err != nil {
+			return errors.WithMessage(err, "get pods")
+		}
+		for _, pod := range pods {
+			sources = append(sources, podLogSource{pod: pod, cluster: resource.Cluster})
+		}
+	}
+	if len(sources) == 0 {
+		return errors.Errorf("no pods found for step %s", o.Step)
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, source := range sources {
+		prefix := ""
+		if len(sources) > 1 {
+			prefix = fmt.Sprintf("[%s/%s] ", source.pod.Namespace, source.pod.Name)
+		}
+		rc, err := utils.GetLogsFromPod(ctx, cfg, cli, source.pod.Name, source.pod.Namespace, source.cluster, &corev1.PodLogOptions{
+			Container: o.Container,
+			Follow:    o.Follow,
+		})
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "get logs of pod %s/%s", source.pod.Namespace, source.pod.Name))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			//nolint:errcheck
+			defer rc.Close()
+			scanner := bufio.NewScanner(rc)
+			for scanner.Scan() {
+				mu.Lock()
+				fmt.Fprintf(o.out, "%s%s\n", prefix, scanner.Text())
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// the lifecycle operations set the controls in the spec of the workflow run, which are applied by the controller
+
+// newSuspendCommand creates the command to suspend a workflow run
+func newSuspendCommand(f *factory, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "suspend NAME",
+		Short: "Suspend a workflow run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], "suspended", func(run *v1alpha1.WorkflowRun) error {
+				if run.Status.Finished {
+					return errors.Errorf("workflow run %s is finished", run.Name)
+				}
+				run.Spec.Suspend = true
+				return nil
+			})
+		},
+	}
+}
+
+// newResumeCommand creates the command to resume a workflow run or its suspend step
+func newResumeCommand(f *factory, out io.Writer) *cobra.Command {
+	resume := v1alpha1.StepResume{}
+	cmd := &cobra.Command{
+		Use:   "resume NAME",
+		Short: "Resume a suspended workflow run, or resume or reject a suspend step of it with --step",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action := "resumed"
+			if resume.Name != "" {
+				action = fmt.Sprintf("step %s resumed", resume.Name)
+				if resume.Reject {
+					action = fmt.Sprintf("step %s rejected", resume.Name)
+				}
+			}
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], action, func(run *v1alpha1.WorkflowRun) error {
+				if resume.Name != "" {
+					run.Spec.ResumeSteps = append(run.Spec.ResumeSteps, resume)
+					return nil
+				}
+				if !run.Spec.Suspend {
+					return errors.Errorf("workflow run %s is not suspended manually, resume the suspend steps with --step", run.Name)
+				}
+				run.Spec.Suspend = false
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&resume.Name, "step", "", "The name or id of the suspend step to resume")
+	cmd.Flags().BoolVar(&resume.Reject, "reject", false, "Reject the suspend step instead of resuming it")
+	cmd.Flags().StringVar(&resume.Approver, "approver", "", "The one who made the decision of the suspend step")
+	cmd.Flags().StringVar(&resume.Comment, "comment", "", "The comment of the decision of the suspend step")
+	return cmd
+}
+
+// newTerminateCommand creates the command to terminate a workflow run
+func newTerminateCommand(f *factory, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "terminate NAME",
+		Short: "Terminate a workflow run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], "terminated", func(run *v1alpha1.WorkflowRun) error {
+				if run.Status.Finished {
+					return errors.Errorf("workflow run %s is finished", run.Name)
+				}
+				run.Spec.Terminate = true
+				return nil
+			})
+		},
+	}
+}
+
+// newRestartCommand creates the command to restart a workflow run
+func newRestartCommand(f *factory, out io.Writer) *cobra.Command {
+	var step string
+	cmd := &cobra.Command{
+		Use:   "restart NAME",
+		Short: "Restart a workflow run from a step, or from the first failed step if the step is not specified",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return f.patchWorkflowRun(cmd.Context(), out, args[0], "restarted", func(run *v1alpha1.WorkflowRun) error {
+				if run.Annotations == nil {
+					run.Annotations = map[string]string{}
+				}
+				run.Annotations[types.AnnotationWorkflowRunRestart] = step
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&step, "step", "", "The name of the step to restart from")
+	return cmd
+}
+
+// patchWorkflowRun patches the workflow run with the changes made by the mutate function
+func (f *factory) patchWorkflowRun(ctx context.Context, out io.Writer, name, action string, mutate func(run *v1alpha1.WorkflowRun) error) error {
+	cli, err := f.Client()
+	if err != nil {
+		return err
+	}
+	run, err := f.GetWorkflowRun(ctx, name)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(run.DeepCopy())
+	if err := mutate(run); err != nil {
+		return err
+	}
+	if err := cli.Patch(ctx, run, patch, client.FieldOwner(fieldManager)); err != nil {
+		return errors.WithMessagef(err, "patch workflow run %s/%s", run.Namespace, run.Name)
+	}
+	fmt.Fprintf(out, "WorkflowRun %s/%s %s\n", run.Namespace, run.Name, action)
+	return nil
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/executor"
+	"github.com/kubevela/workflow/pkg/generator"
+	"github.com/kubevela/workflow/pkg/tasks/template"
+	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/utils"
+)
+
+// RunOptions is the options of running a workflow locally
+type RunOptions struct {
+	// Files are the files of the workflow, a file contains either a WorkflowRun or a Workflow, the other objects
+	// in the files are created in the fake cluster before the workflow runs
+	Files []string
+	// Definitions are the files or directories of the WorkflowStepDefinitions used by the workflow
+	Definitions []string
+	// Cluster runs the workflow against the cluster in the kubeconfig instead of a fake cluster
+	Cluster bool
+	// Resume resumes the suspended workflow automatically
+	Resume bool
+	// Interval is the max interval between the executions of the workflow
+	Interval time.Duration
+	// Timeout is the timeout of the workflow, zero means no timeout
+	Timeout time.Duration
+
+	factory *factory
+	out     io.Writer
+	client  client.Client
+	pd      *packages.PackageDiscover
+	objects []client.Object
+}
+
+// newRunCommand creates the command to run a workflow locally
+func newRunCommand(f *factory, out io.Writer) *cobra.Command {
+	o := &RunOptions{factory: f, out: out}
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run a workflow locally",
+		Long: "Run a workflow locally until it finishes, the steps are executed against a fake cluster which is seeded " +
+			"with the other objects in the files, or the cluster in the kubeconfig with --cluster.",
+		Example: "  workflow run -f workflow.yaml -d ./definitions",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(cmd.Context())
+		},
+	}
+	cmd.Flags().StringArrayVarP(&o.Files, "file", "f", nil, "The files of the workflow and the objects in the fake cluster")
+	cmd.Flags().StringArrayVarP(&o.Definitions, "definitions", "d", nil, "The files or directories of the WorkflowStepDefinitions, either the cue templates named after the step types or the definitions in yaml")
+	cmd.Flags().BoolVar(&o.Cluster, "cluster", false, "Run the workflow against the cluster in the kubeconfig instead of a fake cluster")
+	cmd.Flags().BoolVar(&o.Resume, "resume", false, "Resume the suspended workflow automatically")
+	cmd.Flags().DurationVar(&o.Interval, "interval", time.Second, "The max interval between the executions of the workflow")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", 0, "The timeout of the workflow, zero means no timeout")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+// Run runs the workflow in the files until it finishes, the workflow fails if it's not succeeded
+func (o *RunOptions) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	run, err := o.load()
+	if err != nil {
+		return err
+	}
+	if err := o.init(); err != nil {
+		return err
+	}
+	wfContext.EnableInMemoryContext = true
+	defer wfContext.CleanupMemoryStore(run.Name, run.Namespace)
+	defer wfContext.MemStore.DeleteInMemoryContext(run.Name)
+
+	// the definitions in the workflow files are loaded as well
+	loader := template.NewWorkflowStepFileLoader(append(append([]string{}, o.Definitions...), o.Files...)...)
+	printer := newStepPrinter(o.out)
+	ctx = types.SetNamespaceInCtx(ctx, run.Namespace)
+	for {
+		phase, wait, err := o.execute(ctx, run, loader)
+		if err != nil {
+			return err
+		}
+		printer.print(run.Status.Steps)
+		if phase == v1alpha1.WorkflowStateSuspending && o.Resume && wait == 0 {
+			fmt.Fprintf(o.out, "Resume the workflow\n")
+			if err := o.resume(ctx, run); err != nil {
+				return err
+			}
+			continue
+		}
+		if isFinished(phase, wait) {
+			fmt.Fprintf(o.out, "Workflow %s is %s\n", run.Name, phase)
+			o.printContext(run)
+			if phase != v1alpha1.WorkflowStateSucceeded {
+				return errors.Errorf("workflow %s is %s", run.Name, phase)
+			}
+			return nil
+		}
+		if wait <= 0 || wait > o.Interval {
+			wait = o.Interval
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "wait for workflow %s", run.Name)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// execute executes the workflow once and returns the phase and the time to wait before the next execution
+func (o *RunOptions) execute(ctx context.Context, run *v1alpha1.WorkflowRun, loader template.Loader) (v1alpha1.WorkflowRunPhase, time.Duration, error) {
+	logCtx := monitorContext.NewTraceContext(ctx, "").AddTag("workflowrun", run.Namespace+"/"+run.Name)
+	defer logCtx.Commit("End execute workflowrun")
+	instance, err := generator.GenerateWorkflowInstance(ctx, o.client, run)
+	if err != nil {
+		return "", 0, errors.WithMessage(err, "generate workflow instance")
+	}
+	runners, err := generator.GenerateRunners(logCtx, instance, types.StepGeneratorOptions{
+		PackageDiscover: o.pd,
+		Client:          o.client,
+		TemplateLoader:  loader,
+	})
+	if err != nil {
+		return "", 0, errors.WithMessage(err, "generate runners")
+	}
+	e := executor.New(instance, o.client)
+	phase, err := e.ExecuteRunners(logCtx, runners)
+	if err != nil {
+		return "", 0, errors.WithMessage(err, "execute runners")
+	}
+	run.Status = instance.Status
+	run.Status.Phase = phase
+	switch phase {
+	case v1alpha1.WorkflowStateSuspending:
+		return phase, e.GetSuspendBackoffWaitTime(), nil
+	case v1alpha1.WorkflowStateExecuting:
+		return phase, e.GetBackoffWaitTime(), nil
+	default:
+		return phase, 0, nil
+	}
+}
+
+// resume resumes the workflow and its running suspend steps
+func (o *RunOptions) resume(ctx context.Context, run *v1alpha1.WorkflowRun) error {
+	var suspended []string
+	types.RangeStepStatus(run.Status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		if step.Type == types.WorkflowStepTypeSuspend && step.Phase == v1alpha1.WorkflowStepPhaseRunning {
+			suspended = append(suspended, step.ID)
+		}
+		return true
+	})
+	for _, id := range suspended {
+		if _, err := utils.ResumeWorkflowStep(ctx, o.client, run, v1alpha1.StepResume{Name: id}); err != nil {
+			return errors.WithMessagef(err, "resume step %s", id)
+		}
+	}
+	utils.ResumeWorkflowStatus(&run.Status)
+	return nil
+}
+
+func isFinished(phase v1alpha1.WorkflowRunPhase, wait time.Duration) bool {
+	switch phase {
+	case v1alpha1.WorkflowStateExecuting, v1alpha1.WorkflowStateInitializing, v1alpha1.WorkflowStateSkipped:
+		return false
+	case v1alpha1.WorkflowStateSuspending:
+		// the workflow suspended without a duration never resumes without the operator
+		return wait == 0
+	default:
+		return true
+	}
+}
+
+// init initializes the client and the package discover, the objects in the files are created in the fake cluster
+func (o *RunOptions) init() error {
+	if o.client != nil {
+		return nil
+	}
+	if o.Cluster {
+		if len(o.objects) > 0 {
+			return errors.New("the objects other than the workflow can only be created in the fake cluster")
+		}
+		cfg, err := o.factory.Config()
+		if err != nil {
+			return err
+		}
+		if o.client, err = o.factory.Client(); err != nil {
+			return err
+		}
+		if o.pd, err = packages.NewPackageDiscover(cfg); err != nil {
+			return errors.WithMessage(err, "discover packages")
+		}
+		return nil
+	}
+	scheme, err := newScheme()
+	if err != nil {
+		return err
+	}
+	o.client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(o.objects...).Build()
+	o.pd = packages.NewLocalPackageDiscover()
+	return nil
+}
+
+// load loads the workflow run and the objects in the files, a Workflow is run by a WorkflowRun with its spec
+func (o *RunOptions) load() (*v1alpha1.WorkflowRun, error) {
+	var runs, workflows []*unstructured.Unstructured
+	for _, file := range o.Files {
+		objs, err := readObjects(file)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read %s", file)
+		}
+		for _, obj := range objs {
+			gvk := obj.GroupVersionKind()
+			switch {
+			case gvk.Group == v1alpha1.Group && gvk.Kind == v1alpha1.WorkflowRunKind:
+				runs = append(runs, obj)
+			case gvk.Group == v1alpha1.Group && gvk.Kind == v1alpha1.WorkflowKind:
+				workflows = append(workflows, obj)
+				o.objects = append(o.objects, obj)
+			case gvk.Kind == "WorkflowStepDefinition":
+				// the definitions are loaded by the template loader
+			default:
+				o.objects = append(o.objects, obj)
+			}
+		}
+	}
+	run := &v1alpha1.WorkflowRun{}
+	switch {
+	case len(runs) == 1:
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(runs[0].Object, run); err != nil {
+			return nil, errors.Wrap(err, "invalid workflow run")
+		}
+	case len(runs) == 0 && len(workflows) == 1:
+		wf := &v1alpha1.Workflow{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(workflows[0].Object, wf); err != nil {
+			return nil, errors.Wrap(err, "invalid workflow")
+		}
+		run.Name = wf.Name
+		run.Namespace = wf.Namespace
+		run.Spec.WorkflowSpec = &wf.WorkflowSpec
+	case len(runs) > 1:
+		return nil, errors.New("only one workflow run can be run at a time")
+	case len(workflows) > 1:
+		return nil, errors.New("only one workflow can be run at a time, specify the workflow run to run one of them")
+	default:
+		return nil, errors.New("no workflow run or workflow is found in the files")
+	}
+	if run.Name == "" {
+		return nil, errors.New("the name of the workflow is required")
+	}
+	if run.Namespace == "" {
+		run.Namespace = "default"
+		if o.factory != nil {
+			run.Namespace = o.factory.namespace
+		}
+	}
+	for _, obj := range o.objects {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(run.Namespace)
+		}
+	}
+	return run, nil
+}
+
+// readObjects reads the objects in the yaml or json file
+func readObjects(file string) ([]*unstructured.Unstructured, error) {
+	content, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				return objs, nil
+			}
+			return nil, err
+		}
+		if len(obj.Object) > 0 {
+			objs = append(objs, obj)
+		}
+	}
+}
+
+func (o *RunOptions) printContext(run *v1alpha1.WorkflowRun) {
+	wfCtx, err := wfContext.LoadContext(o.client, run.Namespace, run.Name)
+	if err != nil {
+		return
+	}
+	vars, err := wfCtx.GetVar()
+	if err != nil {
+		return
+	}
+	s, err := vars.String()
+	if err != nil || strings.TrimSpace(s) == "" {
+		return
+	}
+	fmt.Fprintf(o.out, "Context:\n%s\n", strings.TrimSpace(s))
+}
+
+// stepPrinter prints the transitions of the steps
+type stepPrinter struct {
+	out    io.Writer
+	states map[string]string
+}
+
+func newStepPrinter(out io.Writer) *stepPrinter {
+	return &stepPrinter{out: out, states: map[string]string{}}
+}
+
+func (p *stepPrinter) print(steps []v1alpha1.WorkflowStepStatus) {
+	p.printSteps(steps, "")
+}
+
+func (p *stepPrinter) printSteps(steps []v1alpha1.WorkflowStepStatus, prefix string) {
+	for _, step := range steps {
+		name := prefix + step.Name
+		state := string(step.Phase)
+		if step.Reason != "" {
+			state += fmt.Sprintf(" (%s)", step.Reason)
+		}
+		if step.Message != "" {
+			state += ": " + step.Message
+		}
+		if p.states[step.ID] != state {
+			p.states[step.ID] = state
+			fmt.Fprintf(p.out, "Step %s [%s] %s\n", name, step.Type, state)
+		}
+		p.printSteps(step.SubStepsStatus, name+"/")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRun(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+	o := &RunOptions{
+		Files:       []string{"./testdata/workflow.yaml"},
+		Definitions: []string{"./testdata/definitions"},
+		Interval:    10 * time.Millisecond,
+		Timeout:     time.Minute,
+		out:         out,
+	}
+	err := o.Run(ctx)
+	r.Error(err)
+	r.Contains(err.Error(), "workflow local is suspending")
+	r.Contains(out.String(), "Step message [message] succeeded")
+	r.Contains(out.String(), "Step approve [suspend] running")
+
+	out.Reset()
+	o = &RunOptions{
+		Files:       []string{"./testdata/workflow.yaml"},
+		Definitions: []string{"./testdata/definitions"},
+		Resume:      true,
+		Interval:    10 * time.Millisecond,
+		Timeout:     time.Minute,
+		out:         out,
+	}
+	r.NoError(o.Run(ctx))
+	r.Contains(out.String(), "Resume the workflow")
+	r.Contains(out.String(), "Step apply [apply-object] succeeded")
+	r.Contains(out.String(), "Workflow local is succeeded")
+	r.Contains(out.String(), `greeting: "hello workflow"`)
+	cm := &corev1.ConfigMap{}
+	r.NoError(o.client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "greeting"}, cm))
+	r.Equal("hello workflow", cm.Data["greeting"])
+	r.NoError(o.client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "existing"}, cm))
+
+	o = &RunOptions{Files: []string{"./testdata/definitions/message.cue"}, out: out}
+	r.Error(o.Run(ctx))
+}
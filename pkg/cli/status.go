@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// now returns the current time, it's replaced in the tests
+var now = time.Now
+
+// newListCommand creates the command to list the workflow runs
+func newListCommand(f *factory, out io.Writer) *cobra.Command {
+	var allNamespaces bool
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the workflow runs with their phases",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := f.Client()
+			if err != nil {
+				return err
+			}
+			runs := &v1alpha1.WorkflowRunList{}
+			var opts []client.ListOption
+			if !allNamespaces {
+				opts = append(opts, client.InNamespace(f.namespace))
+			}
+			if err := cli.List(cmd.Context(), runs, opts...); err != nil {
+				return err
+			}
+			printRuns(out, runs.Items, allNamespaces)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the workflow runs in all namespaces")
+	return cmd
+}
+
+func printRuns(out io.Writer, runs []v1alpha1.WorkflowRun, withNamespace bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tPHASE\tSTARTED\tDURATION")
+	for _, run := range runs {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", run.Namespace)
+		}
+		phase := string(run.Status.Phase)
+		if phase == "" {
+			phase = "-"
+		}
+		started := "-"
+		if !run.Status.StartTime.IsZero() {
+			started = duration.HumanDuration(now().Sub(run.Status.StartTime.Time)) + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.Name, phase, started, formatDuration(run.Status.StartTime, run.Status.EndTime, !run.Status.Finished))
+	}
+	_ = w.Flush()
+}
+
+// newStatusCommand creates the command to show the status of a workflow run
+func newStatusCommand(f *factory, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the status of a workflow run with the tree of its steps",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run, err := f.GetWorkflowRun(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			printStatus(out, run)
+			return nil
+		},
+	}
+}
+
+func printStatus(out io.Writer, run *v1alpha1.WorkflowRun) {
+	status := run.Status
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", run.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", run.Namespace)
+	fmt.Fprintf(w, "Phase:\t%s\n", status.Phase)
+	if status.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", status.Message)
+	}
+	if !status.StartTime.IsZero() {
+		fmt.Fprintf(w, "Started:\t%s\n", status.StartTime.Format(time.RFC3339))
+		fmt.Fprintf(w, "Duration:\t%s\n", formatDuration(status.StartTime, status.EndTime, !status.Finished))
+	}
+	if status.RestartCount > 0 {
+		fmt.Fprintf(w, "Restarts:\t%d\n", status.RestartCount)
+	}
+	_ = w.Flush()
+	if len(status.Steps) > 0 {
+		fmt.Fprintln(out, "Steps:")
+		printStepTree(out, status.Steps, "")
+	}
+	if len(status.ExitHandlers) > 0 {
+		fmt.Fprintln(out, "Exit Handlers:")
+		printStepTree(out, status.ExitHandlers, "")
+	}
+}
+
+// printStepTree prints the steps and their sub steps as a tree
+func printStepTree(out io.Writer, steps []v1alpha1.WorkflowStepStatus, indent string) {
+	for i, step := range steps {
+		branch, next := "├── ", "│   "
+		if i == len(steps)-1 {
+			branch, next = "└── ", "    "
+		}
+		line := fmt.Sprintf("%s%s%s [%s] %s", indent, branch, step.Name, step.Type, step.Phase)
+		if step.Reason != "" {
+			line += fmt.Sprintf(" (%s)", step.Reason)
+		}
+		running := step.Phase == v1alpha1.WorkflowStepPhaseRunning || step.Phase == v1alpha1.WorkflowStepPhasePending
+		if d := formatDuration(step.FirstExecuteTime, step.LastExecuteTime, running); d != "-" {
+			line += " " + d
+		}
+		if step.Message != "" {
+			line += ": " + step.Message
+		}
+		fmt.Fprintln(out, line)
+		printStepTree(out, step.SubStepsStatus, indent+next)
+	}
+}
+
+// formatDuration formats the duration from the start to the end, the duration of the running one is counted to now
+func formatDuration(start, end metav1.Time, running bool) string {
+	if start.IsZero() {
+		return "-"
+	}
+	if running || end.IsZero() {
+		end = metav1.NewTime(now())
+	}
+	d := end.Sub(start.Time)
+	if d < 0 {
+		d = 0
+	}
+	return duration.HumanDuration(d)
+}
@@ -0,0 +1,4 @@
+parameter: {
+	name: string
+}
+message: "hello " + parameter.name
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package concurrency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"text/template"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+const (
+	// AnnotationConcurrencyGroup is the annotation of the lease which records the concurrency group
+	AnnotationConcurrencyGroup = "workflowrun.oam.dev/concurrency-group"
+)
+
+// Lock is the lock of the concurrency group, it's persisted in a Lease in the namespace of the
+// workflow run, the holder identity of the lease is the name of the run which holds the group.
+type Lock struct {
+	cli    client.Client
+	reader client.Reader
+	run    *v1alpha1.WorkflowRun
+	group  string
+}
+
+// NewLock returns the lock of the concurrency group of the workflow run, the other runs in the group are read from
+// the reader, it should read from the api server if the runs in the group may be in the other shards.
+func NewLock(cli client.Client, reader client.Reader, run *v1alpha1.WorkflowRun) (*Lock, error) {
+	if run.Spec.Concurrency == nil {
+		return nil, errors.New("the concurrency of the workflow run is not set")
+	}
+	group, err := RenderGroup(run)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{cli: cli, reader: reader, run: run, group: group}, nil
+}
+
+// RenderGroup renders the concurrency group of the workflow run, the name, namespace, labels
+// and annotations of the run can be referenced in the group.
+func RenderGroup(run *v1alpha1.WorkflowRun) (string, error) {
+	tmpl, err := template.New("group").Option("missingkey=error").Parse(run.Spec.Concurrency.Group)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid concurrency group")
+	}
+	data := map[string]interface{}{
+		"name":        run.Name,
+		"namespace":   run.Namespace,
+		"labels":      run.Labels,
+		"annotations": run.Annotations,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.WithMessage(err, "render concurrency group")
+	}
+	if buf.Len() == 0 {
+		return "", errors.New("the concurrency group is empty")
+	}
+	return buf.String(), nil
+}
+
+// GenerateLeaseName generates the name of the lease of the concurrency group.
+func GenerateLeaseName(group string) string {
+	return fmt.Sprintf("workflow-concurrency-%x", sha256.Sum256([]byte(group)))[:37]
+}
+
+// Group returns the rendered concurrency group.
+func (l *Lock) Group() string {
+	return l.group
+}
+
+// Acquire tries to acquire the lock for the workflow run and returns the current holder of the lock.
+// The lock is taken over if the holder has finished or been deleted, or force is true. Without force,
+// the lock is only acquired if there's no earlier queued run in the same group.
+func (l *Lock) Acquire(ctx context.Context, force bool) (string, bool, error) {
+	lease := &coordinationv1.Lease{}
+	err := l.cli.Get(ctx, client.ObjectKey{Namespace: l.run.Namespace, Name: GenerateLeaseName(l.group)}, lease)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return "", false, errors.WithMessage(err, "get the lease of concurrency group")
+	}
+	holder := pointer.StringDeref(lease.Spec.HolderIdentity, "")
+	if err == nil && holder == l.run.Name {
+		return holder, true, nil
+	}
+	if !force {
+		if holder != "" {
+			active, err := l.isActive(ctx, holder)
+			if err != nil {
+				return holder, false, err
+			}
+			if active {
+				return holder, false, nil
+			}
+		}
+		first, err := l.isFirstInQueue(ctx)
+		if err != nil || !first {
+			return "", false, err
+		}
+	}
+
+	now := metav1.NowMicro()
+	lease.Spec.HolderIdentity = pointer.String(l.run.Name)
+	lease.Spec.AcquireTime = &now
+	if kerrors.IsNotFound(err) {
+		lease.Name = GenerateLeaseName(l.group)
+		lease.Namespace = l.run.Namespace
+		lease.Annotations = map[string]string{AnnotationConcurrencyGroup: l.group}
+		err = l.cli.Create(ctx, lease)
+	} else {
+		err = l.cli.Update(ctx, lease)
+	}
+	// another run acquires the lock at the same time, try again later
+	if kerrors.IsAlreadyExists(err) || kerrors.IsConflict(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.WithMessage(err, "acquire the lease of concurrency group")
+	}
+	return l.run.Name, true, nil
+}
+
+// Release releases the lock if it's held by the workflow run.
+func (l *Lock) Release(ctx context.Context) error {
+	lease := &coordinationv1.Lease{}
+	if err := l.cli.Get(ctx, client.ObjectKey{Namespace: l.run.Namespace, Name: GenerateLeaseName(l.group)}, lease); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if pointer.StringDeref(lease.Spec.HolderIdentity, "") != l.run.Name {
+		return nil
+	}
+	if err := l.cli.Delete(ctx, lease, client.Preconditions{ResourceVersion: &lease.ResourceVersion}); err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithMessage(err, "release the lease of concurrency group")
+	}
+	return nil
+}
+
+func (l *Lock) isActive(ctx context.Context, name string) (bool, error) {
+	run := &v1alpha1.WorkflowRun{}
+	if err := l.reader.Get(ctx, client.ObjectKey{Namespace: l.run.Namespace, Name: name}, run); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.WithMessagef(err, "get the holder %s of concurrency group", name)
+	}
+	return !run.Status.Finished && run.DeletionTimestamp == nil, nil
+}
+
+// isFirstInQueue checks if there's no earlier queued run in the same group, so that the runs are admitted in order.
+func (l *Lock) isFirstInQueue(ctx context.Context) (bool, error) {
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := l.reader.List(ctx, runs, client.InNamespace(l.run.Namespace)); err != nil {
+		return false, errors.WithMessage(err, "list workflow runs")
+	}
+	for i, run := range runs.Items {
+		if run.Name == l.run.Name || run.Status.Phase != v1alpha1.WorkflowStateQueued || run.Spec.Concurrency == nil {
+			continue
+		}
+		if !run.CreationTimestamp.Before(&l.run.CreationTimestamp) &&
+			!(run.CreationTimestamp.Equal(&l.run.CreationTimestamp) && run.Name < l.run.Name) {
+			continue
+		}
+		if group, err := RenderGroup(&runs.Items[i]); err == nil && group == l.group {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package concurrency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestRenderGroup(t *testing.T) {
+	testCases := map[string]struct {
+		group       string
+		expected    string
+		expectedErr string
+	}{
+		"plain": {
+			group:    "deploy",
+			expected: "deploy",
+		},
+		"label": {
+			group:    "deploy-{{ .labels.env }}",
+			expected: "deploy-prod",
+		},
+		"namespace": {
+			group:    "{{ .namespace }}-{{ index .annotations \"app.oam.dev/name\" }}",
+			expected: "default-app",
+		},
+		"missing-label": {
+			group:       "deploy-{{ .labels.cluster }}",
+			expectedErr: "render concurrency group",
+		},
+		"invalid": {
+			group:       "deploy-{{ .labels.env",
+			expectedErr: "invalid concurrency group",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			run := newRun("run", time.Now())
+			run.Spec.Concurrency.Group = tc.group
+			group, err := RenderGroup(run)
+			if tc.expectedErr != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.expected, group)
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	now := time.Now()
+	first := newRun("first", now)
+	second := newRun("second", now.Add(time.Second))
+	third := newRun("third", now.Add(2*time.Second))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second, third).Build()
+
+	firstLock, err := NewLock(cli, cli, first)
+	r.NoError(err)
+	r.Equal("deploy-prod", firstLock.Group())
+	holder, acquired, err := firstLock.Acquire(ctx, false)
+	r.NoError(err)
+	r.True(acquired)
+	r.Equal("first", holder)
+	lease := &coordinationv1.Lease{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateLeaseName("deploy-prod")}, lease))
+	r.Equal("first", *lease.Spec.HolderIdentity)
+
+	// acquire again by the holder
+	_, acquired, err = firstLock.Acquire(ctx, false)
+	r.NoError(err)
+	r.True(acquired)
+
+	// the second and third runs are queued
+	secondLock, err := NewLock(cli, cli, second)
+	r.NoError(err)
+	holder, acquired, err = secondLock.Acquire(ctx, false)
+	r.NoError(err)
+	r.False(acquired)
+	r.Equal("first", holder)
+	second.Status.Phase = v1alpha1.WorkflowStateQueued
+	r.NoError(cli.Status().Update(ctx, second))
+	thirdLock, err := NewLock(cli, cli, third)
+	r.NoError(err)
+
+	// the third run can't jump the queue after the first run finished
+	first.Status.Finished = true
+	r.NoError(cli.Status().Update(ctx, first))
+	holder, acquired, err = thirdLock.Acquire(ctx, false)
+	r.NoError(err)
+	r.False(acquired)
+	r.Equal("", holder)
+	holder, acquired, err = secondLock.Acquire(ctx, false)
+	r.NoError(err)
+	r.True(acquired)
+	r.Equal("second", holder)
+
+	// the lock is taken over by force
+	holder, acquired, err = thirdLock.Acquire(ctx, true)
+	r.NoError(err)
+	r.True(acquired)
+	r.Equal("third", holder)
+
+	// only the holder can release the lock
+	r.NoError(secondLock.Release(ctx))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateLeaseName("deploy-prod")}, lease))
+	r.NoError(thirdLock.Release(ctx))
+	err = cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateLeaseName("deploy-prod")}, lease)
+	r.Error(err)
+}
+
+func newRun(name string, created time.Time) *v1alpha1.WorkflowRun {
+	return &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{"env": "prod"},
+			Annotations:       map[string]string{"app.oam.dev/name": "app"},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			Concurrency: &v1alpha1.ConcurrencyGroup{
+				Group: "deploy-{{ .labels.env }}",
+			},
+		},
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+// ArtifactRefKey is the key of the artifact reference which is kept in vars instead of the value
+const ArtifactRefKey = "$artifact"
+
+var (
+	// DefaultArtifactStore stores the large vars out of the context ConfigMap, all the vars are kept in the
+	// context if it's nil
+	DefaultArtifactStore ArtifactStore
+	// ArtifactSizeThreshold is the encoded size in bytes above which the var is written to the artifact store,
+	// only the outputs marked as artifacts are written to the store if it's not positive
+	ArtifactSizeThreshold = 0
+)
+
+// ArtifactStore is the backend of the artifacts, e.g. ConfigMaps or a file system
+type ArtifactStore interface {
+	Put(ctx context.Context, ref ArtifactRef, data []byte) error
+	Get(ctx context.Context, ref ArtifactRef) ([]byte, error)
+	// Delete deletes the artifact, it returns nil if the artifact doesn't exist
+	Delete(ctx context.Context, ref ArtifactRef) error
+}
+
+// ArtifactRef is the reference to the artifact
+type ArtifactRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Owners is the owners of the context, it's not kept in the reference
+	Owners []metav1.OwnerReference `json:"-"`
+}
+
+// SetArtifact set variable to the artifact store and keep the reference in workflow context, the variable is kept
+// in workflow context if the artifact store is not set.
+func (wf *WorkflowContext) SetArtifact(v *value.Value, paths ...string) error {
+	str, err := v.String()
+	if err != nil {
+		return errors.WithMessage(err, "compile var")
+	}
+	if DefaultArtifactStore == nil {
+		return wf.setVarRaw(str, paths...)
+	}
+	return wf.setArtifactRaw(str, paths...)
+}
+
+// setArtifactRaw writes the variable to the artifact store, the artifact previously referenced by the path is
+// replaced since the artifact is named by the path.
+func (wf *WorkflowContext) setArtifactRaw(str string, paths ...string) error {
+	ref := ArtifactRef{
+		Namespace: wf.store.Namespace,
+		Name:      generateArtifactName(wf.store.Name, paths),
+		Owners:    wf.store.OwnerReferences,
+	}
+	b, err := json.Marshal(map[string]ArtifactRef{ArtifactRefKey: ref})
+	if err != nil {
+		return err
+	}
+	referenced := wf.isArtifact(paths...)
+	if err := DefaultArtifactStore.Put(context.Background(), ref, []byte(str)); err != nil {
+		return errors.WithMessagef(err, "put artifact %s", ref.Name)
+	}
+	if wf.artifacts != nil {
+		delete(wf.artifacts, ref.Name)
+	}
+	if err := wf.setVarRaw(string(b), paths...); err != nil {
+		// the artifact is not referenced if the path is set to an inline value
+		if !referenced {
+			if err := DefaultArtifactStore.Delete(context.Background(), ref); err != nil {
+				klog.ErrorS(err, "Failed to delete the artifact which is not referenced", "artifact", ref.Name)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// lookupVar looks up the variable and dereferences the artifacts on the path lazily
+func (wf *WorkflowContext) lookupVar(paths ...string) (*value.Value, error) {
+	for i := 1; i <= len(paths); i++ {
+		v, err := wf.vars.LookupValue(paths[:i]...)
+		if err != nil {
+			return nil, err
+		}
+		ref, ok, err := getArtifactRef(v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		artifact, err := wf.loadArtifact(ref)
+		if err != nil {
+			return nil, err
+		}
+		return artifact.LookupValue(paths[i:]...)
+	}
+	return wf.vars.LookupValue(paths...)
+}
+
+func (wf *WorkflowContext) loadArtifact(ref ArtifactRef) (*value.Value, error) {
+	if v, ok := wf.artifacts[ref.Name]; ok {
+		return v, nil
+	}
+	if DefaultArtifactStore == nil {
+		return nil, errors.Errorf("artifact store is not set to load artifact %s", ref.Name)
+	}
+	data, err := DefaultArtifactStore.Get(context.Background(), ref)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "get artifact %s", ref.Name)
+	}
+	v, err := value.NewValue(string(data), nil, "")
+	if err != nil {
+		return nil, errors.WithMessagef(err, "decode artifact %s", ref.Name)
+	}
+	if wf.artifacts == nil {
+		wf.artifacts = make(map[string]*value.Value)
+	}
+	wf.artifacts[ref.Name] = v
+	return v, nil
+}
+
+func getArtifactRef(v *value.Value) (ArtifactRef, bool, error) {
+	ref := ArtifactRef{}
+	rv, err := v.LookupValue(ArtifactRefKey)
+	if err != nil {
+		return ref, false, nil
+	}
+	if err := rv.UnmarshalTo(&ref); err != nil {
+		return ref, false, errors.WithMessage(err, "decode artifact reference")
+	}
+	return ref, true, nil
+}
+
+// getArtifactRefs returns the artifact references in the value by their names
+func getArtifactRefs(v cue.Value) map[string]ArtifactRef {
+	refs := make(map[string]ArtifactRef)
+	var collect func(v cue.Value)
+	collect = func(v cue.Value) {
+		if rv := v.LookupPath(cue.MakePath(cue.Str(ArtifactRefKey))); rv.Exists() {
+			ref := ArtifactRef{}
+			if err := rv.Decode(&ref); err == nil && ref.Name != "" {
+				refs[ref.Name] = ref
+			}
+			return
+		}
+		iter, err := v.Fields()
+		if err != nil {
+			return
+		}
+		for iter.Next() {
+			collect(iter.Value())
+		}
+	}
+	collect(v)
+	return refs
+}
+
+// isArtifact returns true if the variable of the path is kept in the artifact store
+func (wf *WorkflowContext) isArtifact(paths ...string) bool {
+	v, err := wf.vars.LookupValue(paths...)
+	if err != nil {
+		return false
+	}
+	_, ok, _ := getArtifactRef(v)
+	return ok
+}
+
+// DeleteArtifacts deletes the artifacts referenced by the workflow context of the workflow run, it should be called
+// before the workflow run is deleted since the references are lost together with the context.
+func DeleteArtifacts(ctx context.Context, cli client.Client, ns, name string) error {
+	if DefaultArtifactStore == nil {
+		return nil
+	}
+	wfCtx, err := LoadContext(cli, ns, name)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	v, err := wfCtx.GetVar()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ref := range getArtifactRefs(v.CueValue()) {
+		if err := DefaultArtifactStore.Delete(ctx, ref); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "delete artifact %s", ref.Name))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func generateArtifactName(storeName string, paths []string) string {
+	h := sha256.Sum256([]byte(strings.Join(paths, ".")))
+	return fmt.Sprintf("%s-%s", storeName, hex.EncodeToString(h[:])[:16])
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+type testArtifactStore map[string][]byte
+
+func (s testArtifactStore) Put(_ context.Context, ref ArtifactRef, data []byte) error {
+	s[ref.Namespace+"/"+ref.Name] = data
+	return nil
+}
+
+func (s testArtifactStore) Get(_ context.Context, ref ArtifactRef) ([]byte, error) {
+	return s[ref.Namespace+"/"+ref.Name], nil
+}
+
+func (s testArtifactStore) Delete(_ context.Context, ref ArtifactRef) error {
+	delete(s, ref.Namespace+"/"+ref.Name)
+	return nil
+}
+
+func TestArtifact(t *testing.T) {
+	r := require.New(t)
+	store := testArtifactStore{}
+	DefaultArtifactStore = store
+	ArtifactSizeThreshold = 64
+	defer func() {
+		DefaultArtifactStore = nil
+		ArtifactSizeThreshold = 0
+	}()
+	cli := newCliForTest(t, nil)
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+
+	small, err := value.NewValue(`"small"`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(small, "small"))
+	large, err := value.NewValue(`{body: "`+strings.Repeat("x", 64)+`", statusCode: 200}`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(large, "resp"))
+	r.NoError(wfCtx.SetArtifact(small, "marked"))
+	r.Equal(2, len(store))
+
+	// only the references are kept in the context
+	r.NoError(wfCtx.Commit())
+	vars := wfCtx.GetStore().Data[ConfigMapKeyVars]
+	r.NotContains(vars, strings.Repeat("x", 64))
+	r.Contains(vars, ArtifactRefKey)
+
+	loaded, err := LoadContext(cli, "default", "app-v1")
+	r.NoError(err)
+	v, err := loaded.GetVar("resp", "statusCode")
+	r.NoError(err)
+	code, err := v.CueValue().Int64()
+	r.NoError(err)
+	r.Equal(int64(200), code)
+	v, err = loaded.GetVar("resp", "body")
+	r.NoError(err)
+	body, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal(strings.Repeat("x", 64), body)
+	v, err = loaded.GetVar("marked")
+	r.NoError(err)
+	s, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal("small", s)
+	v, err = loaded.GetVar("small")
+	r.NoError(err)
+	s, err = v.CueValue().String()
+	r.NoError(err)
+	r.Equal("small", s)
+
+	// the artifact is replaced once the var is overwritten, e.g. the step is restarted
+	r.NoError(loaded.SetVar(small, "resp"))
+	r.Equal(2, len(store))
+	v, err = loaded.GetVar("resp")
+	r.NoError(err)
+	s, err = v.CueValue().String()
+	r.NoError(err)
+	r.Equal("small", s)
+	// the artifact is deleted if it can't be referenced by the var
+	r.Error(loaded.SetVar(large, "small"))
+	r.Equal(2, len(store))
+	r.NoError(loaded.Commit())
+
+	// the artifacts are deleted with the workflow run
+	r.NoError(DeleteArtifacts(context.Background(), cli, "default", "app-v1"))
+	r.Equal(0, len(store))
+
+	// the artifacts are kept in the context without the store
+	DefaultArtifactStore = nil
+	r.NoError(wfCtx.SetArtifact(small, "inline"))
+	r.Equal(0, len(store))
+	_, err = loaded.GetVar("missing", "path")
+	r.Error(err)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -55,11 +56,26 @@ type WorkflowContext struct {
 	memoryStore *sync.Map
 	components  map[string]*ComponentManifest
 	vars        *value.Value
+	artifacts   map[string]*value.Value
 	modified    bool
+	// committed is the data of the store loaded or written last time, the store is written in the commit if it's
+	// nil, e.g. the store is created with the context
+	committed map[string]string
+	// mu guards the store data and the components which are shared with the forked contexts
+	mu *sync.Mutex
+	// forked is true for the context forked from another one, the vars set in it are recorded in changes
+	forked  bool
+	changes []varChange
+}
+
+type varChange struct {
+	value string
+	paths []string
 }
 
 // GetComponent Get ComponentManifest from workflow context.
 func (wf *WorkflowContext) GetComponent(name string) (*ComponentManifest, error) {
+	defer wf.lock()()
 	component, ok := wf.components[name]
 	if !ok {
 		return nil, errors.Errorf("component %s not found in application", name)
@@ -78,6 +94,7 @@ func (wf *WorkflowContext) PatchComponent(name string, patchValue *value.Value)
 	if err != nil {
 		return err
 	}
+	defer wf.lock()()
 	if err := component.Patch(patchValue); err != nil {
 		return err
 	}
@@ -85,23 +102,35 @@ func (wf *WorkflowContext) PatchComponent(name string, patchValue *value.Value)
 	return nil
 }
 
-// GetVar get variable from workflow context.
+// GetVar get variable from workflow context, the artifacts on the path are loaded from the artifact store.
 func (wf *WorkflowContext) GetVar(paths ...string) (*value.Value, error) {
-	return wf.vars.LookupValue(paths...)
+	return wf.lookupVar(paths...)
 }
 
-// SetVar set variable to workflow context.
+// SetVar set variable to workflow context, the variable larger than the artifact size threshold is written to
+// the artifact store.
 func (wf *WorkflowContext) SetVar(v *value.Value, paths ...string) error {
 	str, err := v.String()
 	if err != nil {
 		return errors.WithMessage(err, "compile var")
 	}
+	if DefaultArtifactStore != nil && len(paths) > 0 &&
+		((ArtifactSizeThreshold > 0 && len(str) > ArtifactSizeThreshold) || wf.isArtifact(paths...)) {
+		return wf.setArtifactRaw(str, paths...)
+	}
+	return wf.setVarRaw(str, paths...)
+}
+
+func (wf *WorkflowContext) setVarRaw(str string, paths ...string) error {
 	if err := wf.vars.FillRaw(str, paths...); err != nil {
 		return err
 	}
 	if err := wf.vars.Error(); err != nil {
 		return err
 	}
+	if wf.forked {
+		wf.changes = append(wf.changes, varChange{value: str, paths: paths})
+	}
 	wf.modified = true
 	return nil
 }
@@ -113,17 +142,20 @@ func (wf *WorkflowContext) GetStore() *corev1.ConfigMap {
 
 // GetMutableValue get mutable data from workflow context.
 func (wf *WorkflowContext) GetMutableValue(paths ...string) string {
+	defer wf.lock()()
 	return wf.store.Data[strings.Join(paths, ".")]
 }
 
 // SetMutableValue set mutable data in workflow context config map.
 func (wf *WorkflowContext) SetMutableValue(data string, paths ...string) {
+	defer wf.lock()()
 	wf.store.Data[strings.Join(paths, ".")] = data
 	wf.modified = true
 }
 
 // DeleteMutableValue delete mutable data in workflow context.
 func (wf *WorkflowContext) DeleteMutableValue(paths ...string) {
+	defer wf.lock()()
 	key := strings.Join(paths, ".")
 	if _, ok := wf.store.Data[key]; ok {
 		delete(wf.store.Data, strings.Join(paths, "."))
@@ -178,20 +210,46 @@ func (wf *WorkflowContext) MakeParameter(parameter interface{}) (*value.Value, e
 	return wf.vars.MakeValue(s)
 }
 
-// Commit the workflow context and persist it's content.
+// Commit the workflow context and persist it's content, the forked context is persisted after being merged.
+// The retry and backoff states in the memory store are persisted together, the store is not written if the
+// content is unchanged.
 func (wf *WorkflowContext) Commit() error {
-	if !wf.modified {
+	if wf.forked {
+		return nil
+	}
+	memory, err := wf.encodeMemory()
+	if err != nil {
+		return errors.WithMessage(err, "encode memory")
+	}
+	if !wf.modified && memory == wf.store.Data[ConfigMapKeyMemory] {
 		return nil
 	}
 	if err := wf.writeToStore(); err != nil {
 		return err
 	}
-	if err := wf.sync(); err != nil {
-		return errors.WithMessagef(err, "save context to configMap(%s/%s)", wf.store.Namespace, wf.store.Name)
+	if memory == "" {
+		delete(wf.store.Data, ConfigMapKeyMemory)
+	} else {
+		wf.store.Data[ConfigMapKeyMemory] = memory
+	}
+	if wf.committed == nil || !reflect.DeepEqual(wf.committed, wf.store.Data) {
+		if err := wf.sync(); err != nil {
+			return errors.WithMessagef(err, "save context to configMap(%s/%s)", wf.store.Namespace, wf.store.Name)
+		}
+		wf.committed = copyData(wf.store.Data)
 	}
+	wf.modified = false
 	return nil
 }
 
+func copyData(data map[string]string) map[string]string {
+	c := make(map[string]string, len(data))
+	for k, v := range data {
+		c[k] = v
+	}
+	return c
+}
+
 func (wf *WorkflowContext) writeToStore() error {
 	varStr, err := wf.vars.String()
 	if err != nil {
@@ -340,9 +398,17 @@ func NewContext(cli client.Client, ns, name string, owner []metav1.OwnerReferenc
 	return wfCtx, wfCtx.Commit()
 }
 
-// CleanupMemoryStore cleans up memory store.
+// CleanupMemoryStore cleans up memory store, the persisted values are cleaned up in the next commit of the
+// contexts loaded before.
 func CleanupMemoryStore(name, ns string) {
-	workflowMemoryCache.Delete(fmt.Sprintf("%s-%s", name, ns))
+	if mc, ok := workflowMemoryCache.LoadAndDelete(fmt.Sprintf("%s-%s", name, ns)); ok {
+		if memCache, ok := mc.(*sync.Map); ok {
+			memCache.Range(func(k, _ interface{}) bool {
+				memCache.Delete(k)
+				return true
+			})
+		}
+	}
 }
 
 func newContext(cli client.Client, ns, name string, owner []metav1.OwnerReference) (*WorkflowContext, error) {
@@ -374,6 +440,7 @@ func newContext(cli client.Client, ns, name string, owner []metav1.OwnerReferenc
 		memoryStore: memCache,
 		components:  map[string]*ComponentManifest{},
 		modified:    true,
+		mu:          &sync.Mutex{},
 	}
 	var err error
 	wfCtx.vars, err = value.NewValue("", nil, "")
@@ -413,10 +480,16 @@ func LoadContext(cli client.Client, ns, name string) (Context, error) {
 		cli:         cli,
 		store:       &store,
 		memoryStore: memCache,
+		mu:          &sync.Mutex{},
+		committed:   copyData(store.Data),
 	}
 	if err := ctx.LoadFromConfigMap(store); err != nil {
 		return nil, err
 	}
+	// the context store is the source of truth of the retry and backoff states, it's shared by the replicas
+	if err := ctx.restoreMemory(); err != nil {
+		return nil, err
+	}
 	return ctx, nil
 }
 
@@ -424,3 +497,50 @@ func LoadContext(cli client.Client, ns, name string) (Context, error) {
 func GenerateStoreName(name string) string {
 	return fmt.Sprintf("workflow-%s-context", name)
 }
+
+// Fork returns a copy of the workflow context for the step running concurrently with the others. The vars are
+// copied into a separate cue runtime, and the vars set in the copy are applied to the context by Merge. The
+// mutable values, the components and the memory store are shared with the context.
+func (wf *WorkflowContext) Fork() (Context, error) {
+	str, err := wf.vars.String()
+	if err != nil {
+		return nil, errors.WithMessage(err, "encode vars")
+	}
+	vars, err := value.NewValue(str, nil, "")
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode vars")
+	}
+	return &WorkflowContext{
+		cli:         wf.cli,
+		store:       wf.store,
+		memoryStore: wf.memoryStore,
+		components:  wf.components,
+		vars:        vars,
+		mu:          wf.mu,
+		forked:      true,
+	}, nil
+}
+
+// Merge applies the vars set in the forked context to the context in order.
+func (wf *WorkflowContext) Merge(forked Context) error {
+	f, ok := forked.(*WorkflowContext)
+	if !ok || !f.forked {
+		return errors.New("the context to merge is not forked from the workflow context")
+	}
+	for _, change := range f.changes {
+		if err := wf.setVarRaw(change.value, change.paths...); err != nil {
+			return errors.WithMessagef(err, "merge var %s", strings.Join(change.paths, "."))
+		}
+	}
+	f.changes = nil
+	wf.modified = wf.modified || f.modified
+	return nil
+}
+
+func (wf *WorkflowContext) lock() func() {
+	if wf.mu == nil {
+		return func() {}
+	}
+	wf.mu.Lock()
+	return wf.mu.Unlock
+}
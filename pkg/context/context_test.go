@@ -311,6 +311,60 @@ func TestMemoryValue(t *testing.T) {
 	r.Equal(count, 11)
 }
 
+func TestWatchedObjects(t *testing.T) {
+	cli := newCliForTest(t, nil)
+	r := require.New(t)
+
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+	deploy := WatchedObject{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app"}
+	cm := WatchedObject{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "app"}
+	AddWatchedObjects(wfCtx, "step1", deploy)
+	AddWatchedObjects(wfCtx, "step1", deploy, cm)
+	AddWatchedObjects(wfCtx, "step2", cm)
+	r.Equal([]WatchedObject{deploy, cm}, GetWatchedObjects(wfCtx, "step1", "step2", "not-found"))
+
+	DeleteWatchedObjects(wfCtx, "step1")
+	r.Equal([]WatchedObject{cm}, GetWatchedObjects(wfCtx, "step1", "step2"))
+	r.Empty(GetWatchedObjects(newContextForTest(t), "step2"))
+}
+
+func TestForkAndMerge(t *testing.T) {
+	cli := newCliForTest(t, nil)
+	r := require.New(t)
+
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+	v, err := value.NewValue(`"base"`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "base"))
+
+	forked, err := wfCtx.Fork()
+	r.NoError(err)
+	base, err := forked.GetVar("base")
+	r.NoError(err)
+	s, err := base.CueValue().String()
+	r.NoError(err)
+	r.Equal("base", s)
+
+	v, err = value.NewValue(`"forked"`, nil, "")
+	r.NoError(err)
+	r.NoError(forked.SetVar(v, "step", "output"))
+	forked.SetMutableValue("value", "test", "key")
+	_, err = wfCtx.GetVar("step", "output")
+	r.Error(err)
+	// the mutable values are shared with the forked context
+	r.Equal("value", wfCtx.GetMutableValue("test", "key"))
+
+	r.NoError(wfCtx.Merge(forked))
+	output, err := wfCtx.GetVar("step", "output")
+	r.NoError(err)
+	s, err = output.CueValue().String()
+	r.NoError(err)
+	r.Equal("forked", s)
+	r.Error(wfCtx.Merge(wfCtx))
+}
+
 func newCliForTest(t *testing.T, wfCm *corev1.ConfigMap) *test.MockClient {
 	r := require.New(t)
 	return &test.MockClient{
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConfigMapKeyMemory is the key in ConfigMap Data field for containing the persisted values of the memory store
+	ConfigMapKeyMemory = "memory"
+
+	// MemoryPrefixFailedTimes is the prefix of the failed times of the steps in the memory store
+	MemoryPrefixFailedTimes = "failed_times"
+	// MemoryPrefixBackoffTimes is the prefix of the backoff times of the steps in the memory store
+	MemoryPrefixBackoffTimes = "backoff_times"
+	// MemoryPrefixBackoffReason is the prefix of the current backoff reason of the steps in the memory store
+	MemoryPrefixBackoffReason = "backoff_reason"
+	// MemoryKeyLastExecuteTime is the key of the last execute time of the workflow in the memory store
+	MemoryKeyLastExecuteTime = "last_execute_time"
+	// MemoryKeyNextExecuteTime is the key of the next execute time of the workflow in the memory store
+	MemoryKeyNextExecuteTime = "next_execute_time"
+)
+
+// persistedMemoryKeys are the retry and backoff states in the memory store, they are persisted in the context
+// store so that the controller restart or the leader failover doesn't reset them. The next execute time is persisted
+// to keep the backoff wait time, but the last execute time is not since it's updated in every reconcile.
+var persistedMemoryKeys = []string{
+	MemoryPrefixFailedTimes,
+	MemoryPrefixBackoffTimes,
+	MemoryPrefixBackoffReason,
+	MemoryKeyNextExecuteTime,
+}
+
+func persistedMemoryKey(key string) (string, bool) {
+	for _, k := range persistedMemoryKeys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// encodeMemory returns the persisted values in the memory store, it's empty if there're no such values.
+func (wf *WorkflowContext) encodeMemory() (string, error) {
+	if wf.memoryStore == nil {
+		return "", nil
+	}
+	values := map[string]interface{}{}
+	wf.memoryStore.Range(func(k, v interface{}) bool {
+		if key, ok := k.(string); ok {
+			if _, ok := persistedMemoryKey(key); ok {
+				values[key] = v
+			}
+		}
+		return true
+	})
+	if len(values) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// restoreMemory replaces the persisted values in the memory store with the ones in the context store
+func (wf *WorkflowContext) restoreMemory() error {
+	if wf.memoryStore == nil {
+		return nil
+	}
+	wf.memoryStore.Range(func(k, _ interface{}) bool {
+		if key, ok := k.(string); ok {
+			if _, ok := persistedMemoryKey(key); ok {
+				wf.memoryStore.Delete(k)
+			}
+		}
+		return true
+	})
+	data := wf.store.Data[ConfigMapKeyMemory]
+	if data == "" {
+		return nil
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return errors.WithMessage(err, "decode memory")
+	}
+	for key, raw := range values {
+		prefix, ok := persistedMemoryKey(key)
+		if !ok {
+			continue
+		}
+		var (
+			v   interface{}
+			err error
+		)
+		// keep the types of the values the same as the ones set in memory
+		switch prefix {
+		case MemoryPrefixFailedTimes, MemoryPrefixBackoffTimes:
+			var i int
+			err = json.Unmarshal(raw, &i)
+			v = i
+		case MemoryKeyNextExecuteTime:
+			var i int64
+			err = json.Unmarshal(raw, &i)
+			v = i
+		default:
+			var s string
+			err = json.Unmarshal(raw, &s)
+			v = s
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "decode memory %s", key)
+		}
+		wf.memoryStore.Store(key, v)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+)
+
+func TestPersistedMemory(t *testing.T) {
+	r := require.New(t)
+	cli := newCliForTest(t, nil)
+	defer CleanupMemoryStore("app-v1", "default")
+
+	wfCtx, err := NewContext(cli, "default", "app-v1", nil)
+	r.NoError(err)
+	wfCtx.SetValueInMemory(2, MemoryPrefixFailedTimes, "step-id")
+	wfCtx.SetValueInMemory("wait", MemoryPrefixBackoffReason, "step-id")
+	wfCtx.SetValueInMemory(int64(100), MemoryKeyNextExecuteTime)
+	wfCtx.SetValueInMemory(int64(50), MemoryKeyLastExecuteTime)
+	wfCtx.SetValueInMemory("not persisted", "other")
+	r.NoError(wfCtx.Commit())
+	r.NotContains(wfCtx.GetStore().Data[ConfigMapKeyMemory], "not persisted")
+
+	// the store is not written if the persisted values are unchanged
+	updated := 0
+	update := cli.MockUpdate
+	cli.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+		updated++
+		return update(ctx, obj, opts...)
+	}
+	wfCtx.SetValueInMemory(int64(150), MemoryKeyLastExecuteTime)
+	r.NoError(wfCtx.Commit())
+	r.Equal(0, updated)
+	wfCtx.SetValueInMemory(int64(200), MemoryKeyNextExecuteTime)
+	r.NoError(wfCtx.Commit())
+	r.Equal(1, updated)
+	wfCtx.SetValueInMemory(3, MemoryPrefixFailedTimes, "step-id")
+	r.NoError(wfCtx.Commit())
+	r.Equal(2, updated)
+	wfCtx.SetValueInMemory(2, MemoryPrefixFailedTimes, "step-id")
+	r.NoError(wfCtx.Commit())
+	r.Equal(3, updated)
+	v, err := value.NewValue(`"bar"`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(v, "foo"))
+	r.NoError(wfCtx.Commit())
+	r.Equal(4, updated)
+	r.NoError(wfCtx.SetVar(v, "foo"))
+	r.NoError(wfCtx.Commit())
+	r.Equal(4, updated)
+
+	// the values are restored after the controller restarts
+	CleanupMemoryStore("app-v1", "default")
+	loaded, err := LoadContext(cli, "default", "app-v1")
+	r.NoError(err)
+	m, ok := loaded.GetValueInMemory(MemoryPrefixFailedTimes, "step-id")
+	r.True(ok)
+	r.Equal(2, m)
+	r.Equal(3, loaded.IncreaseCountValueInMemory(MemoryPrefixFailedTimes, "step-id"))
+	m, ok = loaded.GetValueInMemory(MemoryPrefixBackoffReason, "step-id")
+	r.True(ok)
+	r.Equal("wait", m)
+	// the next execute time keeps the type to calculate the backoff wait time
+	m, ok = loaded.GetValueInMemory(MemoryKeyNextExecuteTime)
+	r.True(ok)
+	r.Equal(int64(200), m)
+	_, ok = loaded.GetValueInMemory(MemoryKeyLastExecuteTime)
+	r.False(ok)
+	_, ok = loaded.GetValueInMemory("other")
+	r.False(ok)
+
+	// the values cleaned up are not restored
+	CleanupMemoryStore("app-v1", "default")
+	r.NoError(loaded.Commit())
+	r.Empty(loaded.GetStore().Data[ConfigMapKeyMemory])
+	loaded, err = LoadContext(cli, "default", "app-v1")
+	r.NoError(err)
+	_, ok = loaded.GetValueInMemory(MemoryPrefixFailedTimes, "step-id")
+	r.False(ok)
+}
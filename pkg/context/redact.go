@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// RedactedValue replaces the secret values in the messages, logs and debug dumps
+	RedactedValue = "******"
+	// MinSecretValueLength is the minimum length of the secret values to redact, the shorter values are ignored
+	// since they are likely to be part of the other words
+	MinSecretValueLength = 6
+	// memoryKeySecretValues is the key of the secret values in the memory store
+	memoryKeySecretValues = "secretValues"
+)
+
+var secretValuesLock sync.Mutex
+
+// AddSecretValues records the values read from the secrets, they are redacted by Redact afterwards. The values
+// are only kept in the memory store of the workflow context, they're recorded again from the inputs of the steps
+// once the memory store is cleaned up.
+func AddSecretValues(ctx Context, values ...string) {
+	secretValuesLock.Lock()
+	defer secretValuesLock.Unlock()
+	existing := getSecretValues(ctx)
+	secrets := make(map[string]bool, len(existing)+len(values))
+	for k := range existing {
+		secrets[k] = true
+	}
+	for _, v := range values {
+		if len(v) < MinSecretValueLength {
+			continue
+		}
+		secrets[v] = true
+		// the value is escaped if it's encoded in json or cue strings
+		if b, err := json.Marshal(v); err == nil {
+			secrets[string(b[1:len(b)-1])] = true
+		}
+	}
+	ctx.SetValueInMemory(secrets, memoryKeySecretValues)
+}
+
+// Redact replaces the recorded secret values in the string
+func Redact(ctx Context, s string) string {
+	if ctx == nil || s == "" {
+		return s
+	}
+	secrets := getSecretValues(ctx)
+	if len(secrets) == 0 {
+		return s
+	}
+	values := make([]string, 0, len(secrets))
+	for v := range secrets {
+		values = append(values, v)
+	}
+	// replace the longer values first in case that a value is part of another one
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, RedactedValue)
+	}
+	return s
+}
+
+func getSecretValues(ctx Context) map[string]bool {
+	// the context may be created without the memory store
+	if wf, ok := ctx.(*WorkflowContext); ok && wf.memoryStore == nil {
+		return nil
+	}
+	v, ok := ctx.GetValueInMemory(memoryKeySecretValues)
+	if !ok {
+		return nil
+	}
+	secrets, _ := v.(map[string]bool)
+	return secrets
+}
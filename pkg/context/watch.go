@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"sort"
+	"sync"
+)
+
+// memoryKeyWatchedObjects is the key of the objects read by the steps in the memory store
+const memoryKeyWatchedObjects = "watchedObjects"
+
+var watchedObjectsLock sync.Mutex
+
+// WatchedObject is an object in the local cluster which a step reads, the workflow run is woken up by the changes
+// of the object when the step is waiting.
+type WatchedObject struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// AddWatchedObjects records the objects read by the step, they are only kept in the memory store of the workflow
+// context.
+func AddWatchedObjects(ctx Context, step string, objects ...WatchedObject) {
+	watchedObjectsLock.Lock()
+	defer watchedObjectsLock.Unlock()
+	existing := getWatchedObjects(ctx, step)
+	watched := make(map[WatchedObject]bool, len(existing)+len(objects))
+	for obj := range existing {
+		watched[obj] = true
+	}
+	for _, obj := range objects {
+		watched[obj] = true
+	}
+	ctx.SetValueInMemory(watched, memoryKeyWatchedObjects, step)
+}
+
+// DeleteWatchedObjects deletes the objects recorded by the step
+func DeleteWatchedObjects(ctx Context, step string) {
+	if getWatchedObjects(ctx, step) != nil {
+		ctx.DeleteValueInMemory(memoryKeyWatchedObjects, step)
+	}
+}
+
+// GetWatchedObjects returns the sorted objects recorded by the steps
+func GetWatchedObjects(ctx Context, steps ...string) []WatchedObject {
+	watched := make(map[WatchedObject]bool)
+	for _, step := range steps {
+		for obj := range getWatchedObjects(ctx, step) {
+			watched[obj] = true
+		}
+	}
+	objects := make([]WatchedObject, 0, len(watched))
+	for obj := range watched {
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return objects
+}
+
+func getWatchedObjects(ctx Context, step string) map[WatchedObject]bool {
+	// the context may be created without the memory store
+	if wf, ok := ctx.(*WorkflowContext); ok && wf.memoryStore == nil {
+		return nil
+	}
+	v, ok := ctx.GetValueInMemory(memoryKeyWatchedObjects, step)
+	if !ok {
+		return nil
+	}
+	watched, _ := v.(map[WatchedObject]bool)
+	return watched
+}
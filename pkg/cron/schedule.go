@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule is a parsed standard cron schedule with five fields:
+// minute, hour, day of month, month and day of week.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields are `*`, since a day matches
+	// either of the day fields only if both of them are restricted.
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also sunday in the day of week field
+	dowBounds = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses the cron schedule, the descriptors like `@daily` are also supported.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid cron schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, errors.WithMessage(err, "minute")
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, errors.WithMessage(err, "hour")
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, errors.WithMessage(err, "day of month")
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, errors.WithMessage(err, "month")
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, errors.WithMessage(err, "day of week")
+	}
+	if s.dow&(1<<7) > 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		rangeAndStep := strings.SplitN(expr, "/", 2)
+		var start, end uint
+		switch lowAndHigh := strings.SplitN(rangeAndStep[0], "-", 2); {
+		case rangeAndStep[0] == "*":
+			start, end = b.min, b.max
+		case len(lowAndHigh) == 2:
+			var err error
+			if start, err = parseValue(lowAndHigh[0], b); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = parseValue(lowAndHigh[0], b); err != nil {
+				return 0, err
+			}
+			end = start
+			// `a/n` means from a to the max value with step n
+			if len(rangeAndStep) == 2 {
+				end = b.max
+			}
+		}
+		step := uint(1)
+		if len(rangeAndStep) == 2 {
+			n, err := strconv.ParseUint(rangeAndStep[1], 10, 0)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step %q", rangeAndStep[1])
+			}
+			step = uint(n)
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q", expr)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if uint(v) < b.min || uint(v) > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, b.min, b.max)
+	}
+	return uint(v), nil
+}
+
+// Next returns the next activation time of the schedule which is later than the given time,
+// the result is in the location of the given time. It returns zero time if there's no
+// activation in the next five years, e.g. `0 0 30 2 *`.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) > 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
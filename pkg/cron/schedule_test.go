@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	base := time.Date(2022, 10, 14, 10, 30, 15, 0, time.UTC) // Friday
+	testCases := map[string]struct {
+		spec     string
+		expected time.Time
+	}{
+		"every-minute": {
+			spec:     "* * * * *",
+			expected: time.Date(2022, 10, 14, 10, 31, 0, 0, time.UTC),
+		},
+		"step": {
+			spec:     "*/20 * * * *",
+			expected: time.Date(2022, 10, 14, 10, 40, 0, 0, time.UTC),
+		},
+		"daily": {
+			spec:     "@daily",
+			expected: time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC),
+		},
+		"range-and-list": {
+			spec:     "0 2,4 * * mon-wed",
+			expected: time.Date(2022, 10, 17, 2, 0, 0, 0, time.UTC),
+		},
+		"sunday-as-7": {
+			spec:     "0 0 * * 7",
+			expected: time.Date(2022, 10, 16, 0, 0, 0, 0, time.UTC),
+		},
+		"day-of-month-or-week": {
+			spec:     "0 0 1 * sat",
+			expected: time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC),
+		},
+		"next-year": {
+			spec:     "0 0 1 jan *",
+			expected: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"never": {
+			spec:     "0 0 30 2 *",
+			expected: time.Time{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			s, err := Parse(tc.spec)
+			r.NoError(err)
+			r.Equal(tc.expected, s.Next(base))
+		})
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	r := require.New(t)
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	s, err := Parse("0 2 * * *")
+	r.NoError(err)
+	next := s.Next(time.Date(2022, 10, 14, 16, 0, 0, 0, time.UTC).In(loc))
+	r.Equal(time.Date(2022, 10, 15, 2, 0, 0, 0, loc), next)
+	r.Equal(time.Date(2022, 10, 14, 18, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestParseError(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+	} {
+		_, err := Parse(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -100,6 +100,12 @@ func NewPackageDiscover(config *rest.Config) (*PackageDiscover, error) {
 	return pd, nil
 }
 
+// NewLocalPackageDiscover creates a PackageDiscover without the packages of the K8s resources, the built-in packages
+// are still imported into the templates, e.g. to run the workflow without a cluster.
+func NewLocalPackageDiscover() *PackageDiscover {
+	return &PackageDiscover{pkgKinds: make(map[string][]VersionKind)}
+}
+
 // ImportBuiltinPackagesFor will add KubeVela built-in packages into your CUE instance
 func (pd *PackageDiscover) ImportBuiltinPackagesFor(bi *build.Instance) {
 	pd.mutex.RLock()
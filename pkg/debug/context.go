@@ -19,19 +19,36 @@ package debug
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
 	wfTypes "github.com/kubevela/workflow/pkg/types"
 )
 
+const (
+	// DataKeyDebug is the key of the snapshot of the latest attempt of the step in the debug ConfigMap
+	DataKeyDebug = "debug"
+	// DataKeyBreakpoint is the key of the snapshot of the step paused at the breakpoint in the debug ConfigMap
+	DataKeyBreakpoint = "breakpoint"
+	// dataKeyAttemptPrefix is the prefix of the keys of the snapshots of the attempts, e.g. debug-1
+	dataKeyAttemptPrefix = "debug-"
+)
+
+// MaxSnapshots is the max number of the snapshots of the attempts kept for each step, the oldest ones are removed
+var MaxSnapshots = 10
+
 // ContextImpl is workflow debug context interface
 type ContextImpl interface {
 	Set(v *value.Value) error
+	SetBreakpoint(v *value.Value) error
 }
 
 // Context is debug context.
@@ -39,23 +56,53 @@ type Context struct {
 	cli      client.Client
 	instance *wfTypes.WorkflowInstance
 	step     string
+	wfCtx    wfContext.Context
 }
 
-// Set sets debug content into context
+// Set sets the snapshot of the attempt of the step, the snapshots of the previous attempts are kept
 func (d *Context) Set(v *value.Value) error {
-	data, err := v.String()
+	data, err := d.encode(v)
 	if err != nil {
 		return err
 	}
-	err = setStore(context.Background(), d.cli, d.instance, d.step, data)
+	return setStore(context.Background(), d.cli, d.instance, d.step, func(store map[string]string) {
+		attempts := ListAttempts(store)
+		attempt := 1
+		if len(attempts) > 0 {
+			attempt = attempts[len(attempts)-1] + 1
+		}
+		for _, old := range attempts {
+			if old <= attempt-MaxSnapshots {
+				delete(store, AttemptKey(old))
+			}
+		}
+		store[DataKeyDebug] = data
+		store[AttemptKey(attempt)] = data
+		// the step is not paused anymore after it runs
+		delete(store, DataKeyBreakpoint)
+	})
+}
+
+// SetBreakpoint sets the snapshot of the step paused at the breakpoint
+func (d *Context) SetBreakpoint(v *value.Value) error {
+	data, err := d.encode(v)
 	if err != nil {
 		return err
 	}
+	return setStore(context.Background(), d.cli, d.instance, d.step, func(store map[string]string) {
+		store[DataKeyBreakpoint] = data
+	})
+}
 
-	return nil
+func (d *Context) encode(v *value.Value) (string, error) {
+	data, err := v.String()
+	if err != nil {
+		return "", err
+	}
+	return wfContext.Redact(d.wfCtx, data), nil
 }
 
-func setStore(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step, data string) error {
+func setStore(ctx context.Context, cli client.Client, instance *wfTypes.WorkflowInstance, step string, update func(data map[string]string)) error {
 	cm := &corev1.ConfigMap{}
 	if err := cli.Get(ctx, types.NamespacedName{
 		Namespace: instance.Namespace,
@@ -64,9 +111,8 @@ func setStore(ctx context.Context, cli client.Client, instance *wfTypes.Workflow
 		if errors.IsNotFound(err) {
 			cm.Name = GenerateContextName(instance.Name, step)
 			cm.Namespace = instance.Namespace
-			cm.Data = map[string]string{
-				"debug": data,
-			}
+			cm.Data = map[string]string{}
+			update(cm.Data)
 			cm.SetOwnerReferences(instance.ChildOwnerReferences)
 			if err := cli.Create(ctx, cm); err != nil {
 				return err
@@ -75,22 +121,43 @@ func setStore(ctx context.Context, cli client.Client, instance *wfTypes.Workflow
 		}
 		return err
 	}
-	cm.Data = map[string]string{
-		"debug": data,
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
 	}
+	update(cm.Data)
 	if err := cli.Update(ctx, cm); err != nil {
 		return err
 	}
-
 	return nil
 }
 
+// AttemptKey returns the key of the snapshot of the attempt in the debug ConfigMap
+func AttemptKey(attempt int) string {
+	return dataKeyAttemptPrefix + strconv.Itoa(attempt)
+}
+
+// ListAttempts returns the sorted attempts whose snapshots are kept in the data of the debug ConfigMap
+func ListAttempts(data map[string]string) []int {
+	var attempts []int
+	for key := range data {
+		if !strings.HasPrefix(key, dataKeyAttemptPrefix) {
+			continue
+		}
+		if attempt, err := strconv.Atoi(strings.TrimPrefix(key, dataKeyAttemptPrefix)); err == nil {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.Ints(attempts)
+	return attempts
+}
+
 // NewContext new workflow context without initialize data.
-func NewContext(cli client.Client, instance *wfTypes.WorkflowInstance, step string) ContextImpl {
+func NewContext(cli client.Client, instance *wfTypes.WorkflowInstance, step string, wfCtx wfContext.Context) ContextImpl {
 	return &Context{
 		cli:      cli,
 		instance: instance,
 		step:     step,
+		wfCtx:    wfCtx,
 	}
 }
 
@@ -18,6 +18,7 @@ package debug
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
@@ -46,7 +47,7 @@ func TestSetContext(t *testing.T) {
 		WorkflowMeta: types.WorkflowMeta{
 			Name: "test",
 		},
-	}, "step1")
+	}, "step1", nil)
 	v, err := value.NewValue(`
 test: test
 `, nil, "")
@@ -58,7 +59,7 @@ test: test
 		WorkflowMeta: types.WorkflowMeta{
 			Name: "test",
 		},
-	}, "step2")
+	}, "step2", nil)
 	v, err = value.NewValue(`
 test: test
 `, nil, "")
@@ -67,6 +68,45 @@ test: test
 	r.NoError(err)
 }
 
+func TestSetContextAttempts(t *testing.T) {
+	r := require.New(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: GenerateContextName("test", "step1"),
+		},
+		Data: map[string]string{
+			DataKeyDebug: "test",
+		},
+	}
+	cli := newCliForTest(cm)
+	debugCtx := NewContext(cli, &types.WorkflowInstance{
+		WorkflowMeta: types.WorkflowMeta{
+			Name: "test",
+		},
+	}, "step1", nil)
+	v, err := value.NewValue(`
+test: "breakpoint"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(debugCtx.SetBreakpoint(v))
+	r.Contains(cm.Data[DataKeyBreakpoint], "breakpoint")
+	r.Equal("test", cm.Data[DataKeyDebug])
+
+	defer func(max int) { MaxSnapshots = max }(MaxSnapshots)
+	MaxSnapshots = 2
+	for _, s := range []string{"attempt1", "attempt2", "attempt3"} {
+		v, err := value.NewValue(fmt.Sprintf("test: %q\n", s), nil, "")
+		r.NoError(err)
+		r.NoError(debugCtx.Set(v))
+	}
+	r.Equal([]int{2, 3}, ListAttempts(cm.Data))
+	r.Contains(cm.Data[AttemptKey(2)], "attempt2")
+	r.Contains(cm.Data[AttemptKey(3)], "attempt3")
+	r.Equal(cm.Data[AttemptKey(3)], cm.Data[DataKeyDebug])
+	_, ok := cm.Data[DataKeyBreakpoint]
+	r.False(ok)
+}
+
 func newCliForTest(wfCm *corev1.ConfigMap) *test.MockClient {
 	return &test.MockClient{
 		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
@@ -21,6 +21,7 @@ import (
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -33,4 +34,7 @@ type WorkflowExecutor interface {
 	GetBackoffWaitTime() time.Duration
 
 	GetSuspendBackoffWaitTime() time.Duration
+
+	// GetWatchedObjects returns the objects read by the waiting steps, the changes of them wake up the workflow.
+	GetWatchedObjects() []wfContext.WatchedObject
 }
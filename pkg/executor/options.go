@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// Option is the option of the workflow executor
+type Option func(w *workflowExecutor)
+
+// Hooks are the extra hooks of the steps registered by the embedders of the executor, e.g. the policy checks before
+// the steps, the audit events after the steps. The hooks of each kind run after the built-in ones in the order of
+// registration, and their failures are handled the same as the built-in hooks:
+//   - PreCheckHooks: an error skips the step with the error in the message, same as an invalid if condition, so a
+//     hook should return a result with Fail to fail the step instead, e.g. when the step is denied by a policy. The
+//     failed step terminates the workflow with the reason and the message of the result. A result with Skip skips
+//     the step and a result with Timeout fails the step as timeout.
+//   - PreStartHooks: an error stops the step before it's executed and fails the execution of the workflow, which is
+//     retried in the next reconcile.
+//   - PostStopHooks: the hooks get the final status of the step in the execution, an error is recorded in the message
+//     of the step and the rest post stop hooks are not run, the phase of the step is not changed.
+type Hooks struct {
+	PreCheckHooks []types.TaskPreCheckHook
+	PreStartHooks []types.TaskPreStartHook
+	PostStopHooks []types.TaskPostStopHook
+}
+
+// HookFactory creates the extra hooks of the steps for the workflow instance, it's called in each execution of the
+// instance so that the hooks can access the instance.
+type HookFactory func(instance *types.WorkflowInstance) Hooks
+
+// WithHooks registers the factories of the extra hooks of the steps, the hooks are run in the order of the factories.
+func WithHooks(factories ...HookFactory) Option {
+	return func(w *workflowExecutor) {
+		w.hookFactories = append(w.hookFactories, factories...)
+	}
+}
+
+// makeHooks creates the extra hooks of the steps for the instance
+func (w *workflowExecutor) makeHooks() Hooks {
+	var hooks Hooks
+	for _, factory := range w.hookFactories {
+		h := factory(w.instance)
+		hooks.PreCheckHooks = append(hooks.PreCheckHooks, h.PreCheckHooks...)
+		hooks.PreStartHooks = append(hooks.PreStartHooks, h.PreStartHooks...)
+		hooks.PostStopHooks = append(hooks.PostStopHooks, h.PostStopHooks...)
+	}
+	return hooks
+}
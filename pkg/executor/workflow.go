@@ -17,19 +17,23 @@ limitations under the License.
 package executor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/util/feature"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
@@ -37,6 +41,7 @@ import (
 	"github.com/kubevela/workflow/pkg/features"
 	"github.com/kubevela/workflow/pkg/hooks"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/providers/dryrun"
 	"github.com/kubevela/workflow/pkg/tasks/builtin"
 	"github.com/kubevela/workflow/pkg/tasks/custom"
 	"github.com/kubevela/workflow/pkg/types"
@@ -57,17 +62,22 @@ const (
 )
 
 type workflowExecutor struct {
-	instance *types.WorkflowInstance
-	cli      client.Client
-	wfCtx    wfContext.Context
+	instance      *types.WorkflowInstance
+	cli           client.Client
+	wfCtx         wfContext.Context
+	hookFactories []HookFactory
 }
 
 // New returns a Workflow Executor implementation.
-func New(instance *types.WorkflowInstance, cli client.Client) WorkflowExecutor {
-	return &workflowExecutor{
+func New(instance *types.WorkflowInstance, cli client.Client, opts ...Option) WorkflowExecutor {
+	w := &workflowExecutor{
 		instance: instance,
 		cli:      cli,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 // InitializeWorkflowInstance init workflow instance
@@ -85,6 +95,7 @@ func InitializeWorkflowInstance(instance *types.WorkflowInstance) {
 			if instance.Mode.SubSteps != "" {
 				mode.SubSteps = instance.Mode.SubSteps
 			}
+			mode.MaxParallelism = instance.Mode.MaxParallelism
 		}
 		instance.Status = v1alpha1.WorkflowRunStatus{
 			Mode:      mode,
@@ -98,6 +109,122 @@ func InitializeWorkflowInstance(instance *types.WorkflowInstance) {
 // ExecuteRunners execute workflow task runners in order.
 func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunners []types.TaskRunner) (v1alpha1.WorkflowRunPhase, error) {
 	InitializeWorkflowInstance(w.instance)
+	taskRunners, handlers := w.splitExitHandlers(taskRunners)
+	state, err := w.executeSteps(ctx, taskRunners)
+	if err != nil {
+		return state, w.redactError(err)
+	}
+	switch state {
+	case v1alpha1.WorkflowStateSucceeded, v1alpha1.WorkflowStateFailed, v1alpha1.WorkflowStateTerminated:
+		state, err = w.executeExitHandlers(ctx, state, handlers)
+		return state, w.redactError(err)
+	default:
+		return state, nil
+	}
+}
+
+// redactError replaces the secret values in the error, the error is recorded in the events and the conditions
+func (w *workflowExecutor) redactError(err error) error {
+	if err == nil || w.wfCtx == nil {
+		return err
+	}
+	if msg := wfContext.Redact(w.wfCtx, err.Error()); msg != err.Error() {
+		return errors.New(msg)
+	}
+	return err
+}
+
+type exitHandlers struct {
+	onSuccess []types.TaskRunner
+	onFailure []types.TaskRunner
+	finally   []types.TaskRunner
+}
+
+// splitExitHandlers splits the runners of the exit handlers from the runners of the workflow steps, the runners
+// of the exit handlers are generated after the ones of the workflow steps in order. They're split by the positions
+// rather than the names, so that a handler can't be mistaken for a workflow step with the same name.
+func (w *workflowExecutor) splitExitHandlers(taskRunners []types.TaskRunner) ([]types.TaskRunner, exitHandlers) {
+	var handlers exitHandlers
+	n := len(taskRunners) - len(w.instance.OnSuccess) - len(w.instance.OnFailure) - len(w.instance.Finally)
+	if n == len(taskRunners) || n < 0 {
+		return taskRunners, handlers
+	}
+	rest := taskRunners[n:]
+	for _, kind := range []struct {
+		runners *[]types.TaskRunner
+		steps   []v1alpha1.WorkflowStep
+	}{
+		{&handlers.onSuccess, w.instance.OnSuccess},
+		{&handlers.onFailure, w.instance.OnFailure},
+		{&handlers.finally, w.instance.Finally},
+	} {
+		*kind.runners, rest = rest[:len(kind.steps)], rest[len(kind.steps):]
+	}
+	return taskRunners[:n], handlers
+}
+
+// executeExitHandlers executes the exit handlers after the workflow steps finished,
+// the handlers of the outcome run first and the finally handlers run after them.
+func (w *workflowExecutor) executeExitHandlers(ctx monitorContext.Context, state v1alpha1.WorkflowRunPhase, handlers exitHandlers) (v1alpha1.WorkflowRunPhase, error) {
+	runners := handlers.onFailure
+	if state == v1alpha1.WorkflowStateSucceeded {
+		runners = handlers.onSuccess
+	}
+	if len(runners) == 0 && len(handlers.finally) == 0 {
+		return state, nil
+	}
+	status := &w.instance.Status
+	exitStatus := &v1alpha1.WorkflowRunStatus{
+		Mode:      status.Mode,
+		StartTime: status.StartTime,
+		Steps:     status.ExitHandlers,
+	}
+	for _, stage := range [][]types.TaskRunner{runners, handlers.finally} {
+		if done, _ := stepsAllDone(exitStatus.Steps, stage); done {
+			continue
+		}
+		if w.wfCtx == nil {
+			wfCtx, err := w.makeContext(w.instance.Name)
+			if err != nil {
+				ctx.Error(err, "make context")
+				return v1alpha1.WorkflowStateExecuting, err
+			}
+			w.wfCtx = wfCtx
+		}
+		e := newEngine(ctx, w.wfCtx, w, exitStatus)
+		e.setWorkflowOutcome(*status, state)
+		// the exit handlers should not be limited by the timeout of the workflow
+		e.deadline = time.Time{}
+		err := e.Run(stage, status.Mode.Steps == v1alpha1.WorkflowModeDAG)
+		if commitErr := w.wfCtx.Commit(); commitErr != nil && err == nil {
+			err = errors.WithMessage(commitErr, "commit workflow context")
+		}
+		status.ExitHandlers = exitStatus.Steps
+		if err != nil {
+			ctx.Error(err, "run exit handlers")
+			return v1alpha1.WorkflowStateExecuting, err
+		}
+		if done, _ := stepsAllDone(exitStatus.Steps, stage); !done {
+			return v1alpha1.WorkflowStateExecuting, nil
+		}
+	}
+	w.cleanupMemoryStore(ctx)
+	return state, nil
+}
+
+// cleanupMemoryStore cleans up the memory store of the workflow, the retry and backoff states persisted in the
+// context are cleaned up together.
+func (w *workflowExecutor) cleanupMemoryStore(ctx monitorContext.Context) {
+	wfContext.CleanupMemoryStore(w.instance.Name, w.instance.Namespace)
+	if w.wfCtx == nil {
+		return
+	}
+	if err := w.wfCtx.Commit(); err != nil {
+		ctx.Error(err, "commit workflow context")
+	}
+}
+
+func (w *workflowExecutor) executeSteps(ctx monitorContext.Context, taskRunners []types.TaskRunner) (v1alpha1.WorkflowRunPhase, error) {
 	status := &w.instance.Status
 	dagMode := status.Mode.Steps == v1alpha1.WorkflowModeDAG
 	cacheKey := fmt.Sprintf("%s-%s", w.instance.Name, w.instance.Namespace)
@@ -112,6 +239,11 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 		}
 		return v1alpha1.WorkflowStateFailed, nil
 	}
+	if isWorkflowTimeout(getWorkflowDeadline(w.instance)) {
+		// the timeout workflow should go on to terminate the remaining steps even if it's suspended
+		status.Suspend = false
+		status.SuspendState = ""
+	}
 	if checkWorkflowSuspended(status) {
 		return v1alpha1.WorkflowStateSuspending, nil
 	}
@@ -136,6 +268,13 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 	e := newEngine(ctx, wfCtx, w, status)
 
 	err = e.Run(taskRunners, dagMode)
+	if status.Terminated {
+		e.cleanBackoffTimesForTerminated()
+	}
+	// the changes of all the steps in the reconcile are persisted together
+	if commitErr := wfCtx.Commit(); commitErr != nil && err == nil {
+		err = errors.WithMessage(commitErr, "commit workflow context")
+	}
 	if err != nil {
 		ctx.Error(err, "run steps")
 		StepStatusCache.Store(cacheKey, len(status.Steps))
@@ -146,9 +285,17 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 	StepStatusCache.Store(cacheKey, len(status.Steps))
 	allTasksDone, allTasksSucceeded = w.allDone(taskRunners)
 	if status.Terminated {
-		e.cleanBackoffTimesForTerminated()
 		if checkWorkflowTerminated(status, allTasksDone) {
-			wfContext.CleanupMemoryStore(e.instance.Name, e.instance.Namespace)
+			w.cleanupMemoryStore(ctx)
+			if isWorkflowTimeout(e.deadline) {
+				status.SetConditions(condition.Condition{
+					Type:               condition.ConditionType(v1alpha1.WorkflowRunConditionType),
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.Now(),
+					Reason:             condition.ConditionReason(types.StatusReasonTimeout),
+					Message:            types.MessageTimeout,
+				})
+			}
 			if isTerminatedManually(status) {
 				return v1alpha1.WorkflowStateTerminated, nil
 			}
@@ -156,7 +303,7 @@ func (w *workflowExecutor) ExecuteRunners(ctx monitorContext.Context, taskRunner
 		}
 	}
 	if status.Suspend {
-		wfContext.CleanupMemoryStore(e.instance.Name, e.instance.Namespace)
+		w.cleanupMemoryStore(ctx)
 		return v1alpha1.WorkflowStateSuspending, nil
 	}
 	if allTasksSucceeded {
@@ -185,34 +332,54 @@ func checkWorkflowTerminated(status *v1alpha1.WorkflowRunStatus, allTasksDone bo
 }
 
 func checkWorkflowSuspended(status *v1alpha1.WorkflowRunStatus) bool {
+	// the workflow suspended manually keeps suspending until it's resumed, even if there's suspend step running
+	if status.Suspend && status.SuspendState == types.SuspendStateManual {
+		return true
+	}
 	// if workflow is suspended and the suspended step is still running, return false to run the suspended step
 	if status.Suspend {
-		for _, step := range status.Steps {
-			if step.Type == types.WorkflowStepTypeSuspend && step.Phase == v1alpha1.WorkflowStepPhaseRunning {
-				return false
-			}
-			for _, sub := range step.SubStepsStatus {
-				if sub.Type == types.WorkflowStepTypeSuspend && sub.Phase == v1alpha1.WorkflowStepPhaseRunning {
-					return false
-				}
-			}
+		if !types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+			return !isWaitSuspendStep(step.StepStatus)
+		}) {
+			return false
 		}
 	}
 	return status.Suspend
 }
 
+// getWorkflowDeadline returns the deadline of the workflow run, the zero time means there's no deadline
+func getWorkflowDeadline(instance *types.WorkflowInstance) time.Time {
+	if instance.Timeout == "" || instance.Status.StartTime.IsZero() {
+		return time.Time{}
+	}
+	duration, err := time.ParseDuration(instance.Timeout)
+	if err != nil {
+		return time.Time{}
+	}
+	return instance.Status.StartTime.Add(duration)
+}
+
+func isWorkflowTimeout(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
 func newEngine(ctx monitorContext.Context, wfCtx wfContext.Context, w *workflowExecutor, wfStatus *v1alpha1.WorkflowRunStatus) *engine {
 	stepStatus := make(map[string]v1alpha1.StepStatus)
 	setStepStatus(stepStatus, wfStatus.Steps)
 	stepDependsOn := make(map[string][]string)
-	for _, step := range w.instance.Steps {
+	stepRetry := make(map[string]*v1alpha1.RetryPolicy)
+	steps := append([]v1alpha1.WorkflowStep{}, w.instance.Steps...)
+	steps = append(steps, w.instance.OnSuccess...)
+	steps = append(steps, w.instance.OnFailure...)
+	steps = append(steps, w.instance.Finally...)
+	for _, step := range steps {
 		hooks.SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, stepStatus[step.Name])
-		stepDependsOn[step.Name] = append(stepDependsOn[step.Name], step.DependsOn...)
-		for _, sub := range step.SubSteps {
-			hooks.SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, stepStatus[step.Name])
-			stepDependsOn[sub.Name] = append(stepDependsOn[sub.Name], sub.DependsOn...)
-		}
 	}
+	// the sub steps can depend on the steps in the other levels, so the dependencies are recorded by the names
+	types.RangeSteps(steps, func(step v1alpha1.WorkflowStep) {
+		stepDependsOn[step.Name] = append(stepDependsOn[step.Name], step.DependsOn...)
+		stepRetry[step.Name] = step.Retry
+	})
 	return &engine{
 		status:        wfStatus,
 		monitorCtx:    ctx,
@@ -222,17 +389,57 @@ func newEngine(ctx monitorContext.Context, wfCtx wfContext.Context, w *workflowE
 		debug:         w.instance.Debug,
 		stepStatus:    stepStatus,
 		stepDependsOn: stepDependsOn,
+		stepRetry:     stepRetry,
 		stepTimeout:   make(map[string]time.Time),
+		deadline:      getWorkflowDeadline(w.instance),
+		hooks:         w.makeHooks(),
 	}
 }
 
-func setStepStatus(statusMap map[string]v1alpha1.StepStatus, status []v1alpha1.WorkflowStepStatus) {
-	for _, ss := range status {
-		statusMap[ss.Name] = ss.StepStatus
-		for _, sss := range ss.SubStepsStatus {
-			statusMap[sss.Name] = sss
+// setWorkflowOutcome lets the exit handlers read the status of the workflow steps and the outcome of the workflow
+func (e *engine) setWorkflowOutcome(wfStatus v1alpha1.WorkflowRunStatus, state v1alpha1.WorkflowRunPhase) {
+	stepStatus := make(map[string]v1alpha1.StepStatus)
+	setStepStatus(stepStatus, wfStatus.Steps)
+	outcome := v1alpha1.StepStatus{
+		Name:    types.StatusKeyWorkflow,
+		Phase:   v1alpha1.WorkflowStepPhaseSucceeded,
+		Message: wfStatus.Message,
+	}
+	if state != v1alpha1.WorkflowStateSucceeded {
+		outcome.Phase = v1alpha1.WorkflowStepPhaseFailed
+		switch {
+		case wfStatus.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunConditionType)).Reason == condition.ConditionReason(types.StatusReasonTimeout):
+			outcome.Reason = types.StatusReasonTimeout
+		case state == v1alpha1.WorkflowStateTerminated:
+			outcome.Reason = types.StatusReasonTerminate
 		}
 	}
+	stepStatus[types.StatusKeyWorkflow] = outcome
+	e.workflowStepStatus = stepStatus
+}
+
+// ifStepStatus returns the status read by the if expressions of the steps, the exit handlers read the status of
+// the workflow steps and the outcome of the workflow besides the status of the handlers.
+func (e *engine) ifStepStatus() map[string]v1alpha1.StepStatus {
+	if len(e.workflowStepStatus) == 0 {
+		return e.stepStatus
+	}
+	stepStatus := make(map[string]v1alpha1.StepStatus, len(e.stepStatus)+len(e.workflowStepStatus))
+	for name, ss := range e.stepStatus {
+		stepStatus[name] = ss
+	}
+	// the outcome of the workflow can't be overwritten by the status of a handler with the same name
+	for name, ss := range e.workflowStepStatus {
+		stepStatus[name] = ss
+	}
+	return stepStatus
+}
+
+func setStepStatus(statusMap map[string]v1alpha1.StepStatus, status []v1alpha1.WorkflowStepStatus) {
+	types.RangeStepStatus(status, func(ss *v1alpha1.WorkflowStepStatus) bool {
+		statusMap[ss.Name] = ss.StepStatus
+		return true
+	})
 }
 
 func (w *workflowExecutor) GetSuspendBackoffWaitTime() time.Duration {
@@ -243,21 +450,14 @@ func (w *workflowExecutor) GetSuspendBackoffWaitTime() time.Duration {
 	setStepStatus(stepStatus, w.instance.Status.Steps)
 	max := time.Duration(1<<63 - 1)
 	min := max
-	for _, step := range w.instance.Steps {
+	types.RangeSteps(w.instance.Steps, func(step v1alpha1.WorkflowStep) {
 		if step.Type == types.WorkflowStepTypeSuspend || step.Type == types.WorkflowStepTypeStepGroup {
 			min = handleSuspendBackoffTime(step, stepStatus[step.Name], min)
 		}
-		for _, sub := range step.SubSteps {
-			if sub.Type == types.WorkflowStepTypeSuspend {
-				min = handleSuspendBackoffTime(v1alpha1.WorkflowStep{
-					WorkflowStepBase: v1alpha1.WorkflowStepBase{
-						Name:       sub.Name,
-						Type:       sub.Type,
-						Timeout:    sub.Timeout,
-						Properties: sub.Properties,
-					},
-				}, stepStatus[sub.Name], min)
-			}
+	})
+	if deadline := getWorkflowDeadline(w.instance); !deadline.IsZero() {
+		if d := time.Until(deadline); d > 0 && d < min {
+			min = d
 		}
 	}
 	if min == max {
@@ -314,14 +514,36 @@ func (w *workflowExecutor) GetBackoffWaitTime() time.Duration {
 	return time.Second
 }
 
+func (w *workflowExecutor) GetWatchedObjects() []wfContext.WatchedObject {
+	if w.wfCtx == nil {
+		return nil
+	}
+	return wfContext.GetWatchedObjects(w.wfCtx, getWaitingSteps(w.instance.Status.Steps)...)
+}
+
+// getWaitingSteps returns the names of the steps waiting for the conditions in all levels
+func getWaitingSteps(steps []v1alpha1.WorkflowStepStatus) []string {
+	var waiting []string
+	types.RangeStepStatus(steps, func(ss *v1alpha1.WorkflowStepStatus) bool {
+		if ss.Phase == v1alpha1.WorkflowStepPhaseRunning && ss.Reason == types.StatusReasonWait {
+			waiting = append(waiting, ss.Name)
+		}
+		return true
+	})
+	return waiting
+}
+
 func (w *workflowExecutor) allDone(taskRunners []types.TaskRunner) (bool, bool) {
+	return stepsAllDone(w.instance.Status.Steps, taskRunners)
+}
+
+func stepsAllDone(steps []v1alpha1.WorkflowStepStatus, taskRunners []types.TaskRunner) (bool, bool) {
 	success := true
-	status := w.instance.Status
 	for _, t := range taskRunners {
 		done := false
-		for _, ss := range status.Steps {
+		for _, ss := range steps {
 			if ss.Name == t.Name() {
-				done = types.IsStepFinish(ss.Phase, ss.Reason)
+				done = types.IsStepStatusFinish(ss.StepStatus)
 				success = success && done && (ss.Phase == v1alpha1.WorkflowStepPhaseSucceeded || ss.Phase == v1alpha1.WorkflowStepPhaseSkipped)
 				break
 			}
@@ -340,6 +562,7 @@ func (w *workflowExecutor) makeContext(name string) (wfContext.Context, error) {
 		if err != nil {
 			return nil, errors.WithMessage(err, "load context")
 		}
+		w.restoreSecretValues(wfCtx)
 		return wfCtx, nil
 	}
 
@@ -351,13 +574,27 @@ func (w *workflowExecutor) makeContext(name string) (wfContext.Context, error) {
 	if err = w.setMetadataToContext(wfCtx); err != nil {
 		return nil, err
 	}
+	if err = w.setInitialContext(wfCtx); err != nil {
+		return nil, err
+	}
 	if err = wfCtx.Commit(); err != nil {
 		return nil, err
 	}
 	status.ContextBackend = wfCtx.StoreRef()
+	w.restoreSecretValues(wfCtx)
 	return wfCtx, nil
 }
 
+// restoreSecretValues records the values of the inputs from secrets before any step runs, so that they are
+// redacted in the status even if the steps read them before the memory store is cleaned up.
+func (w *workflowExecutor) restoreSecretValues(wfCtx wfContext.Context) {
+	var steps []v1alpha1.WorkflowStep
+	for _, s := range [][]v1alpha1.WorkflowStep{w.instance.Steps, w.instance.OnSuccess, w.instance.OnFailure, w.instance.Finally} {
+		steps = append(steps, s...)
+	}
+	hooks.RestoreSecretValues(wfCtx, steps, w.cli, w.instance.Namespace)
+}
+
 func (w *workflowExecutor) setMetadataToContext(wfCtx wfContext.Context) error {
 	copierMeta := types.WorkflowMeta{
 		Name:        w.instance.Name,
@@ -376,6 +613,17 @@ func (w *workflowExecutor) setMetadataToContext(wfCtx wfContext.Context) error {
 	return wfCtx.SetVar(metadata, types.ContextKeyMetadata)
 }
 
+func (w *workflowExecutor) setInitialContext(wfCtx wfContext.Context) error {
+	if w.instance.Context == nil || len(w.instance.Context.Raw) == 0 {
+		return nil
+	}
+	v, err := value.NewValue(string(w.instance.Context.Raw), nil, "")
+	if err != nil {
+		return errors.WithMessage(err, "invalid initial context")
+	}
+	return wfCtx.SetVar(v)
+}
+
 func (e *engine) getBackoffTimes(stepID string) int {
 	if v, ok := e.wfCtx.GetValueInMemory(types.ContextPrefixBackoffTimes, stepID); ok {
 		times, ok := v.(int)
@@ -386,44 +634,87 @@ func (e *engine) getBackoffTimes(stepID string) int {
 	return -1
 }
 
+func (e *engine) getFailedTimes(stepID string) int {
+	if v, ok := e.wfCtx.GetValueInMemory(types.ContextPrefixFailedTimes, stepID); ok {
+		times, ok := v.(int)
+		if ok {
+			return times
+		}
+	}
+	return 0
+}
+
 func (e *engine) getBackoffWaitTime() int {
 	// the default value of min times reaches the max workflow backoff wait time
 	minTimes := 15
 	found := false
-	for _, step := range e.status.Steps {
-		if backoffTimes := e.getBackoffTimes(step.ID); backoffTimes > 0 {
-			found = true
-			if backoffTimes < minTimes {
-				minTimes = backoffTimes
-			}
+	// the min wait time of the failed steps with retry policy
+	minRetryInterval := -1
+	// the waiting steps reading the watched objects are woken up by the changes of the objects
+	watched := false
+	checkBackoff := func(status v1alpha1.StepStatus) {
+		backoffTimes := e.getBackoffTimes(status.ID)
+		if backoffTimes <= 0 {
+			return
 		}
-		if step.SubStepsStatus != nil {
-			for _, subStep := range step.SubStepsStatus {
-				if backoffTimes := e.getBackoffTimes(subStep.ID); backoffTimes > 0 {
-					found = true
-					if backoffTimes < minTimes {
-						minTimes = backoffTimes
-					}
+		if e.isWatched(status) {
+			watched = true
+			return
+		}
+		if status.Phase == v1alpha1.WorkflowStepPhaseFailed {
+			if interval, ok := types.GetRetryBackoffTime(e.stepRetry[status.Name], e.getFailedTimes(status.ID)); ok {
+				if minRetryInterval < 0 || interval < minRetryInterval {
+					minRetryInterval = interval
 				}
+				return
 			}
 		}
+		found = true
+		if backoffTimes < minTimes {
+			minTimes = backoffTimes
+		}
 	}
+	types.RangeStepStatus(e.status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		checkBackoff(step.StepStatus)
+		return true
+	})
 
 	if !found {
+		if minRetryInterval > 0 {
+			return minRetryInterval
+		}
+		if watched {
+			// poll the watched objects in the max wait time in case that the changes are missed
+			return types.MaxWorkflowWaitBackoffTime
+		}
 		return minWorkflowBackoffWaitTime
 	}
 
 	interval := int(math.Pow(2, float64(minTimes)) * backoffTimeCoefficient)
 	if interval < minWorkflowBackoffWaitTime {
-		return minWorkflowBackoffWaitTime
+		interval = minWorkflowBackoffWaitTime
 	}
 	maxWorkflowBackoffWaitTime := e.getMaxBackoffWaitTime()
 	if interval > maxWorkflowBackoffWaitTime {
-		return maxWorkflowBackoffWaitTime
+		interval = maxWorkflowBackoffWaitTime
+	}
+	if minRetryInterval > 0 && minRetryInterval < interval {
+		return minRetryInterval
 	}
 	return interval
 }
 
+// isWatched returns true if the step is waiting for the objects watched by the controller
+func (e *engine) isWatched(status v1alpha1.StepStatus) bool {
+	if !feature.DefaultMutableFeatureGate.Enabled(features.EnableWatchWaitingResources) {
+		return false
+	}
+	if status.Phase != v1alpha1.WorkflowStepPhaseRunning || status.Reason != types.StatusReasonWait {
+		return false
+	}
+	return len(wfContext.GetWatchedObjects(e.wfCtx, status.Name)) > 0
+}
+
 func (e *engine) getMaxBackoffWaitTime() int {
 	for _, step := range e.status.Steps {
 		if step.Phase == v1alpha1.WorkflowStepPhaseFailed {
@@ -447,6 +738,11 @@ func (e *engine) getNextTimeout() int64 {
 			}
 		}
 	}
+	if !e.deadline.IsZero() {
+		if duration := e.deadline.Sub(now); duration < min {
+			min = duration
+		}
+	}
 	if min == max {
 		return -1
 	}
@@ -488,11 +784,12 @@ func (e *engine) runAsDAG(taskRunners []types.TaskRunner, pendingRunners bool) e
 		var stepID string
 		if status, ok := e.stepStatus[tRunner.Name()]; ok {
 			stepID = status.ID
-			finish = types.IsStepFinish(status.Phase, status.Reason)
+			finish = types.IsStepStatusFinish(status)
 		}
 		if !finish {
 			done = false
-			if pending, status := tRunner.Pending(wfCtx, e.stepStatus); pending {
+			// the pending steps will be terminated directly if the workflow is timeout
+			if pending, status := tRunner.Pending(wfCtx, e.stepStatus); pending && !isWorkflowTimeout(e.deadline) {
 				if pendingRunners {
 					wfCtx.IncreaseCountValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 					e.updateStepStatus(status)
@@ -544,6 +841,8 @@ func (e *engine) Run(taskRunners []types.TaskRunner, dag bool) error {
 
 func (e *engine) checkWorkflowStatusMessage(wfStatus *v1alpha1.WorkflowRunStatus) {
 	switch {
+	case wfStatus.Terminated && isWorkflowTimeout(e.deadline):
+		e.status.Message = types.MessageTimeout
 	case !e.waiting && e.failedAfterRetries && feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure):
 		e.status.Message = types.MessageSuspendFailedAfterRetries
 	case wfStatus.Terminated && !feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure):
@@ -557,11 +856,11 @@ func (e *engine) steps(taskRunners []types.TaskRunner, dag bool) error {
 	wfCtx := e.wfCtx
 	for index, runner := range taskRunners {
 		if status, ok := e.stepStatus[runner.Name()]; ok {
-			if types.IsStepFinish(status.Phase, status.Reason) {
+			if types.IsStepStatusFinish(status) {
 				continue
 			}
 		}
-		if pending, status := runner.Pending(wfCtx, e.stepStatus); pending {
+		if pending, status := runner.Pending(wfCtx, e.stepStatus); pending && !isWorkflowTimeout(e.deadline) {
 			wfCtx.IncreaseCountValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 			e.updateStepStatus(status)
 			if dag {
@@ -569,6 +868,9 @@ func (e *engine) steps(taskRunners []types.TaskRunner, dag bool) error {
 			}
 			return nil
 		}
+		if dag && e.getMaxParallelism() > 1 {
+			return e.parallelSteps(taskRunners[index:])
+		}
 		options := e.generateRunOptions(e.findDependPhase(taskRunners, index, dag))
 
 		status, operation, err := runner.Run(wfCtx, options)
@@ -576,26 +878,12 @@ func (e *engine) steps(taskRunners []types.TaskRunner, dag bool) error {
 			return err
 		}
 
-		e.updateStepStatus(status)
-
-		e.failedAfterRetries = e.failedAfterRetries || operation.FailedAfterRetries
-		e.waiting = e.waiting || operation.Waiting
-		// for the suspend step with duration, there's no need to increase the backoff time in reconcile when it's still running
-		if !types.IsStepFinish(status.Phase, status.Reason) && !isWaitSuspendStep(status) {
-			if err := handleBackoffTimes(wfCtx, status, false); err != nil {
-				return err
-			}
+		if !e.applyStepResult(status, operation) {
 			if dag {
 				continue
 			}
 			return nil
 		}
-		// clear the backoff time when the step is finished
-		if err := handleBackoffTimes(wfCtx, status, true); err != nil {
-			return err
-		}
-
-		e.finishStep(operation)
 		if dag {
 			continue
 		}
@@ -606,6 +894,257 @@ func (e *engine) steps(taskRunners []types.TaskRunner, dag bool) error {
 	return nil
 }
 
+// applyStepResult records the result of the step, it returns false if the step is still running.
+func (e *engine) applyStepResult(status v1alpha1.StepStatus, operation *types.Operation) bool {
+	e.updateStepStatus(status)
+
+	e.failedAfterRetries = e.failedAfterRetries || operation.FailedAfterRetries
+	e.waiting = e.waiting || operation.Waiting
+	// for the suspend step with duration, there's no need to increase the backoff time in reconcile when it's still running
+	if !types.IsStepStatusFinish(status) && !isWaitSuspendStep(status) {
+		handleBackoffTimes(e.wfCtx, status, false)
+		return false
+	}
+	// clear the backoff time and the watched objects when the step is finished
+	handleBackoffTimes(e.wfCtx, status, true)
+	wfContext.DeleteWatchedObjects(e.wfCtx, status.Name)
+	// the decision at the breakpoint is kept while the step is running, so that it's not paused again
+	e.wfCtx.DeleteMutableValue(types.ContextPrefixContinue, status.ID)
+
+	e.finishStep(operation)
+	return true
+}
+
+type parallelStep struct {
+	runner         types.TaskRunner
+	dependsOnPhase v1alpha1.WorkflowStepPhase
+	engine         *engine
+	status         v1alpha1.StepStatus
+	operation      *types.Operation
+	err            error
+}
+
+// parallelSteps runs the ready steps in DAG mode concurrently. Each step runs on a fork of the engine and the
+// workflow context, and the forks are merged back in the order of the steps after all of them returned, so that
+// the status and the vars are the same as running the steps one by one. The steps unblocked by a batch are run
+// in the next batch of the same reconcile.
+func (e *engine) parallelSteps(taskRunners []types.TaskRunner) error {
+	executed := make(map[string]bool)
+	for {
+		var ready []types.TaskRunner
+		// the steps depending on the ones in the batch wait for the next batch, the same as running them one by one
+		waiting := make(map[string]bool)
+		for _, runner := range taskRunners {
+			if executed[runner.Name()] {
+				continue
+			}
+			if status, ok := e.stepStatus[runner.Name()]; ok {
+				if types.IsStepStatusFinish(status) {
+					continue
+				}
+			}
+			if pending, _ := runner.Pending(e.wfCtx, e.stepStatus); pending && !isWorkflowTimeout(e.deadline) {
+				continue
+			}
+			if !e.isDependsOnWaiting(runner.Name(), waiting) {
+				ready = append(ready, runner)
+			}
+			waiting[runner.Name()] = true
+		}
+		if len(ready) == 0 {
+			break
+		}
+		steps := make([]*parallelStep, 0, len(ready))
+		for _, runner := range ready {
+			executed[runner.Name()] = true
+			steps = append(steps, &parallelStep{runner: runner, dependsOnPhase: e.findDependsOnPhase(runner.Name())})
+		}
+		if err := e.runParallelSteps(steps); err != nil {
+			return err
+		}
+	}
+	for _, runner := range taskRunners {
+		if executed[runner.Name()] {
+			continue
+		}
+		if status, ok := e.stepStatus[runner.Name()]; ok {
+			if types.IsStepStatusFinish(status) {
+				continue
+			}
+		}
+		if pending, status := runner.Pending(e.wfCtx, e.stepStatus); pending {
+			e.wfCtx.IncreaseCountValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
+			e.updateStepStatus(status)
+		}
+	}
+	return nil
+}
+
+func (e *engine) runParallelSteps(steps []*parallelStep) error {
+	if len(steps) == 1 {
+		step := steps[0]
+		status, operation, err := step.runner.Run(e.wfCtx, e.generateRunOptions(step.dependsOnPhase))
+		if err != nil {
+			return err
+		}
+		e.applyStepResult(status, operation)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, e.getMaxParallelism())
+	for _, step := range steps {
+		fork, err := e.fork()
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+		step.engine = fork
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(step *parallelStep) {
+			defer func() {
+				if r := recover(); r != nil {
+					step.err = fmt.Errorf("panic in step %s: %v", step.runner.Name(), r)
+				}
+				<-sem
+				wg.Done()
+			}()
+			step.status, step.operation, step.err = step.runner.Run(step.engine.wfCtx, step.engine.generateRunOptions(step.dependsOnPhase))
+		}(step)
+	}
+	wg.Wait()
+
+	var runErr error
+	for _, step := range steps {
+		if err := e.merge(step.engine); err != nil {
+			return err
+		}
+		if step.err != nil {
+			if runErr == nil {
+				runErr = step.err
+			}
+			continue
+		}
+		e.applyStepResult(step.status, step.operation)
+	}
+	return runErr
+}
+
+func (e *engine) isDependsOnWaiting(name string, waiting map[string]bool) bool {
+	for _, dependsOn := range e.stepDependsOn[name] {
+		if waiting[dependsOn] {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *engine) getMaxParallelism() int {
+	if e.status.Mode.MaxParallelism > 0 {
+		return e.status.Mode.MaxParallelism
+	}
+	return types.MaxWorkflowStepParallelism
+}
+
+// fork copies the engine for the step running concurrently with the others
+func (e *engine) fork() (*engine, error) {
+	wfCtx, err := e.wfCtx.Fork()
+	if err != nil {
+		return nil, errors.WithMessage(err, "fork workflow context")
+	}
+	base := make(map[string]v1alpha1.StepStatus, len(e.stepStatus))
+	stepStatus := make(map[string]v1alpha1.StepStatus, len(e.stepStatus))
+	for k, v := range e.stepStatus {
+		base[k] = v
+		stepStatus[k] = v
+	}
+	stepTimeout := make(map[string]time.Time, len(e.stepTimeout))
+	for k, v := range e.stepTimeout {
+		stepTimeout[k] = v
+	}
+	return &engine{
+		status:             e.status.DeepCopy(),
+		baseStatus:         e.status.DeepCopy(),
+		baseStepStatus:     base,
+		monitorCtx:         e.monitorCtx,
+		instance:           e.instance,
+		wfCtx:              wfCtx,
+		cli:                e.cli,
+		debug:              e.debug,
+		parentRunners:      append([]string{}, e.parentRunners...),
+		stepStatus:         stepStatus,
+		workflowStepStatus: e.workflowStepStatus,
+		stepDependsOn:      e.stepDependsOn,
+		stepRetry:          e.stepRetry,
+		stepTimeout:        stepTimeout,
+		deadline:           e.deadline,
+		hooks:              e.hooks,
+	}, nil
+}
+
+// merge applies the changes made by the fork, e.g. the status of the sub steps, to the engine
+func (e *engine) merge(fork *engine) error {
+	if err := e.wfCtx.Merge(fork.wfCtx); err != nil {
+		return err
+	}
+	for k, v := range fork.stepStatus {
+		if base, ok := fork.baseStepStatus[k]; !ok || !reflect.DeepEqual(base, v) {
+			e.stepStatus[k] = v
+		}
+	}
+	for k, v := range fork.stepTimeout {
+		e.stepTimeout[k] = v
+	}
+	for _, step := range fork.status.Steps {
+		base := findWorkflowStepStatus(fork.baseStatus.Steps, step.Name)
+		if base != nil && reflect.DeepEqual(*base, step) {
+			continue
+		}
+		current := findWorkflowStepStatus(e.status.Steps, step.Name)
+		if current == nil {
+			e.status.Steps = append(e.status.Steps, step)
+			continue
+		}
+		mergeStepStatus(current, base, step)
+	}
+	e.status.Suspend = e.status.Suspend || fork.status.Suspend
+	e.status.Terminated = e.status.Terminated || fork.status.Terminated
+	e.failedAfterRetries = e.failedAfterRetries || fork.failedAfterRetries
+	e.waiting = e.waiting || fork.waiting
+	return nil
+}
+
+func findWorkflowStepStatus(steps []v1alpha1.WorkflowStepStatus, name string) *v1alpha1.WorkflowStepStatus {
+	for i := range steps {
+		if steps[i].Name == name {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// mergeStepStatus applies the changes of the step and its sub steps in any depth from the base to the current one
+func mergeStepStatus(current, base *v1alpha1.WorkflowStepStatus, step v1alpha1.WorkflowStepStatus) {
+	if base == nil || !reflect.DeepEqual(base.StepStatus, step.StepStatus) {
+		current.StepStatus = step.StepStatus
+	}
+	for _, sub := range step.SubStepsStatus {
+		var baseSub *v1alpha1.WorkflowStepStatus
+		if base != nil {
+			baseSub = findWorkflowStepStatus(base.SubStepsStatus, sub.Name)
+		}
+		if baseSub != nil && reflect.DeepEqual(*baseSub, sub) {
+			continue
+		}
+		if currentSub := findWorkflowStepStatus(current.SubStepsStatus, sub.Name); currentSub != nil {
+			mergeStepStatus(currentSub, baseSub, sub)
+		} else {
+			current.SubStepsStatus = append(current.SubStepsStatus, sub)
+		}
+	}
+}
+
 func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *types.TaskRunOptions {
 	options := &types.TaskRunOptions{
 		GetTracer: func(id string, stepStatus v1alpha1.WorkflowStep) monitorContext.Context {
@@ -620,8 +1159,8 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 				if feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure) {
 					return &types.PreCheckResult{Skip: false}, nil
 				}
-				if e.parentRunner != "" {
-					if status, ok := e.stepStatus[e.parentRunner]; ok && status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+				if parent := e.getParentRunner(); parent != "" {
+					if status, ok := e.stepStatus[parent]; ok && status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
 						return &types.PreCheckResult{Skip: true}, nil
 					}
 				}
@@ -631,7 +1170,7 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 				case "":
 					return &types.PreCheckResult{Skip: isUnsuccessfulStep(dependsOnPhase)}, nil
 				default:
-					ifValue, err := custom.ValidateIfValue(e.wfCtx, step, e.stepStatus, options)
+					ifValue, err := custom.ValidateIfValue(e.wfCtx, step, e.ifStepStatus(), options)
 					if err != nil {
 						return &types.PreCheckResult{Skip: true}, err
 					}
@@ -640,8 +1179,11 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 			},
 			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
 				status := e.stepStatus[step.Name]
-				if e.parentRunner != "" {
-					if status, ok := e.stepStatus[e.parentRunner]; ok && status.Phase == v1alpha1.WorkflowStepPhaseFailed && status.Reason == types.StatusReasonTimeout {
+				if isWorkflowTimeout(e.deadline) {
+					return &types.PreCheckResult{Timeout: true}, nil
+				}
+				if parent := e.getParentRunner(); parent != "" {
+					if status, ok := e.stepStatus[parent]; ok && status.Phase == v1alpha1.WorkflowStepPhaseFailed && status.Reason == types.StatusReasonTimeout {
 						return &types.PreCheckResult{Timeout: true}, nil
 					}
 				}
@@ -660,21 +1202,65 @@ func (e *engine) generateRunOptions(dependsOnPhase v1alpha1.WorkflowStepPhase) *
 				return &types.PreCheckResult{Timeout: false}, nil
 			},
 		},
-		PreStartHooks: []types.TaskPreStartHook{hooks.Input},
+		PreStartHooks: []types.TaskPreStartHook{hooks.NewInput(e.cli, e.instance.Namespace)},
 		PostStopHooks: []types.TaskPostStopHook{hooks.Output},
 	}
+	if e.instance.DryRun {
+		options.PostStopHooks = append(options.PostStopHooks, func(ctx wfContext.Context, _ *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, _ map[string]v1alpha1.StepStatus) error {
+			return dryrun.SavePlan(context.Background(), e.cli, e.instance, ctx, step, status)
+		})
+	}
+	// the registered hooks run after the built-in ones
+	options.PreCheckHooks = append(options.PreCheckHooks, e.hooks.PreCheckHooks...)
+	options.PreStartHooks = append(options.PreStartHooks, e.hooks.PreStartHooks...)
+	options.PostStopHooks = append(options.PostStopHooks, e.hooks.PostStopHooks...)
 	if e.debug {
 		options.Debug = func(step string, v *value.Value) error {
-			debugContext := debug.NewContext(e.cli, e.instance, step)
+			debugContext := debug.NewContext(e.cli, e.instance, step, e.wfCtx)
 			if err := debugContext.Set(v); err != nil {
 				return err
 			}
 			return nil
 		}
 	}
+	if len(e.instance.Breakpoints) > 0 {
+		options.Breakpoint = e.breakpoint
+	}
 	return options
 }
 
+// breakpoint pauses the step at the breakpoint until the decision to continue it is stored in the workflow context,
+// the snapshot of the paused step is saved in the debug context to be inspected
+func (e *engine) breakpoint(ctx wfContext.Context, step v1alpha1.WorkflowStep, id string, snapshot func() (*value.Value, error)) (bool, *types.StepContinue, error) {
+	if !e.hasBreakpoint(step.Name) {
+		return false, nil, nil
+	}
+	if data := ctx.GetMutableValue(types.ContextPrefixContinue, id); data != "" {
+		decision := &types.StepContinue{}
+		if err := json.Unmarshal([]byte(data), decision); err != nil {
+			return false, nil, errors.WithMessage(err, "parse the decision at the breakpoint")
+		}
+		return false, decision, nil
+	}
+	v, err := snapshot()
+	if err != nil {
+		return false, nil, err
+	}
+	if err := debug.NewContext(e.cli, e.instance, step.Name, e.wfCtx).SetBreakpoint(v); err != nil {
+		e.monitorCtx.Error(err, "save the snapshot at the breakpoint", "step", step.Name)
+	}
+	return true, nil, nil
+}
+
+func (e *engine) hasBreakpoint(step string) bool {
+	for _, name := range e.instance.Breakpoints {
+		if name == step || name == types.BreakpointAll {
+			return true
+		}
+	}
+	return false
+}
+
 type engine struct {
 	failedAfterRetries bool
 	waiting            bool
@@ -684,70 +1270,61 @@ type engine struct {
 	wfCtx              wfContext.Context
 	instance           *types.WorkflowInstance
 	cli                client.Client
-	parentRunner       string
+	parentRunners      []string
 	stepStatus         map[string]v1alpha1.StepStatus
 	stepTimeout        map[string]time.Time
 	stepDependsOn      map[string][]string
+	stepRetry          map[string]*v1alpha1.RetryPolicy
+	deadline           time.Time
+	hooks              Hooks
+	// workflowStepStatus is the status of the workflow steps and the outcome of the workflow read by the exit
+	// handlers, it's kept apart from the status of the handlers in stepStatus
+	workflowStepStatus map[string]v1alpha1.StepStatus
+	// baseStatus and baseStepStatus are the status when the engine is forked, they're compared with the status
+	// of the fork to find out the changes to merge
+	baseStatus     *v1alpha1.WorkflowRunStatus
+	baseStepStatus map[string]v1alpha1.StepStatus
 }
 
 func (e *engine) finishStep(operation *types.Operation) {
 	if operation != nil {
-		e.status.Suspend = operation.Suspend
+		// the resume of one suspend step doesn't resume the workflow if the others are still waiting
+		e.status.Suspend = operation.Suspend || e.hasWaitingSuspendStep()
 		e.status.Terminated = e.status.Terminated || operation.Terminated
 	}
 }
 
-func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
-	var (
-		conditionUpdated bool
-		now              = metav1.NewTime(time.Now())
-	)
+func (e *engine) hasWaitingSuspendStep() bool {
+	return !types.RangeStepStatus(e.status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		return !isWaitSuspendStep(step.StepStatus)
+	})
+}
 
-	parentRunner := e.parentRunner
-	stepName := status.Name
-	if parentRunner != "" {
-		stepName = parentRunner
-	}
+func (e *engine) updateStepStatus(status v1alpha1.StepStatus) {
+	now := metav1.NewTime(time.Now())
 	e.wfCtx.SetValueInMemory(now.Unix(), types.ContextKeyLastExecuteTime)
 	status.LastExecuteTime = now
-	index := -1
-	for i, ss := range e.status.Steps {
-		if ss.Name == stepName {
-			index = i
-			if parentRunner != "" {
-				// update the sub steps status
-				for j, sub := range ss.SubStepsStatus {
-					if sub.Name == status.Name {
-						status.FirstExecuteTime = sub.FirstExecuteTime
-						e.status.Steps[i].SubStepsStatus[j] = status
-						conditionUpdated = true
-						break
-					}
-				}
-			} else {
-				// update the parent steps status
-				status.FirstExecuteTime = ss.FirstExecuteTime
-				e.status.Steps[i].StepStatus = status
-				conditionUpdated = true
-				break
-			}
+	status.Message = wfContext.Redact(e.wfCtx, status.Message)
+	// find the status list of the level the step is in, the status of the parent step groups are created if missing
+	steps := &e.status.Steps
+	for _, parent := range e.parentRunners {
+		ss := findWorkflowStepStatus(*steps, parent)
+		if ss == nil {
+			*steps = append(*steps, v1alpha1.WorkflowStepStatus{
+				StepStatus: v1alpha1.StepStatus{
+					Name:             parent,
+					FirstExecuteTime: now,
+				}})
+			ss = &(*steps)[len(*steps)-1]
 		}
+		steps = &ss.SubStepsStatus
 	}
-	if !conditionUpdated {
+	if ss := findWorkflowStepStatus(*steps, status.Name); ss != nil {
+		status.FirstExecuteTime = ss.FirstExecuteTime
+		ss.StepStatus = status
+	} else {
 		status.FirstExecuteTime = now
-		if parentRunner != "" {
-			if index < 0 {
-				e.status.Steps = append(e.status.Steps, v1alpha1.WorkflowStepStatus{
-					StepStatus: v1alpha1.StepStatus{
-						Name:             parentRunner,
-						FirstExecuteTime: now,
-					}})
-				index = len(e.status.Steps) - 1
-			}
-			e.status.Steps[index].SubStepsStatus = append(e.status.Steps[index].SubStepsStatus, status)
-		} else {
-			e.status.Steps = append(e.status.Steps, v1alpha1.WorkflowStepStatus{StepStatus: status})
-		}
+		*steps = append(*steps, v1alpha1.WorkflowStepStatus{StepStatus: status})
 	}
 	e.stepStatus[status.Name] = status
 }
@@ -804,7 +1381,7 @@ func isWaitSuspendStep(step v1alpha1.StepStatus) bool {
 	return step.Type == types.WorkflowStepTypeSuspend && step.Phase == v1alpha1.WorkflowStepPhaseRunning
 }
 
-func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, clear bool) error {
+func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, clear bool) {
 	if clear {
 		wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 		wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffReason, status.ID)
@@ -815,33 +1392,22 @@ func handleBackoffTimes(wfCtx wfContext.Context, status v1alpha1.StepStatus, cle
 		}
 		wfCtx.IncreaseCountValueInMemory(types.ContextPrefixBackoffTimes, status.ID)
 	}
-	if err := wfCtx.Commit(); err != nil {
-		return errors.WithMessage(err, "commit workflow context")
-	}
-	return nil
 }
 
 func (e *engine) cleanBackoffTimesForTerminated() {
-	for _, ss := range e.status.Steps {
-		for _, sub := range ss.SubStepsStatus {
-			if sub.Reason == types.StatusReasonTerminate {
-				e.wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffTimes, sub.ID)
-				e.wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffReason, sub.ID)
-			}
-		}
+	types.RangeStepStatus(e.status.Steps, func(ss *v1alpha1.WorkflowStepStatus) bool {
 		if ss.Reason == types.StatusReasonTerminate {
 			e.wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffTimes, ss.ID)
 			e.wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffReason, ss.ID)
 		}
-	}
+		return true
+	})
 }
 
 func (e *engine) GetStepStatus(stepName string) v1alpha1.WorkflowStepStatus {
-	// ss is step status
-	for _, ss := range e.status.Steps {
-		if ss.Name == stepName {
-			return ss
-		}
+	// the step can be a step group nested in the other ones
+	if ss := types.FindStepStatus(e.status.Steps, stepName); ss != nil {
+		return *ss
 	}
 	return v1alpha1.WorkflowStepStatus{}
 }
@@ -853,8 +1419,23 @@ func (e *engine) GetCommonStepStatus(stepName string) v1alpha1.StepStatus {
 	return v1alpha1.StepStatus{}
 }
 
+// SetParentRunner sets the step group running the following steps, the empty name restores the previous one
+// after the sub steps of the nested step group returned.
 func (e *engine) SetParentRunner(name string) {
-	e.parentRunner = name
+	if name != "" {
+		e.parentRunners = append(e.parentRunners, name)
+		return
+	}
+	if len(e.parentRunners) > 0 {
+		e.parentRunners = e.parentRunners[:len(e.parentRunners)-1]
+	}
+}
+
+func (e *engine) getParentRunner() string {
+	if len(e.parentRunners) == 0 {
+		return ""
+	}
+	return e.parentRunners[len(e.parentRunners)-1]
 }
 
 func (e *engine) GetOperation() *types.Operation {
@@ -36,12 +36,15 @@ import (
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	featuregatetesting "k8s.io/component-base/featuregate/testing"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
 
+	"github.com/kubevela/workflow/api/condition"
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/debug"
 	"github.com/kubevela/workflow/pkg/features"
 	"github.com/kubevela/workflow/pkg/tasks/builtin"
 	"github.com/kubevela/workflow/pkg/types"
@@ -185,15 +188,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "failed",
-					},
+					}},
 				},
 			},
 			{
@@ -224,16 +227,16 @@ var _ = Describe("Test Workflow", func() {
 					Type:  "step-group",
 					Phase: v1alpha1.WorkflowStepPhaseFailed,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub2",
 						Type:  "failed",
 						Phase: v1alpha1.WorkflowStepPhaseFailed,
-					},
+					}},
 				},
 			}},
 		})).Should(BeEquivalentTo(""))
@@ -318,6 +321,219 @@ var _ = Describe("Test Workflow", func() {
 		})).Should(BeEquivalentTo(""))
 	})
 
+	It("Workflow test for workflow run timeout", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "running",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s3",
+					Type: "success",
+				},
+			},
+		})
+		instance.Timeout = "1s"
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		Expect(wf.GetBackoffWaitTime()).Should(BeNumerically("<=", time.Second))
+		time.Sleep(1 * time.Second)
+		state, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
+		workflowStatus := instance.Status
+		cond := workflowStatus.GetCondition(condition.ConditionType(v1alpha1.WorkflowRunConditionType))
+		Expect(cond.Reason).Should(BeEquivalentTo(types.StatusReasonTimeout))
+		Expect(cond.Status).Should(BeEquivalentTo(corev1.ConditionFalse))
+		workflowStatus.ContextBackend = nil
+		workflowStatus.Conditions = nil
+		cleanStepTimeStamp(&workflowStatus)
+		Expect(cmp.Diff(workflowStatus, v1alpha1.WorkflowRunStatus{
+			Mode:       defaultMode,
+			Message:    types.MessageTimeout,
+			Terminated: true,
+			Steps: []v1alpha1.WorkflowStepStatus{
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s1",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				}, {
+					StepStatus: v1alpha1.StepStatus{
+						Name:   "s2",
+						Type:   "running",
+						Phase:  v1alpha1.WorkflowStepPhaseFailed,
+						Reason: types.StatusReasonTimeout,
+					},
+				}, {
+					StepStatus: v1alpha1.StepStatus{
+						Name:   "s3",
+						Type:   "success",
+						Phase:  v1alpha1.WorkflowStepPhaseSkipped,
+						Reason: types.StatusReasonSkip,
+					},
+				},
+			},
+		})).Should(BeEquivalentTo(""))
+	})
+
+	It("Workflow test for exit handlers", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "terminate",
+				},
+			},
+		})
+		instance.OnSuccess = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "on-success",
+					Type: "success",
+				},
+			},
+		}
+		instance.OnFailure = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "on-failure",
+					If:   "status.workflow.failed && status.workflow.terminate && status.s1.succeeded",
+					Type: "success",
+				},
+			},
+		}
+		instance.Finally = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "finally",
+					Type: "success",
+				},
+			},
+		}
+		for _, step := range append(append(instance.OnSuccess, instance.OnFailure...), instance.Finally...) {
+			runners = append(runners, makeRunner(step, nil))
+		}
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateTerminated))
+		workflowStatus := instance.Status
+		workflowStatus.ContextBackend = nil
+		cleanStepTimeStamp(&workflowStatus)
+		for index := range workflowStatus.ExitHandlers {
+			workflowStatus.ExitHandlers[index].FirstExecuteTime = metav1.Time{}
+			workflowStatus.ExitHandlers[index].LastExecuteTime = metav1.Time{}
+		}
+		Expect(cmp.Diff(workflowStatus, v1alpha1.WorkflowRunStatus{
+			Mode:       defaultMode,
+			Message:    types.MessageTerminated,
+			Terminated: true,
+			Steps: []v1alpha1.WorkflowStepStatus{
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s1",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				}, {
+					StepStatus: v1alpha1.StepStatus{
+						Name:   "s2",
+						Type:   "terminate",
+						Phase:  v1alpha1.WorkflowStepPhaseFailed,
+						Reason: types.StatusReasonTerminate,
+					},
+				},
+			},
+			ExitHandlers: []v1alpha1.WorkflowStepStatus{
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "on-failure",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				}, {
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "finally",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				},
+			},
+		})).Should(BeEquivalentTo(""))
+	})
+
+	It("Workflow test for exit handlers with the same names as the workflow steps", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "terminate",
+				},
+			},
+		})
+		instance.OnFailure = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					If:   "status.s1.succeeded && status.workflow.terminate",
+					Type: "success",
+				},
+			},
+		}
+		instance.Finally = []v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "workflow",
+					If:   "status.workflow.failed",
+					Type: "success",
+				},
+			},
+		}
+		for _, step := range append(instance.OnFailure, instance.Finally...) {
+			runners = append(runners, makeRunner(step, nil))
+		}
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateTerminated))
+		workflowStatus := instance.Status
+		cleanStepTimeStamp(&workflowStatus)
+		Expect(workflowStatus.Steps).Should(HaveLen(2))
+		Expect(workflowStatus.Steps[0].Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(workflowStatus.ExitHandlers).Should(HaveLen(2))
+		for _, handler := range workflowStatus.ExitHandlers {
+			Expect(handler.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseSucceeded))
+		}
+	})
+
 	It("Workflow test for timeout with suspend", func() {
 		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
 			{
@@ -424,21 +640,21 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:    "s2-sub2",
 						Type:    "running",
 						Timeout: "1s",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:    "s2-suspend",
 						Type:    "suspend",
 						Timeout: "1s",
-					},
+					}},
 				},
 			},
 			{
@@ -478,22 +694,22 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonTimeout,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "running",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTimeout,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-suspend",
 						Type:   "suspend",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTimeout,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -518,19 +734,19 @@ var _ = Describe("Test Workflow", func() {
 					Type:    "step-group",
 					Timeout: "1s",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "running",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-suspend",
 						Type: "suspend",
-					},
+					}},
 				},
 			},
 			{
@@ -570,22 +786,22 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonTimeout,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "running",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTimeout,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-suspend",
 						Type:   "suspend",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTimeout,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -612,15 +828,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "failed",
-					},
+					}},
 				},
 			},
 			{
@@ -655,18 +871,18 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 					Reason: types.StatusReasonSkip,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub1",
 						Type:   "success",
 						Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 						Reason: types.StatusReasonSkip,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "failed",
 						Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 						Reason: types.StatusReasonSkip,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -694,21 +910,21 @@ var _ = Describe("Test Workflow", func() {
 					If:   "always",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name:      "s2-sub1",
 						DependsOn: []string{"s2-sub2"},
 						If:        "always",
 						Type:      "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "failed-after-retries",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub3",
 						Type: "terminate",
-					},
+					}},
 				},
 			},
 			{
@@ -743,22 +959,22 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonFailedAfterRetries,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "failed-after-retries",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonFailedAfterRetries,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub3",
 						Type:   "terminate",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTerminate,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -785,15 +1001,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "success",
-					},
+					}},
 				},
 			},
 			{
@@ -824,16 +1040,16 @@ var _ = Describe("Test Workflow", func() {
 					Type:  "step-group",
 					Phase: v1alpha1.WorkflowStepPhaseSucceeded,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub2",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -857,7 +1073,7 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{},
+				SubSteps: []v1alpha1.WorkflowStep{},
 			},
 			{
 				WorkflowStepBase: v1alpha1.WorkflowStepBase{
@@ -897,6 +1113,139 @@ var _ = Describe("Test Workflow", func() {
 		})).Should(BeEquivalentTo(""))
 	})
 
+	It("Workflow test with nested step groups", func() {
+		By("Test success with the nested step groups")
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "stage",
+					Type: "step-group",
+				},
+				Mode: v1alpha1.WorkflowModeStep,
+				SubSteps: []v1alpha1.WorkflowStep{
+					{
+						WorkflowStepBase: v1alpha1.WorkflowStepBase{
+							Name: "env1",
+							Type: "step-group",
+						},
+						SubSteps: []v1alpha1.WorkflowStep{
+							{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+								Name: "cluster1",
+								Type: "success",
+							}},
+						},
+					},
+					{
+						WorkflowStepBase: v1alpha1.WorkflowStepBase{
+							Name: "env2",
+							Type: "step-group",
+						},
+						SubSteps: []v1alpha1.WorkflowStep{
+							{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+								Name: "cluster2",
+								Type: "success",
+							}},
+						},
+					},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "success",
+				},
+			},
+		})
+		wf := New(instance, k8sClient)
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSucceeded))
+		instance.Status.ContextBackend = nil
+		cleanStepTimeStamp(&instance.Status)
+		Expect(cmp.Diff(instance.Status, v1alpha1.WorkflowRunStatus{
+			Mode: defaultMode,
+			Steps: []v1alpha1.WorkflowStepStatus{{
+				StepStatus: v1alpha1.StepStatus{
+					Name:  "stage",
+					Type:  "step-group",
+					Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+				},
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "env1",
+						Type:  "step-group",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{
+						Name:  "cluster1",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					}}},
+				}, {
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "env2",
+						Type:  "step-group",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{
+						Name:  "cluster2",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					}}},
+				}},
+			}, {
+				StepStatus: v1alpha1.StepStatus{
+					Name:  "s2",
+					Type:  "success",
+					Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+				},
+			}},
+		})).Should(BeEquivalentTo(""))
+
+		By("Test failed in the nested step group")
+		instance, runners = makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "stage",
+					Type: "step-group",
+				},
+				Mode: v1alpha1.WorkflowModeStep,
+				SubSteps: []v1alpha1.WorkflowStep{
+					{
+						WorkflowStepBase: v1alpha1.WorkflowStepBase{
+							Name: "env1",
+							Type: "step-group",
+						},
+						SubSteps: []v1alpha1.WorkflowStep{
+							{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+								Name: "cluster1",
+								Type: "failed-after-retries",
+							}},
+						},
+					},
+					{
+						WorkflowStepBase: v1alpha1.WorkflowStepBase{
+							Name: "env2",
+							Type: "success",
+						},
+					},
+				},
+			},
+		})
+		wf = New(instance, k8sClient)
+		state, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateFailed))
+		Expect(instance.Status.Steps[0].Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseFailed))
+		Expect(instance.Status.Steps[0].Reason).Should(BeEquivalentTo(types.StatusReasonFailedAfterRetries))
+		env1 := instance.Status.Steps[0].SubStepsStatus[0]
+		Expect(env1.Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseFailed))
+		Expect(env1.SubStepsStatus[0].Reason).Should(BeEquivalentTo(types.StatusReasonFailedAfterRetries))
+		// the steps after the failed one are skipped in the step by step mode of the group
+		Expect(instance.Status.Steps[0].SubStepsStatus[1].Phase).Should(BeEquivalentTo(v1alpha1.WorkflowStepPhaseSkipped))
+	})
+
 	It("Workflow test for failed after retries with suspend", func() {
 		By("Test failed-after-retries in StepByStep mode with suspend")
 		defer featuregatetesting.SetFeatureGateDuringTest(&testing.T{}, utilfeature.DefaultFeatureGate, features.EnableSuspendOnFailure, true)()
@@ -1383,16 +1732,16 @@ var _ = Describe("Test Workflow", func() {
 					If:   "status.s1.timeout",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2_sub1",
 						If:   "always",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2_sub2",
 						Type: "failed-after-retries",
-					},
+					}},
 				},
 			},
 			{
@@ -1401,16 +1750,16 @@ var _ = Describe("Test Workflow", func() {
 					If:   "status.s1.succeeded",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s3_sub1",
 						If:   "status.s2_sub1.skipped",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s3_sub2",
 						Type: "failed-after-retries",
-					},
+					}},
 				},
 			},
 		})
@@ -1438,18 +1787,18 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 					Reason: types.StatusReasonSkip,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:   "s2_sub1",
 						Type:   "success",
 						Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 						Reason: types.StatusReasonSkip,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2_sub2",
 						Type:   "failed-after-retries",
 						Phase:  v1alpha1.WorkflowStepPhaseSkipped,
 						Reason: types.StatusReasonSkip,
-					},
+					}},
 				},
 			}, {
 				StepStatus: v1alpha1.StepStatus{
@@ -1458,17 +1807,17 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonFailedAfterRetries,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s3_sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s3_sub2",
 						Type:   "failed-after-retries",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonFailedAfterRetries,
-					},
+					}},
 				},
 			}},
 		})).Should(BeEquivalentTo(""))
@@ -1489,15 +1838,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "failed-after-retries",
-					},
+					}},
 				},
 			},
 			{
@@ -1531,17 +1880,17 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonFailedAfterRetries,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "failed-after-retries",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonFailedAfterRetries,
-					},
+					}},
 				},
 			}},
 		})).Should(BeEquivalentTo(""))
@@ -1591,8 +1940,20 @@ var _ = Describe("Test Workflow", func() {
 			Expect(interval).Should(BeEquivalentTo(types.MaxWorkflowWaitBackoffTime))
 		}
 
+		By("Test get backoff time after restart")
+		wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+		wfCtx, err = wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
+		Expect(err).ToNot(HaveOccurred())
+		e = &engine{
+			status: &instance.Status,
+			wfCtx:  wfCtx,
+		}
+		interval = e.getBackoffWaitTime()
+		Expect(interval).Should(BeEquivalentTo(types.MaxWorkflowWaitBackoffTime))
+
 		By("Test get backoff time after clean")
 		wfContext.CleanupMemoryStore(instance.Name, instance.Namespace)
+		Expect(wfCtx.Commit()).Should(BeNil())
 		_, err = wf.ExecuteRunners(ctx, runners)
 		Expect(err).ToNot(HaveOccurred())
 		wfCtx, err = wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
@@ -1631,6 +1992,156 @@ var _ = Describe("Test Workflow", func() {
 		Expect(int(math.Ceil(wf.GetBackoffWaitTime().Seconds()))).Should(Equal(30))
 	})
 
+	It("Test get backoff time with watched objects", func() {
+		defer featuregatetesting.SetFeatureGateDuringTest(&testing.T{}, utilfeature.DefaultFeatureGate, features.EnableWatchWaitingResources, true)()
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "wait-for-object",
+				},
+			},
+		})
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		for i := 0; i < 2; i++ {
+			state, err := wf.ExecuteRunners(ctx, runners)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		}
+		Expect(wf.GetWatchedObjects()).Should(Equal([]wfContext.WatchedObject{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "s1"}}))
+		wfCtx, err := wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
+		Expect(err).ToNot(HaveOccurred())
+		e := &engine{
+			status: &instance.Status,
+			wfCtx:  wfCtx,
+		}
+		// the waiting step is woken up by the changes of the watched objects rather than the backoff
+		Expect(e.getBackoffWaitTime()).Should(BeEquivalentTo(types.MaxWorkflowWaitBackoffTime))
+
+		By("Test get backoff time without watching the objects")
+		featuregatetesting.SetFeatureGateDuringTest(&testing.T{}, utilfeature.DefaultFeatureGate, features.EnableWatchWaitingResources, false)
+		Expect(e.getBackoffWaitTime()).Should(BeEquivalentTo(minWorkflowBackoffWaitTime))
+	})
+
+	It("Workflow test with breakpoints", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "breakpoint",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s2",
+					Type: "breakpoint",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s3",
+					Type: "breakpoint",
+				},
+			},
+		})
+		instance.Breakpoints = []string{"s2", "s3"}
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient)
+		for i := 0; i < 2; i++ {
+			state, err := wf.ExecuteRunners(ctx, runners)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		}
+		Expect(instance.Status.Steps).Should(HaveLen(2))
+		Expect(instance.Status.Steps[0].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(instance.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+		Expect(instance.Status.Steps[1].Reason).Should(Equal(types.StatusReasonBreakpoint))
+		cm := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{
+			Namespace: instance.Namespace,
+			Name:      debug.GenerateContextName(instance.Name, "s2"),
+		}, cm)).Should(BeNil())
+		Expect(cm.Data[debug.DataKeyBreakpoint]).Should(ContainSubstring(`name: "s2"`))
+
+		By("Continue s2 and skip s3 at the breakpoints")
+		wfCtx, err := wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
+		Expect(err).ToNot(HaveOccurred())
+		wfCtx.SetMutableValue(`{"name":"s2"}`, types.ContextPrefixContinue, "s2")
+		Expect(wfCtx.Commit()).Should(BeNil())
+		_, err = wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Status.Steps[1].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSucceeded))
+		Expect(instance.Status.Steps[2].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseRunning))
+		Expect(instance.Status.Steps[2].Reason).Should(Equal(types.StatusReasonBreakpoint))
+
+		wfCtx, err = wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
+		Expect(err).ToNot(HaveOccurred())
+		// the decision is removed after the step finished
+		Expect(wfCtx.GetMutableValue(types.ContextPrefixContinue, "s2")).Should(BeEmpty())
+		wfCtx.SetMutableValue(`{"name":"s3","skip":true}`, types.ContextPrefixContinue, "s3")
+		Expect(wfCtx.Commit()).Should(BeNil())
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSucceeded))
+		Expect(instance.Status.Steps[2].Phase).Should(Equal(v1alpha1.WorkflowStepPhaseSkipped))
+	})
+
+	It("Workflow test with registered hooks", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+		})
+		var hookInstance *types.WorkflowInstance
+		var stopped []v1alpha1.StepStatus
+		policy := func(instance *types.WorkflowInstance) Hooks {
+			hookInstance = instance
+			return Hooks{
+				PreCheckHooks: []types.TaskPreCheckHook{
+					func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+						return &types.PreCheckResult{Skip: instance.Labels["policy"] == "deny"}, nil
+					},
+				},
+			}
+		}
+		audit := func(instance *types.WorkflowInstance) Hooks {
+			return Hooks{
+				PostStopHooks: []types.TaskPostStopHook{
+					func(ctx wfContext.Context, _ *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, _ map[string]v1alpha1.StepStatus) error {
+						stopped = append(stopped, status)
+						return nil
+					},
+				},
+			}
+		}
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		wf := New(instance, k8sClient, WithHooks(policy), WithHooks(audit))
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateSucceeded))
+		Expect(hookInstance).Should(Equal(instance))
+
+		By("Test the registered hooks run after the built-in ones")
+		wfCtx, err := wfContext.LoadContext(k8sClient, instance.Namespace, instance.Name)
+		Expect(err).ToNot(HaveOccurred())
+		instance.Labels = map[string]string{"policy": "deny"}
+		e := newEngine(ctx, wfCtx, wf.(*workflowExecutor), &instance.Status)
+		options := e.generateRunOptions(v1alpha1.WorkflowStepPhaseSucceeded)
+		Expect(len(options.PreCheckHooks)).Should(Equal(3))
+		Expect(len(options.PreStartHooks)).Should(Equal(1))
+		Expect(len(options.PostStopHooks)).Should(Equal(2))
+		result, err := options.PreCheckHooks[2](instance.Steps[0], &types.PreCheckOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Skip).Should(BeTrue())
+		status := v1alpha1.StepStatus{Name: "s1", Phase: v1alpha1.WorkflowStepPhaseSucceeded}
+		Expect(options.PostStopHooks[1](wfCtx, nil, instance.Steps[0], status, options.StepStatus)).Should(BeNil())
+		Expect(stopped).Should(Equal([]v1alpha1.StepStatus{status}))
+	})
+
 	It("Test get suspend backoff time", func() {
 		By("if there's no timeout and duration, return 0")
 		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
@@ -1843,15 +2354,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "suspend",
-					},
+					}},
 				},
 			},
 			{
@@ -1883,16 +2394,16 @@ var _ = Describe("Test Workflow", func() {
 					Type:  "step-group",
 					Phase: v1alpha1.WorkflowStepPhaseRunning,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub2",
 						Type:  "suspend",
 						Phase: v1alpha1.WorkflowStepPhaseRunning,
-					},
+					}},
 				},
 			}},
 		})).Should(BeEquivalentTo(""))
@@ -1960,15 +2471,15 @@ var _ = Describe("Test Workflow", func() {
 					Name: "s2",
 					Type: "step-group",
 				},
-				SubSteps: []v1alpha1.WorkflowStepBase{
-					{
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub1",
 						Type: "success",
-					},
-					{
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 						Name: "s2-sub2",
 						Type: "terminate",
-					},
+					}},
 				},
 			},
 		})
@@ -1996,17 +2507,17 @@ var _ = Describe("Test Workflow", func() {
 					Phase:  v1alpha1.WorkflowStepPhaseFailed,
 					Reason: types.StatusReasonTerminate,
 				},
-				SubStepsStatus: []v1alpha1.StepStatus{
-					{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
 						Name:  "s2-sub1",
 						Type:  "success",
 						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-					}, {
+					}}, {StepStatus: v1alpha1.StepStatus{
 						Name:   "s2-sub2",
 						Type:   "terminate",
 						Phase:  v1alpha1.WorkflowStepPhaseFailed,
 						Reason: types.StatusReasonTerminate,
-					},
+					}},
 				},
 			}},
 		})).Should(BeEquivalentTo(""))
@@ -2156,6 +2667,106 @@ var _ = Describe("Test Workflow", func() {
 		})).Should(BeEquivalentTo(""))
 	})
 
+	It("test for DAG with max parallelism", func() {
+		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s1",
+					Type: "success",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name:      "s2",
+					Type:      "success",
+					DependsOn: []string{"s1"},
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s3",
+					Type: "running",
+				},
+			},
+			{
+				WorkflowStepBase: v1alpha1.WorkflowStepBase{
+					Name: "s4",
+					Type: "step-group",
+				},
+				SubSteps: []v1alpha1.WorkflowStep{
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name: "s4-sub1",
+						Type: "success",
+					}},
+					{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+						Name: "s4-sub2",
+						Type: "success",
+					}},
+				},
+			},
+		})
+		instance.Mode = &v1alpha1.WorkflowExecuteMode{
+			Steps:          v1alpha1.WorkflowModeDAG,
+			MaxParallelism: 2,
+		}
+		wf := New(instance, k8sClient)
+		ctx := monitorContext.NewTraceContext(context.Background(), "test-app")
+		state, err := wf.ExecuteRunners(ctx, runners)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state).Should(BeEquivalentTo(v1alpha1.WorkflowStateExecuting))
+		instance.Status.ContextBackend = nil
+		cleanStepTimeStamp(&instance.Status)
+		Expect(cmp.Diff(instance.Status, v1alpha1.WorkflowRunStatus{
+			Mode: v1alpha1.WorkflowExecuteMode{
+				Steps:          v1alpha1.WorkflowModeDAG,
+				SubSteps:       v1alpha1.WorkflowModeDAG,
+				MaxParallelism: 2,
+			},
+			Steps: []v1alpha1.WorkflowStepStatus{
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s1",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				},
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s3",
+						Type:  "running",
+						Phase: v1alpha1.WorkflowStepPhaseRunning,
+					},
+				},
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s4",
+						Type:  "step-group",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
+							Name:  "s4-sub1",
+							Type:  "success",
+							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+						}},
+						{StepStatus: v1alpha1.StepStatus{
+							Name:  "s4-sub2",
+							Type:  "success",
+							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+						}},
+					},
+				},
+				{
+					StepStatus: v1alpha1.StepStatus{
+						Name:  "s2",
+						Type:  "success",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					},
+				},
+			},
+		})).Should(BeEquivalentTo(""))
+	})
+
 	It("step commit data without success", func() {
 		instance, runners := makeTestCase([]v1alpha1.WorkflowStep{
 			{
@@ -2205,22 +2816,20 @@ func makeTestCase(steps []v1alpha1.WorkflowStep) (*types.WorkflowInstance, []typ
 		Steps:  steps,
 		Status: v1alpha1.WorkflowRunStatus{},
 	}
+	return instance, makeRunners(steps)
+}
+
+func makeRunners(steps []v1alpha1.WorkflowStep) []types.TaskRunner {
 	runners := []types.TaskRunner{}
 	for _, step := range steps {
+		var subStepRunners []types.TaskRunner
 		if step.SubSteps != nil {
-			subStepRunners := []types.TaskRunner{}
-			for _, subStep := range step.SubSteps {
-				step := v1alpha1.WorkflowStep{
-					WorkflowStepBase: subStep,
-				}
-				subStepRunners = append(subStepRunners, makeRunner(step, nil))
-			}
-			runners = append(runners, makeRunner(step, subStepRunners))
-		} else {
-			runners = append(runners, makeRunner(step, nil))
+			// the sub steps can be step groups as well
+			subStepRunners = makeRunners(step.SubSteps)
 		}
+		runners = append(runners, makeRunner(step, subStepRunners))
 	}
-	return instance, runners
+	return runners
 }
 
 var pending bool
@@ -2301,8 +2910,45 @@ func makeRunner(step v1alpha1.WorkflowStep, subTaskRunners []types.TaskRunner) t
 				Phase: v1alpha1.WorkflowStepPhaseRunning,
 			}, &types.Operation{}, err
 		}
+	case "wait-for-object":
+		run = func(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+			wfContext.AddWatchedObjects(ctx, step.Name, wfContext.WatchedObject{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: step.Name})
+			return v1alpha1.StepStatus{
+				Name:   step.Name,
+				Type:   "wait-for-object",
+				Phase:  v1alpha1.WorkflowStepPhaseRunning,
+				Reason: types.StatusReasonWait,
+			}, &types.Operation{Waiting: true}, nil
+		}
+	case "breakpoint":
+		run = func(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+			status := v1alpha1.StepStatus{
+				ID:    step.Name,
+				Name:  step.Name,
+				Type:  "breakpoint",
+				Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+			}
+			if options.Breakpoint == nil {
+				return status, &types.Operation{}, nil
+			}
+			paused, decision, err := options.Breakpoint(ctx, step, step.Name, func() (*value.Value, error) {
+				return value.NewValue(`parameter: name: "`+step.Name+`"`, nil, "")
+			})
+			if err != nil {
+				return v1alpha1.StepStatus{}, nil, err
+			}
+			switch {
+			case paused:
+				status.Phase, status.Reason = v1alpha1.WorkflowStepPhaseRunning, types.StatusReasonBreakpoint
+				return status, &types.Operation{Waiting: true}, nil
+			case decision != nil && decision.Skip:
+				status.Phase, status.Reason = v1alpha1.WorkflowStepPhaseSkipped, types.StatusReasonSkip
+				return status, &types.Operation{Skip: true}, nil
+			}
+			return status, &types.Operation{}, nil
+		}
 	case "step-group":
-		group, _ := builtin.StepGroup(step, &types.TaskGeneratorOptions{SubTaskRunners: subTaskRunners})
+		group, _ := builtin.StepGroup(step, &types.TaskGeneratorOptions{SubTaskRunners: subTaskRunners, SubStepExecuteMode: step.Mode})
 		run = group.Run
 	case "running":
 		run = func(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
@@ -2396,16 +3042,11 @@ func (tr *testTaskRunner) Pending(ctx wfContext.Context, stepStatus map[string]v
 
 func cleanStepTimeStamp(wfStatus *v1alpha1.WorkflowRunStatus) {
 	wfStatus.StartTime = metav1.Time{}
-	for index, step := range wfStatus.Steps {
-		wfStatus.Steps[index].FirstExecuteTime = metav1.Time{}
-		wfStatus.Steps[index].LastExecuteTime = metav1.Time{}
-		if step.SubStepsStatus != nil {
-			for indexSubStep := range step.SubStepsStatus {
-				wfStatus.Steps[index].SubStepsStatus[indexSubStep].FirstExecuteTime = metav1.Time{}
-				wfStatus.Steps[index].SubStepsStatus[indexSubStep].LastExecuteTime = metav1.Time{}
-			}
-		}
-	}
+	types.RangeStepStatus(wfStatus.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		step.FirstExecuteTime = metav1.Time{}
+		step.LastExecuteTime = metav1.Time{}
+		return true
+	})
 }
 
 const cmYaml = `apiVersion: v1
@@ -27,11 +27,14 @@ const (
 	EnableSuspendOnFailure featuregate.Feature = "EnableSuspendOnFailure"
 	// EnableBackupWorkflowRecord enable backup workflow record
 	EnableBackupWorkflowRecord featuregate.Feature = "EnableBackupWorkflowRecord"
+	// EnableWatchWaitingResources enable waking up the waiting steps by the changes of the resources they read
+	EnableWatchWaitingResources featuregate.Feature = "EnableWatchWaitingResources"
 )
 
 var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
-	EnableSuspendOnFailure:     {Default: false, PreRelease: featuregate.Alpha},
-	EnableBackupWorkflowRecord: {Default: false, PreRelease: featuregate.Alpha},
+	EnableSuspendOnFailure:      {Default: false, PreRelease: featuregate.Alpha},
+	EnableBackupWorkflowRecord:  {Default: false, PreRelease: featuregate.Alpha},
+	EnableWatchWaitingResources: {Default: false, PreRelease: featuregate.Alpha},
 }
 
 func init() {
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/debug"
+	"github.com/kubevela/workflow/pkg/monitor/metrics"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ReasonTTL is the reason of the deletion of the run whose ttl is expired
+	ReasonTTL = "ttl"
+	// ReasonHistoryLimit is the reason of the deletion of the run which exceeds the history limit
+	ReasonHistoryLimit = "history-limit"
+)
+
+// Args is the args of the garbage collector
+type Args struct {
+	// Interval is the interval between two garbage collections
+	Interval time.Duration
+	// SucceededHistoryLimit is the number of succeeded runs to keep in each group, negative means no limit
+	SucceededHistoryLimit int
+	// FailedHistoryLimit is the number of failed runs to keep in each group, negative means no limit
+	FailedHistoryLimit int
+	// GroupByLabel is the label to group the runs for the history limits, the runs are grouped
+	// by the referenced workflow if it's empty
+	GroupByLabel string
+}
+
+// Collector deletes the finished workflow runs whose ttl is expired or exceed the history limits,
+// together with their context and debug config maps.
+type Collector struct {
+	Args
+	cli    client.Client
+	reader client.Reader
+	now    func() time.Time
+}
+
+// NewCollector creates the garbage collector of the workflow runs, the workflow runs are listed from the reader, it
+// should read from the api server if the cache of the replica doesn't have the runs in all the shards.
+func NewCollector(cli client.Client, reader client.Reader, args Args) *Collector {
+	return &Collector{Args: args, cli: cli, reader: reader, now: time.Now}
+}
+
+// Start implements manager.Runnable, it runs the garbage collection periodically until the context is done.
+func (c *Collector) Start(ctx context.Context) error {
+	klog.InfoS("Start the garbage collection of workflow runs", "interval", c.Interval)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.Collect(ctx); err != nil {
+			klog.ErrorS(err, "Failed to collect the garbage of workflow runs")
+		}
+	}, c.Interval)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (c *Collector) NeedLeaderElection() bool {
+	return true
+}
+
+// Collect runs the garbage collection once
+func (c *Collector) Collect(ctx context.Context) error {
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := c.reader.List(ctx, runs); err != nil {
+		return errors.WithMessage(err, "list workflow runs")
+	}
+	now := c.now()
+	groups := make(map[string][]*v1alpha1.WorkflowRun)
+	var errs []error
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if !run.Status.Finished || run.DeletionTimestamp != nil {
+			continue
+		}
+		if isExpired(run, now) {
+			if err := c.deleteRun(ctx, run, ReasonTTL); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if key := c.groupKey(run); key != "" {
+			groups[key] = append(groups[key], run)
+		}
+	}
+	for _, group := range groups {
+		for _, run := range c.getRunsOverLimit(group) {
+			if err := c.deleteRun(ctx, run, ReasonHistoryLimit); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func isExpired(run *v1alpha1.WorkflowRun, now time.Time) bool {
+	if run.Spec.TTLSecondsAfterFinished == nil || run.Status.EndTime.IsZero() {
+		return false
+	}
+	ttl := time.Duration(*run.Spec.TTLSecondsAfterFinished) * time.Second
+	return !now.Before(run.Status.EndTime.Add(ttl))
+}
+
+func (c *Collector) groupKey(run *v1alpha1.WorkflowRun) string {
+	key := run.Spec.WorkflowRef
+	if c.GroupByLabel != "" {
+		key = run.Labels[c.GroupByLabel]
+	}
+	if key == "" {
+		return ""
+	}
+	return run.Namespace + "/" + key
+}
+
+// getRunsOverLimit returns the succeeded and failed runs exceeding the history limits, the latest
+// finished runs are kept.
+func (c *Collector) getRunsOverLimit(runs []*v1alpha1.WorkflowRun) []*v1alpha1.WorkflowRun {
+	var succeeded, failed []*v1alpha1.WorkflowRun
+	for _, run := range runs {
+		if run.Status.Phase == v1alpha1.WorkflowStateSucceeded {
+			succeeded = append(succeeded, run)
+		} else {
+			failed = append(failed, run)
+		}
+	}
+	return append(overLimit(succeeded, c.SucceededHistoryLimit), overLimit(failed, c.FailedHistoryLimit)...)
+}
+
+func overLimit(runs []*v1alpha1.WorkflowRun, limit int) []*v1alpha1.WorkflowRun {
+	if limit < 0 || len(runs) <= limit {
+		return nil
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].Status.EndTime.Equal(&runs[j].Status.EndTime) {
+			return runs[j].Status.EndTime.Before(&runs[i].Status.EndTime)
+		}
+		return runs[i].Name > runs[j].Name
+	})
+	return runs[limit:]
+}
+
+func (c *Collector) deleteRun(ctx context.Context, run *v1alpha1.WorkflowRun, reason string) error {
+	// the artifacts are deleted before the context which references them
+	if err := wfContext.DeleteArtifacts(ctx, c.cli, run.Namespace, run.Name); err != nil {
+		return errors.WithMessagef(err, "delete artifacts of workflow run %s/%s", run.Namespace, run.Name)
+	}
+	if err := c.cli.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.WithMessagef(err, "delete workflow run %s/%s", run.Namespace, run.Name)
+	}
+	klog.InfoS("Delete workflow run", "name", run.Name, "namespace", run.Namespace, "reason", reason)
+	metrics.WorkflowRunGCDeletedCounter.WithLabelValues("WorkflowRun", reason).Inc()
+	wfContext.CleanupMemoryStore(run.Name, run.Namespace)
+
+	names := []string{wfContext.GenerateStoreName(run.Name)}
+	for _, steps := range [][]v1alpha1.WorkflowStepStatus{run.Status.Steps, run.Status.ExitHandlers} {
+		types.RangeStepStatus(steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+			names = append(names, debug.GenerateContextName(run.Name, step.Name))
+			return true
+		})
+	}
+	var errs []error
+	for _, name := range names {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: run.Namespace}}
+		if err := c.cli.Delete(ctx, cm); err != nil {
+			if !kerrors.IsNotFound(err) {
+				errs = append(errs, errors.WithMessagef(err, "delete config map %s/%s", run.Namespace, name))
+			}
+			continue
+		}
+		metrics.WorkflowRunGCDeletedCounter.WithLabelValues("ConfigMap", reason).Inc()
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/debug"
+)
+
+func TestCollect(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	now := time.Now()
+
+	expired := newRun("expired", "", v1alpha1.WorkflowStateSucceeded, now.Add(-time.Hour))
+	expired.Spec.TTLSecondsAfterFinished = pointer.Int32(60)
+	expired.Status.Steps = []v1alpha1.WorkflowStepStatus{{
+		StepStatus:     v1alpha1.StepStatus{Name: "step"},
+		SubStepsStatus: []v1alpha1.WorkflowStepStatus{{StepStatus: v1alpha1.StepStatus{Name: "sub"}}},
+	}}
+	notExpired := newRun("not-expired", "", v1alpha1.WorkflowStateSucceeded, now)
+	notExpired.Spec.TTLSecondsAfterFinished = pointer.Int32(60)
+	running := newRun("running", "wf", v1alpha1.WorkflowStateExecuting, time.Time{})
+	running.Status.Finished = false
+	objs := []client.Object{
+		expired, notExpired, running,
+		newRun("succeeded-1", "wf", v1alpha1.WorkflowStateSucceeded, now.Add(-3*time.Minute)),
+		newRun("succeeded-2", "wf", v1alpha1.WorkflowStateSucceeded, now.Add(-2*time.Minute)),
+		newRun("succeeded-3", "wf", v1alpha1.WorkflowStateSucceeded, now.Add(-time.Minute)),
+		newRun("failed-1", "wf", v1alpha1.WorkflowStateFailed, now.Add(-2*time.Minute)),
+		newRun("failed-2", "wf", v1alpha1.WorkflowStateTerminated, now.Add(-time.Minute)),
+		newRun("other-succeeded", "other", v1alpha1.WorkflowStateSucceeded, now.Add(-3*time.Minute)),
+		newConfigMap(wfContext.GenerateStoreName("expired")),
+		newConfigMap(debug.GenerateContextName("expired", "step")),
+		newConfigMap(debug.GenerateContextName("expired", "sub")),
+		newConfigMap(wfContext.GenerateStoreName("succeeded-1")),
+		newConfigMap(wfContext.GenerateStoreName("not-expired")),
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	collector := NewCollector(cli, cli, Args{SucceededHistoryLimit: 2, FailedHistoryLimit: 1})
+	collector.now = func() time.Time { return now }
+	r.NoError(collector.Collect(ctx))
+
+	deleted := []string{"expired", "succeeded-1", "failed-1"}
+	remained := []string{"not-expired", "running", "succeeded-2", "succeeded-3", "failed-2", "other-succeeded"}
+	for _, name := range deleted {
+		err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &v1alpha1.WorkflowRun{})
+		r.True(kerrors.IsNotFound(err), name)
+	}
+	for _, name := range remained {
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &v1alpha1.WorkflowRun{}), name)
+	}
+	for _, name := range []string{
+		wfContext.GenerateStoreName("expired"),
+		debug.GenerateContextName("expired", "step"),
+		debug.GenerateContextName("expired", "sub"),
+		wfContext.GenerateStoreName("succeeded-1"),
+	} {
+		err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &corev1.ConfigMap{})
+		r.True(kerrors.IsNotFound(err), name)
+	}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: wfContext.GenerateStoreName("not-expired")}, &corev1.ConfigMap{}))
+}
+
+func TestCollectGroupByLabel(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	now := time.Now()
+
+	first := newRun("first", "wf-1", v1alpha1.WorkflowStateSucceeded, now.Add(-2*time.Minute))
+	first.Labels = map[string]string{"app": "test"}
+	second := newRun("second", "wf-2", v1alpha1.WorkflowStateSucceeded, now.Add(-time.Minute))
+	second.Labels = map[string]string{"app": "test"}
+	unlabeled := newRun("unlabeled", "wf-1", v1alpha1.WorkflowStateSucceeded, now.Add(-time.Minute))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second, unlabeled).Build()
+	collector := NewCollector(cli, cli, Args{SucceededHistoryLimit: 1, FailedHistoryLimit: -1, GroupByLabel: "app"})
+	r.NoError(collector.Collect(ctx))
+
+	err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "first"}, &v1alpha1.WorkflowRun{})
+	r.True(kerrors.IsNotFound(err))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "second"}, &v1alpha1.WorkflowRun{}))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unlabeled"}, &v1alpha1.WorkflowRun{}))
+}
+
+func newRun(name, workflow string, phase v1alpha1.WorkflowRunPhase, end time.Time) *v1alpha1.WorkflowRun {
+	return &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: workflow,
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Phase:    phase,
+			Finished: true,
+			EndTime:  metav1.NewTime(end),
+		},
+	}
+}
+
+func newConfigMap(name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+}
@@ -19,6 +19,7 @@ package generator
 import (
 	"context"
 	"errors"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
@@ -32,11 +33,13 @@ import (
 	"github.com/kubevela/workflow/pkg/executor"
 	"github.com/kubevela/workflow/pkg/monitor/metrics"
 	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/providers/dryrun"
 	"github.com/kubevela/workflow/pkg/providers/email"
 	"github.com/kubevela/workflow/pkg/providers/http"
 	"github.com/kubevela/workflow/pkg/providers/kube"
 	"github.com/kubevela/workflow/pkg/providers/util"
 	"github.com/kubevela/workflow/pkg/providers/workspace"
+	"github.com/kubevela/workflow/pkg/stepcache"
 	"github.com/kubevela/workflow/pkg/tasks"
 	"github.com/kubevela/workflow/pkg/tasks/template"
 	"github.com/kubevela/workflow/pkg/types"
@@ -52,11 +55,18 @@ func GenerateRunners(ctx monitorContext.Context, instance *types.WorkflowInstanc
 	options = initStepGeneratorOptions(ctx, instance, options)
 	taskDiscover := tasks.NewTaskDiscover(ctx, options)
 	var tasks []types.TaskRunner
-	for _, step := range instance.Steps {
+	// the runners of the exit handlers are generated after the workflow steps,
+	// the executor will run them after the workflow steps finished
+	steps := append([]v1alpha1.WorkflowStep{}, instance.Steps...)
+	steps = append(steps, instance.OnSuccess...)
+	steps = append(steps, instance.OnFailure...)
+	steps = append(steps, instance.Finally...)
+	for _, step := range steps {
 		opt := &types.TaskGeneratorOptions{
 			ID:              generateStepID(instance.Status, step.Name),
 			PackageDiscover: options.PackageDiscover,
 			ProcessContext:  options.ProcessCtx,
+			StepCache:       options.StepCache,
 		}
 		for typ, convertor := range options.StepConvertor {
 			if step.Type == typ {
@@ -74,10 +84,10 @@ func GenerateRunners(ctx monitorContext.Context, instance *types.WorkflowInstanc
 
 // GenerateWorkflowInstance generates a workflow instance
 func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) (*types.WorkflowInstance, error) {
-	var steps []v1alpha1.WorkflowStep
+	var spec v1alpha1.WorkflowSpec
 	switch {
 	case run.Spec.WorkflowSpec != nil:
-		steps = run.Spec.WorkflowSpec.Steps
+		spec = *run.Spec.WorkflowSpec
 	case run.Spec.WorkflowRef != "":
 		template := new(v1alpha1.Workflow)
 		if err := cli.Get(ctx, client.ObjectKey{
@@ -86,7 +96,7 @@ func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alp
 		}, template); err != nil {
 			return nil, err
 		}
-		steps = template.WorkflowSpec.Steps
+		spec = template.WorkflowSpec
 	default:
 		return nil, errors.New("failed to generate workflow instance")
 	}
@@ -95,6 +105,15 @@ func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alp
 	if run.Annotations != nil && run.Annotations[types.AnnotationWorkflowRunDebug] == "true" {
 		debug = true
 	}
+	dryRun := run.Spec.DryRun
+	if run.Annotations != nil && run.Annotations[types.AnnotationWorkflowRunDryRun] == "true" {
+		dryRun = true
+	}
+	breakpoints := parseBreakpoints(run.Annotations[types.AnnotationWorkflowRunBreakpoints])
+	// the snapshots of the steps are kept for the breakpoints
+	if len(breakpoints) > 0 {
+		debug = true
+	}
 
 	instance := &types.WorkflowInstance{
 		WorkflowMeta: types.WorkflowMeta{
@@ -112,15 +131,33 @@ func GenerateWorkflowInstance(ctx context.Context, cli client.Client, run *v1alp
 				},
 			},
 		},
-		Debug:  debug,
-		Mode:   run.Spec.Mode,
-		Steps:  steps,
-		Status: run.Status,
+		Debug:       debug,
+		DryRun:      dryRun,
+		Breakpoints: breakpoints,
+		Mode:        run.Spec.Mode,
+		Timeout:     run.Spec.Timeout,
+		Context:     run.Spec.Context,
+		Steps:       spec.Steps,
+		OnSuccess:   spec.OnSuccess,
+		OnFailure:   spec.OnFailure,
+		Finally:     spec.Finally,
+		Status:      run.Status,
 	}
 	executor.InitializeWorkflowInstance(instance)
 	return instance, nil
 }
 
+// parseBreakpoints parses the comma separated names of the steps in the breakpoints annotation
+func parseBreakpoints(annotation string) []string {
+	var breakpoints []string
+	for _, name := range strings.Split(annotation, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			breakpoints = append(breakpoints, name)
+		}
+	}
+	return breakpoints
+}
+
 func initStepGeneratorOptions(ctx monitorContext.Context, instance *types.WorkflowInstance, options types.StepGeneratorOptions) types.StepGeneratorOptions {
 	if options.Providers == nil {
 		options.Providers = providers.NewProviders()
@@ -132,18 +169,29 @@ func initStepGeneratorOptions(ctx monitorContext.Context, instance *types.Workfl
 	if options.TemplateLoader == nil {
 		options.TemplateLoader = template.NewWorkflowStepTemplateLoader(options.Client)
 	}
+	// the results in dry run are not real, they should neither be cached nor replayed
+	if instance.DryRun {
+		options.StepCache = nil
+	} else if options.StepCache == nil && options.Client != nil {
+		options.StepCache = stepcache.NewConfigMapStore(options.Client, instance.Namespace)
+	}
 	return options
 }
 
 func installBuiltinProviders(instance *types.WorkflowInstance, client client.Client, providerHandlers types.Providers, pCtx process.Context) {
+	labels := map[string]string{
+		types.LabelWorkflowRunName:      instance.Name,
+		types.LabelWorkflowRunNamespace: instance.Namespace,
+	}
+	// the providers registered first take effect, so that the recording providers replace the real ones in dry run
+	if instance.DryRun {
+		dryrun.Install(providerHandlers, client, labels)
+	}
 	workspace.Install(providerHandlers)
 	email.Install(providerHandlers)
 	util.Install(providerHandlers, pCtx)
 	http.Install(providerHandlers, client, instance.Namespace)
-	kube.Install(providerHandlers, client, map[string]string{
-		types.LabelWorkflowRunName:      instance.Name,
-		types.LabelWorkflowRunNamespace: instance.Namespace,
-	}, nil)
+	kube.Install(providerHandlers, client, labels, nil)
 }
 
 func generateTaskRunner(ctx context.Context,
@@ -155,20 +203,19 @@ func generateTaskRunner(ctx context.Context,
 	if step.Type == types.WorkflowStepTypeStepGroup {
 		var subTaskRunners []types.TaskRunner
 		for _, subStep := range step.SubSteps {
-			workflowStep := v1alpha1.WorkflowStep{
-				WorkflowStepBase: subStep,
-			}
 			o := &types.TaskGeneratorOptions{
 				ID:              generateSubStepID(instance.Status, subStep.Name, step.Name),
 				PackageDiscover: options.PackageDiscover,
 				ProcessContext:  options.ProcessContext,
+				StepCache:       options.StepCache,
 			}
 			for typ, convertor := range stepOptions.StepConvertor {
 				if subStep.Type == typ {
 					o.StepConvertor = convertor
 				}
 			}
-			subTask, err := generateTaskRunner(ctx, instance, workflowStep, taskDiscover, o, stepOptions)
+			// the sub step can be a step group as well, its sub steps are generated recursively
+			subTask, err := generateTaskRunner(ctx, instance, subStep, taskDiscover, o, stepOptions)
 			if err != nil {
 				return nil, err
 			}
@@ -179,6 +226,9 @@ func generateTaskRunner(ctx context.Context,
 		if instance.Mode != nil {
 			options.SubStepExecuteMode = instance.Mode.SubSteps
 		}
+		if step.Mode != "" {
+			options.SubStepExecuteMode = step.Mode
+		}
 	}
 
 	genTask, err := taskDiscover.GetTaskGenerator(ctx, step.Type)
@@ -194,9 +244,11 @@ func generateTaskRunner(ctx context.Context,
 }
 
 func generateStepID(status v1alpha1.WorkflowRunStatus, name string) string {
-	for _, ss := range status.Steps {
-		if ss.Name == name {
-			return ss.ID
+	for _, steps := range [][]v1alpha1.WorkflowStepStatus{status.Steps, status.ExitHandlers} {
+		for _, ss := range steps {
+			if ss.Name == name {
+				return ss.ID
+			}
 		}
 	}
 
@@ -204,8 +256,9 @@ func generateStepID(status v1alpha1.WorkflowRunStatus, name string) string {
 }
 
 func generateSubStepID(status v1alpha1.WorkflowRunStatus, name, parentStepName string) string {
-	for _, ss := range status.Steps {
-		if ss.Name == parentStepName {
+	for _, steps := range [][]v1alpha1.WorkflowStepStatus{status.Steps, status.ExitHandlers} {
+		// the parent step group can be nested in another one
+		if ss := types.FindStepStatus(steps, parentStepName); ss != nil {
 			for _, sub := range ss.SubStepsStatus {
 				if sub.Name == name {
 					return sub.ID
@@ -110,15 +110,15 @@ var _ = Describe("Test workflow step runner generator", func() {
 								Name: "step-1",
 								Type: "step-group",
 							},
-							SubSteps: []v1alpha1.WorkflowStepBase{
-								{
+							SubSteps: []v1alpha1.WorkflowStep{
+								{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 									Name: "step-1-1",
 									Type: "suspend",
-								},
-								{
+								}},
+								{WorkflowStepBase: v1alpha1.WorkflowStepBase{
 									Name: "step-1-2",
 									Type: "suspend",
-								},
+								}},
 							},
 						},
 					},
@@ -133,4 +133,23 @@ var _ = Describe("Test workflow step runner generator", func() {
 		Expect(len(runners)).Should(BeEquivalentTo(1))
 		Expect(runners[0].Name()).Should(BeEquivalentTo("step-1"))
 	})
+
+	It("Test generate workflow instance with breakpoints", func() {
+		wr := &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-wr-breakpoints",
+				Namespace:   "default",
+				Annotations: map[string]string{types.AnnotationWorkflowRunBreakpoints: "step-1, step-2,"},
+			},
+			Spec: v1alpha1.WorkflowRunSpec{
+				WorkflowSpec: &v1alpha1.WorkflowSpec{
+					Steps: []v1alpha1.WorkflowStep{{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "step-1", Type: "suspend"}}},
+				},
+			},
+		}
+		instance, err := GenerateWorkflowInstance(ctx, k8sClient, wr)
+		Expect(err).Should(BeNil())
+		Expect(instance.Breakpoints).Should(BeEquivalentTo([]string{"step-1", "step-2"}))
+		Expect(instance.Debug).Should(BeTrue())
+	})
 })
@@ -17,12 +17,16 @@ limitations under the License.
 package hooks
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
@@ -32,7 +36,25 @@ import (
 
 // Input set data to parameter.
 func Input(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.WorkflowStep) error {
+	return input(ctx, paramValue, step, nil, "")
+}
+
+// NewInput returns the hook which set data to parameter, the inputs from secrets are read in the namespace by
+// default. The secret values are filled into the parameter only, and they are redacted in the workflow context.
+func NewInput(cli client.Reader, namespace string) wfTypes.TaskPreStartHook {
+	return func(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.WorkflowStep) error {
+		return input(ctx, paramValue, step, cli, namespace)
+	}
+}
+
+func input(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.WorkflowStep, cli client.Reader, namespace string) error {
 	for _, input := range step.Inputs {
+		if input.FromSecret != nil {
+			if err := inputFromSecret(ctx, paramValue, input.ParameterKey, input.FromSecret, cli, namespace); err != nil {
+				return err
+			}
+			continue
+		}
 		inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
 		if err != nil {
 			return errors.WithMessagef(err, "get input from [%s]", input.From)
@@ -46,10 +68,65 @@ func Input(ctx wfContext.Context, paramValue *value.Value, step v1alpha1.Workflo
 	return nil
 }
 
+func inputFromSecret(ctx wfContext.Context, paramValue *value.Value, parameterKey string, selector *v1alpha1.SecretKeySelector, cli client.Reader, namespace string) error {
+	data, err := getSecretValue(selector, cli, namespace)
+	if err != nil {
+		return err
+	}
+	wfContext.AddSecretValues(ctx, string(data))
+	if parameterKey == "" {
+		return nil
+	}
+	inputValue, err := paramValue.MakeValue(strconv.Quote(string(data)))
+	if err != nil {
+		return errors.WithMessagef(err, "decode input from secret %s", selector.Name)
+	}
+	return paramValue.FillValueByScript(inputValue, parameterKey)
+}
+
+func getSecretValue(selector *v1alpha1.SecretKeySelector, cli client.Reader, namespace string) ([]byte, error) {
+	if cli == nil {
+		return nil, errors.Errorf("failed to get input from secret %s: the client is not set", selector.Name)
+	}
+	if selector.Namespace != "" {
+		namespace = selector.Namespace
+	}
+	secret := &corev1.Secret{}
+	if err := cli.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		return nil, errors.WithMessagef(err, "get input from secret %s", selector.Name)
+	}
+	data, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, errors.Errorf("key %s is not found in secret %s", selector.Key, selector.Name)
+	}
+	return data, nil
+}
+
+// RestoreSecretValues reads the inputs from secrets of the steps and records their values to be redacted, since
+// the values are only kept in the memory store which is cleaned up once the workflow is suspended, restarted or
+// finished, or the controller is restarted. The secrets failed to read are skipped, the steps report the errors
+// when they run.
+func RestoreSecretValues(ctx wfContext.Context, steps []v1alpha1.WorkflowStep, cli client.Reader, namespace string) {
+	var values []string
+	wfTypes.RangeSteps(steps, func(step v1alpha1.WorkflowStep) {
+		for _, input := range step.Inputs {
+			if input.FromSecret == nil {
+				continue
+			}
+			if data, err := getSecretValue(input.FromSecret, cli, namespace); err == nil {
+				values = append(values, string(data))
+			}
+		}
+	})
+	if len(values) > 0 {
+		wfContext.AddSecretValues(ctx, values...)
+	}
+}
+
 // Output get data from task value.
 func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, stepStatus map[string]v1alpha1.StepStatus) error {
 	errMsg := ""
-	if wfTypes.IsStepFinish(status.Phase, status.Reason) {
+	if wfTypes.IsStepStatusFinish(status) {
 		SetAdditionalNameInStatus(stepStatus, step.Name, step.Properties, status)
 		for _, output := range step.Outputs {
 			v, err := taskValue.LookupByScript(output.ValueFrom)
@@ -61,7 +138,16 @@ func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.Workflo
 			if err != nil || v.Error() != nil {
 				v, _ = taskValue.MakeValue("null")
 			}
-			if err := ctx.SetVar(v, output.Name); err != nil {
+			v, err = redactValue(ctx, v)
+			if err != nil {
+				errMsg += fmt.Sprintf("failed to redact output %s: %s\n", output.Name, err.Error())
+				continue
+			}
+			setVar := ctx.SetVar
+			if output.Artifact {
+				setVar = ctx.SetArtifact
+			}
+			if err := setVar(v, output.Name); err != nil {
 				errMsg += fmt.Sprintf("failed to set output %s: %s\n", output.Name, err.Error())
 			}
 		}
@@ -73,6 +159,18 @@ func Output(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.Workflo
 	return nil
 }
 
+// redactValue replaces the secret values in the value, so that they are never kept in the workflow context
+func redactValue(ctx wfContext.Context, v *value.Value) (*value.Value, error) {
+	str, err := v.String()
+	if err != nil {
+		return nil, err
+	}
+	if redacted := wfContext.Redact(ctx, str); redacted != str {
+		return v.MakeValue(redacted)
+	}
+	return v, nil
+}
+
 // SetAdditionalNameInStatus sets additional name from properties to status map
 func SetAdditionalNameInStatus(stepStatus map[string]v1alpha1.StepStatus, name string, properties *runtime.RawExtension, status v1alpha1.StepStatus) {
 	if stepStatus == nil || properties == nil {
@@ -22,8 +22,12 @@ import (
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
@@ -59,6 +63,84 @@ func TestInput(t *testing.T) {
 `)
 }
 
+func TestInputFromSecret(t *testing.T) {
+	wfCtx := mockContext(t)
+	defer wfContext.CleanupMemoryStore("v1", "default")
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "smtp", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte(`pass"word`)},
+	}).Build()
+	paramValue, err := wfCtx.MakeParameter(map[string]interface{}{
+		"name": "foo",
+	})
+	r.NoError(err)
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Inputs: v1alpha1.StepInputs{{
+				FromSecret:   &v1alpha1.SecretKeySelector{Name: "smtp", Key: "password"},
+				ParameterKey: "password",
+			}},
+			Outputs: v1alpha1.StepOutputs{{
+				ValueFrom: "output",
+				Name:      "message",
+			}},
+		},
+	}
+	r.Error(Input(wfCtx, paramValue, step))
+	r.NoError(NewInput(cli, "default")(wfCtx, paramValue, step))
+	result, err := paramValue.LookupValue("password")
+	r.NoError(err)
+	password, err := result.GetString()
+	r.NoError(err)
+	r.Equal(`pass"word`, password)
+
+	// the secret values are redacted in the outputs and the messages
+	taskValue, err := value.NewValue(`
+password: string
+output: "the password is \(password)"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(taskValue.FillObject(password, "password"))
+	r.NoError(Output(wfCtx, taskValue, step, v1alpha1.StepStatus{Phase: v1alpha1.WorkflowStepPhaseSucceeded}, nil))
+	result, err = wfCtx.GetVar("message")
+	r.NoError(err)
+	message, err := result.GetString()
+	r.NoError(err)
+	r.Equal("the password is "+wfContext.RedactedValue, message)
+	r.Equal("failed to login with "+wfContext.RedactedValue, wfContext.Redact(wfCtx, `failed to login with pass"word`))
+
+	step.Inputs[0].FromSecret.Key = "not-found"
+	r.Error(NewInput(cli, "default")(wfCtx, paramValue, step))
+}
+
+func TestRestoreSecretValues(t *testing.T) {
+	wfCtx := mockContext(t)
+	defer wfContext.CleanupMemoryStore("v1", "default")
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "smtp", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("password"), "user": []byte("foo")},
+	}).Build()
+	steps := []v1alpha1.WorkflowStep{{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "group"},
+		SubSteps: []v1alpha1.WorkflowStep{{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "notify",
+				Inputs: v1alpha1.StepInputs{
+					{FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "password"}},
+					{FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "user"}},
+					{FromSecret: &v1alpha1.SecretKeySelector{Name: "not-found", Key: "password"}},
+				},
+			},
+		}},
+	}}
+	r.Equal("login foo with password", wfContext.Redact(wfCtx, "login foo with password"))
+	// the values shorter than the minimum length are not redacted
+	RestoreSecretValues(wfCtx, steps, cli, "default")
+	r.Equal("login foo with "+wfContext.RedactedValue, wfContext.Redact(wfCtx, "login foo with password"))
+}
+
 func TestOutput(t *testing.T) {
 	wfCtx := mockContext(t)
 	r := require.New(t)
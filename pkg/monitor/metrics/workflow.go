@@ -74,6 +74,12 @@ var (
 		Buckets:     velametrics.FineGrainedBuckets,
 		ConstLabels: prometheus.Labels{},
 	}, []string{"controller", "step_type"})
+
+	// WorkflowRunGCDeletedCounter report the number of the resources deleted by the garbage collection of workflow runs
+	WorkflowRunGCDeletedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "workflowrun_gc_deleted_num",
+		Help: "workflow run garbage collection deleted number",
+	}, []string{"kind", "reason"})
 )
 
 var collectorGroup = []prometheus.Collector{
@@ -84,6 +90,7 @@ var collectorGroup = []prometheus.Collector{
 	WorkflowRunInitializedCounter,
 	WorkflowRunPhaseCounter,
 	WorkflowRunStepPhaseGauge,
+	WorkflowRunGCDeletedCounter,
 }
 
 func init() {
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/providers/email"
+	"github.com/kubevela/workflow/pkg/providers/http"
+	"github.com/kubevela/workflow/pkg/providers/kube"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// Manifest is the resource applied or deleted by the kube provider
+type Manifest struct {
+	Cluster string                     `json:"cluster,omitempty"`
+	Object  *unstructured.Unstructured `json:"object"`
+}
+
+// Request is the request sent by the http provider
+type Request struct {
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+// Email is the message sent by the email provider, the password of the sender is not recorded
+type Email struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
+
+// StepPlan is the recorded plan of a step in dry run
+type StepPlan struct {
+	Manifests []Manifest                 `json:"manifests,omitempty"`
+	Deletions []Manifest                 `json:"deletions,omitempty"`
+	Requests  []Request                  `json:"requests,omitempty"`
+	Emails    []Email                    `json:"emails,omitempty"`
+	Outputs   map[string]json.RawMessage `json:"outputs,omitempty"`
+}
+
+// the plans of the steps in the memory of the workflow context are shared by the steps running in parallel
+var planLock sync.Mutex
+
+func record(ctx context.Context, wfCtx wfContext.Context, fn func(plan *StepPlan)) error {
+	step := types.GetStepNameFromCtx(ctx)
+	if step == "" {
+		return errors.New("the step to record is not found in dry run")
+	}
+	planLock.Lock()
+	defer planLock.Unlock()
+	plan, ok := wfCtx.GetValueInMemory(types.ContextPrefixDryRun, step)
+	if !ok {
+		plan = &StepPlan{}
+		wfCtx.SetValueInMemory(plan, types.ContextPrefixDryRun, step)
+	}
+	fn(plan.(*StepPlan))
+	return nil
+}
+
+// TakePlan takes the recorded plan of the step out of the memory of the workflow context
+func TakePlan(wfCtx wfContext.Context, step string) *StepPlan {
+	planLock.Lock()
+	defer planLock.Unlock()
+	plan, ok := wfCtx.GetValueInMemory(types.ContextPrefixDryRun, step)
+	if !ok {
+		return &StepPlan{}
+	}
+	wfCtx.DeleteValueInMemory(types.ContextPrefixDryRun, step)
+	return plan.(*StepPlan)
+}
+
+type provider struct {
+	cli    client.Client
+	labels map[string]string
+}
+
+// kubeHandler runs the handler of the kube provider with the dispatchers which only collect the manifests,
+// so that the manifests are rendered the same as the real run.
+func (p *provider) kubeHandler(do string) types.Handler {
+	return func(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+		var applied, deleted []Manifest
+		handlers := providers.NewProviders()
+		kube.Install(handlers, p.cli, p.labels, &kube.Handlers{
+			Apply: func(_ context.Context, cluster, _ string, manifests ...*unstructured.Unstructured) error {
+				for _, manifest := range manifests {
+					applied = append(applied, Manifest{Cluster: cluster, Object: manifest})
+				}
+				return nil
+			},
+			Delete: func(_ context.Context, cluster, _ string, manifest *unstructured.Unstructured) error {
+				deleted = append(deleted, Manifest{Cluster: cluster, Object: manifest})
+				return nil
+			},
+		})
+		h, _ := handlers.GetHandler(kube.ProviderName, do)
+		if err := h(ctx, wfCtx, v, act); err != nil {
+			return err
+		}
+		return record(ctx, wfCtx, func(plan *StepPlan) {
+			plan.Manifests = append(plan.Manifests, applied...)
+			plan.Deletions = append(plan.Deletions, deleted...)
+		})
+	}
+}
+
+// Do records the http request and responds with an empty body.
+func (p *provider) Do(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	req := Request{}
+	var err error
+	if req.Method, err = v.GetString("method"); err != nil {
+		return err
+	}
+	if req.URL, err = v.GetString("url"); err != nil {
+		return err
+	}
+	if r, err := v.LookupValue("request"); err == nil {
+		if req.Request, err = r.CueValue().MarshalJSON(); err != nil {
+			return err
+		}
+	}
+	if err := record(ctx, wfCtx, func(plan *StepPlan) {
+		plan.Requests = append(plan.Requests, req)
+	}); err != nil {
+		return err
+	}
+	return v.FillObject(map[string]interface{}{
+		"statusCode": 200,
+		"body":       "",
+	}, "response")
+}
+
+// Send records the email.
+func (p *provider) Send(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
+	msg := Email{}
+	var err error
+	if msg.From, err = v.GetString("from", "address"); err != nil {
+		return err
+	}
+	to, err := v.LookupValue("to")
+	if err != nil {
+		return err
+	}
+	if err := to.UnmarshalTo(&msg.To); err != nil {
+		return err
+	}
+	if msg.Subject, err = v.GetString("content", "subject"); err != nil {
+		return err
+	}
+	if msg.Body, err = v.GetString("content", "body"); err != nil {
+		return err
+	}
+	return record(ctx, wfCtx, func(plan *StepPlan) {
+		plan.Emails = append(plan.Emails, msg)
+	})
+}
+
+// Install register the recording handlers of the kube, http and email providers, they must be installed before
+// the real providers to replace them.
+func Install(p types.Providers, cli client.Client, labels map[string]string) {
+	prd := &provider{
+		cli:    cli,
+		labels: labels,
+	}
+	p.Register(kube.ProviderName, map[string]types.Handler{
+		"apply":             prd.kubeHandler("apply"),
+		"apply-in-parallel": prd.kubeHandler("apply-in-parallel"),
+		"read":              prd.kubeHandler("read"),
+		"list":              prd.kubeHandler("list"),
+		"delete":            prd.kubeHandler("delete"),
+	})
+	p.Register(http.ProviderName, map[string]types.Handler{
+		"do": prd.Do,
+	})
+	p.Register(email.ProviderName, map[string]types.Handler{
+		"send": prd.Send,
+	})
+}
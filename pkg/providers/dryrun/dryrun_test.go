@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	monitorContext "github.com/kubevela/pkg/monitor/context"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/mock"
+	"github.com/kubevela/workflow/pkg/providers"
+	"github.com/kubevela/workflow/pkg/providers/kube"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestDryRun(t *testing.T) {
+	r := require.New(t)
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	wfCtx, err := wfContext.NewContext(cli, "default", "test", nil)
+	r.NoError(err)
+	p := providers.NewProviders()
+	Install(p, cli, map[string]string{"app": "test"})
+	// the real providers installed later don't take effect
+	kube.Install(p, cli, nil, nil)
+	ctx := monitorContext.NewTraceContext(types.SetStepNameInCtx(context.Background(), "step1"), "")
+	act := &mock.Action{}
+
+	h, ok := p.GetHandler("kube", "apply")
+	r.True(ok)
+	manifest, err := value.NewValue(`
+value: {
+	apiVersion: "v1"
+	kind: "ConfigMap"
+	metadata: name: "cm"
+	data: key: "value"
+}
+cluster: ""
+`, nil, "")
+	r.NoError(err)
+	r.NoError(h(ctx, wfCtx, manifest, act))
+	r.True(kerrors.IsNotFound(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "cm"}, &corev1.ConfigMap{})))
+
+	h, ok = p.GetHandler("http", "do")
+	r.True(ok)
+	v, err := value.NewValue(`
+method: "POST"
+url: "https://example.com"
+request: body: "hello"
+`, nil, "")
+	r.NoError(err)
+	r.NoError(h(ctx, wfCtx, v, act))
+	code, err := v.GetInt64("response", "statusCode")
+	r.NoError(err)
+	r.Equal(int64(200), code)
+
+	h, ok = p.GetHandler("email", "send")
+	r.True(ok)
+	v, err = value.NewValue(`
+from: {
+	address: "kubevela@example.com"
+	password: "secret"
+	host: "smtp.example.com"
+	port: 465
+}
+to: ["user@example.com"]
+content: {
+	subject: "hello"
+	body: "world"
+}
+`, nil, "")
+	r.NoError(err)
+	r.NoError(h(ctx, wfCtx, v, act))
+	r.Equal("", act.Phase)
+
+	out, err := value.NewValue(`"out"`, nil, "")
+	r.NoError(err)
+	r.NoError(wfCtx.SetVar(out, "message"))
+	instance := &types.WorkflowInstance{WorkflowMeta: types.WorkflowMeta{Name: "test", Namespace: "default"}}
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{
+		Name:    "step1",
+		Outputs: v1alpha1.StepOutputs{{Name: "message", ValueFrom: "message"}},
+	}}
+	r.NoError(SavePlan(ctx, cli, instance, wfCtx, step, v1alpha1.StepStatus{Phase: v1alpha1.WorkflowStepPhaseSucceeded}))
+
+	cm := &corev1.ConfigMap{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GeneratePlanName("test")}, cm))
+	plan := &StepPlan{}
+	r.NoError(json.Unmarshal([]byte(cm.Data["step1"]), plan))
+	r.Equal(1, len(plan.Manifests))
+	r.Equal("cm", plan.Manifests[0].Object.GetName())
+	r.Equal("default", plan.Manifests[0].Object.GetNamespace())
+	r.Equal(map[string]string{"app": "test"}, plan.Manifests[0].Object.GetLabels())
+	r.Equal([]Request{{Method: "POST", URL: "https://example.com", Request: json.RawMessage(`{"body":"hello"}`)}}, plan.Requests)
+	r.Equal([]Email{{From: "kubevela@example.com", To: []string{"user@example.com"}, Subject: "hello", Body: "world"}}, plan.Emails)
+	r.NotContains(cm.Data["step1"], "secret")
+	r.Equal(json.RawMessage(`"out"`), plan.Outputs["message"])
+
+	// the recorded plan is taken out after it's saved
+	r.Equal(&StepPlan{}, TakePlan(wfCtx, "step1"))
+
+	// the step is required to record the plan
+	h, _ = p.GetHandler("kube", "apply")
+	err = h(monitorContext.NewTraceContext(context.Background(), ""), wfCtx, manifest, act)
+	r.Equal("the step to record is not found in dry run", err.Error())
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// GeneratePlanName generates the name of the ConfigMap which stores the plans of the workflow run
+func GeneratePlanName(name string) string {
+	return fmt.Sprintf("%s-dryrun", name)
+}
+
+// SavePlan saves the plan recorded by the step together with the outputs of the finished step, the plan of the
+// step is overwritten every time the step runs.
+func SavePlan(ctx context.Context, cli client.Client, instance *types.WorkflowInstance, wfCtx wfContext.Context, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus) error {
+	plan := TakePlan(wfCtx, step.Name)
+	if types.IsStepStatusFinish(status) {
+		for _, output := range step.Outputs {
+			v, err := wfCtx.GetVar(output.Name)
+			if err != nil {
+				continue
+			}
+			b, err := v.CueValue().MarshalJSON()
+			if err != nil {
+				continue
+			}
+			if plan.Outputs == nil {
+				plan.Outputs = make(map[string]json.RawMessage)
+			}
+			plan.Outputs[output.Name] = b
+		}
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	return setStore(ctx, cli, instance, step.Name, string(data))
+}
+
+func setStore(ctx context.Context, cli client.Client, instance *types.WorkflowInstance, step, data string) error {
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(ctx, ktypes.NamespacedName{
+		Namespace: instance.Namespace,
+		Name:      GeneratePlanName(instance.Name),
+	}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			cm.Name = GeneratePlanName(instance.Name)
+			cm.Namespace = instance.Namespace
+			cm.Data = map[string]string{
+				step: data,
+			}
+			cm.SetOwnerReferences(instance.ChildOwnerReferences)
+			return cli.Create(ctx, cm)
+		}
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[step] = data
+	return cli.Update(ctx, cm)
+}
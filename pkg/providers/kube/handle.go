@@ -22,7 +22,9 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/util/feature"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	monitorContext "github.com/kubevela/pkg/monitor/context"
@@ -34,6 +36,7 @@ import (
 	"github.com/kubevela/workflow/pkg/cue"
 	"github.com/kubevela/workflow/pkg/cue/model"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/features"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -157,6 +160,7 @@ func (h *provider) Apply(ctx monitorContext.Context, wfCtx wfContext.Context, v
 	if err := h.handlers.Apply(deployCtx, cluster, WorkflowResourceCreator, workload); err != nil {
 		return err
 	}
+	watchObject(ctx, wfCtx, cluster, workload.GroupVersionKind(), client.ObjectKeyFromObject(workload))
 	return cue.FillUnstructuredObject(v, workload, "value")
 }
 
@@ -213,12 +217,33 @@ func (h *provider) Read(ctx monitorContext.Context, wfCtx wfContext.Context, v *
 		return err
 	}
 	readCtx := handleContext(ctx, cluster)
+	// the object is watched even if it's not found, the step waiting for its creation is woken up as well
+	watchObject(ctx, wfCtx, cluster, obj.GroupVersionKind(), key)
 	if err := h.cli.Get(readCtx, key, obj); err != nil {
 		return v.FillObject(err.Error(), "err")
 	}
 	return cue.FillUnstructuredObject(v, obj, "value")
 }
 
+// watchObject records the object in the local cluster read by the running step, so that the workflow run is woken
+// up by the changes of the object when the step is waiting, rather than polling it in the backoff.
+func watchObject(ctx monitorContext.Context, wfCtx wfContext.Context, cluster string, gvk schema.GroupVersionKind, key client.ObjectKey) {
+	if !feature.DefaultMutableFeatureGate.Enabled(features.EnableWatchWaitingResources) || !multicluster.IsLocal(cluster) {
+		return
+	}
+	step := types.GetStepNameFromCtx(ctx.GetContext())
+	if step == "" || wfCtx == nil || gvk.Kind == "" || key.Name == "" {
+		return
+	}
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	wfContext.AddWatchedObjects(wfCtx, step, wfContext.WatchedObject{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+	})
+}
+
 // List lists CRs from cluster.
 func (h *provider) List(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
 	r, err := v.LookupValue("resource")
@@ -78,7 +78,10 @@ func (p *provider) String(ctx monitorContext.Context, wfCtx wfContext.Context, v
 
 // Log print cue value in log
 func (p *provider) Log(ctx monitorContext.Context, wfCtx wfContext.Context, v *value.Value, act types.Action) error {
-	stepName := fmt.Sprint(p.pCtx.GetData(model.ContextStepName))
+	stepName := types.GetStepNameFromCtx(ctx.GetContext())
+	if stepName == "" {
+		stepName = fmt.Sprint(p.pCtx.GetData(model.ContextStepName))
+	}
 	config := make(map[string]types.LogConfig)
 	c := wfCtx.GetMutableValue(types.ContextKeyLogConfig)
 	if c != "" {
@@ -89,7 +92,7 @@ func (p *provider) Log(ctx monitorContext.Context, wfCtx wfContext.Context, v *v
 	stepConfig := config[stepName]
 	data, err := v.LookupValue("data")
 	if err == nil {
-		if err := printDataInLog(ctx, data, &stepConfig); err != nil {
+		if err := printDataInLog(ctx, wfCtx, data, &stepConfig); err != nil {
 			return err
 		}
 	}
@@ -108,11 +111,11 @@ func (p *provider) Log(ctx monitorContext.Context, wfCtx wfContext.Context, v *v
 	return nil
 }
 
-func printDataInLog(ctx monitorContext.Context, data *value.Value, stepConfig *types.LogConfig) error {
+func printDataInLog(ctx monitorContext.Context, wfCtx wfContext.Context, data *value.Value, stepConfig *types.LogConfig) error {
 	stepConfig.Data = true
 	logCtx := ctx.Fork("cue logs")
 	if s, err := data.GetString(); err == nil {
-		logCtx.Info(s)
+		logCtx.Info(wfContext.Redact(wfCtx, s))
 		return nil
 	}
 	var tmp interface{}
@@ -123,7 +126,7 @@ func printDataInLog(ctx monitorContext.Context, data *value.Value, stepConfig *t
 	if err != nil {
 		return err
 	}
-	logCtx.Info(string(b))
+	logCtx.Info(wfContext.Redact(wfCtx, string(b)))
 	return nil
 }
 
@@ -0,0 +1,254 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+const (
+	// LabelShardID is the label of the shard which the workflow run is assigned to, it's also set on the heartbeat
+	// leases of the shards
+	LabelShardID = "workflowrun.oam.dev/shard-id"
+	// MasterShardID is the id of the master shard, it schedules the workflow runs to the shards and runs the
+	// controllers which need the workflow runs in all the shards, e.g. the cron workflow controller
+	MasterShardID = "master"
+)
+
+// Args is the args of the sharding
+type Args struct {
+	// EnableSharding enables the sharding of the workflow runs across the replicas
+	EnableSharding bool
+	// ShardID is the id of the shard of the replica
+	ShardID string
+	// Namespace is the namespace of the heartbeat leases of the shards
+	Namespace string
+	// LeaseDuration is the duration after which the shard is considered unhealthy if its lease is not renewed
+	LeaseDuration time.Duration
+	// ScheduleInterval is the interval between two schedules of the unassigned workflow runs
+	ScheduleInterval time.Duration
+}
+
+// IsMaster returns true if the sharding is disabled or the replica is the master shard
+func (args Args) IsMaster() bool {
+	return !args.EnableSharding || args.ShardID == MasterShardID
+}
+
+// NewCacheFunc returns the cache builder which only watches the workflow runs in the shard of the replica. The master
+// watches the workflow runs in all the shards, since its controllers, e.g. the cron workflow controller, need them.
+func (args Args) NewCacheFunc() cache.NewCacheFunc {
+	if args.IsMaster() {
+		return cache.New
+	}
+	return cache.BuilderWithOptions(cache.Options{
+		SelectorsByObject: cache.SelectorsByObject{
+			&v1alpha1.WorkflowRun{}: {Label: labels.SelectorFromSet(map[string]string{LabelShardID: args.ShardID})},
+		},
+	})
+}
+
+// ShardPredicate returns the predicate which only admits the workflow runs in the shard of the replica, it filters the
+// workflow runs reconciled by the master whose cache has the runs in all the shards.
+func (args Args) ShardPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return args.InShard(obj)
+	})
+}
+
+// InShard returns true if the sharding is disabled or the object is assigned to the shard of the replica
+func (args Args) InShard(obj client.Object) bool {
+	return !args.EnableSharding || obj.GetLabels()[LabelShardID] == args.ShardID
+}
+
+// GenerateLeaseName generates the name of the heartbeat lease of the shard
+func GenerateLeaseName(shardID string) string {
+	return fmt.Sprintf("workflow-shard-%s", shardID)
+}
+
+// Heartbeat renews the lease of the shard periodically, so that the scheduler knows the shard is healthy.
+type Heartbeat struct {
+	Args
+	cli client.Client
+	now func() time.Time
+}
+
+// NewHeartbeat creates the heartbeat of the shard
+func NewHeartbeat(cli client.Client, args Args) *Heartbeat {
+	return &Heartbeat{Args: args, cli: cli, now: time.Now}
+}
+
+// Start implements manager.Runnable, it renews the lease until the context is done.
+func (h *Heartbeat) Start(ctx context.Context) error {
+	klog.InfoS("Start the heartbeat of the shard", "shard", h.ShardID)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := h.Renew(ctx); err != nil {
+			klog.ErrorS(err, "Failed to renew the lease of the shard", "shard", h.ShardID)
+		}
+	}, h.LeaseDuration/3)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, only the leader of the shard is working
+func (h *Heartbeat) NeedLeaderElection() bool {
+	return true
+}
+
+// Renew creates or renews the lease of the shard
+func (h *Heartbeat) Renew(ctx context.Context) error {
+	lease := &coordinationv1.Lease{}
+	key := client.ObjectKey{Namespace: h.Namespace, Name: GenerateLeaseName(h.ShardID)}
+	now := metav1.NewMicroTime(h.now())
+	if err := h.cli.Get(ctx, key, lease); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		lease.Name = key.Name
+		lease.Namespace = key.Namespace
+		lease.Labels = map[string]string{LabelShardID: h.ShardID}
+		lease.Spec = coordinationv1.LeaseSpec{
+			HolderIdentity:       pointer.String(h.ShardID),
+			LeaseDurationSeconds: pointer.Int32(int32(h.LeaseDuration.Seconds())),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+		return h.cli.Create(ctx, lease)
+	}
+	lease.Spec.LeaseDurationSeconds = pointer.Int32(int32(h.LeaseDuration.Seconds()))
+	lease.Spec.RenewTime = &now
+	return h.cli.Update(ctx, lease)
+}
+
+// Scheduler assigns the workflow runs without shard or in the unhealthy shards to the healthy shards.
+type Scheduler struct {
+	Args
+	cli    client.Client
+	reader client.Reader
+	now    func() time.Time
+}
+
+// NewScheduler creates the scheduler of the workflow runs, the reader should read the workflow runs from the api
+// server, so that the runs are not assigned twice from a stale cache.
+func NewScheduler(cli client.Client, reader client.Reader, args Args) *Scheduler {
+	return &Scheduler{Args: args, cli: cli, reader: reader, now: time.Now}
+}
+
+// Start implements manager.Runnable, it schedules the workflow runs periodically until the context is done.
+func (s *Scheduler) Start(ctx context.Context) error {
+	klog.InfoS("Start the scheduler of the shards", "interval", s.ScheduleInterval)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Schedule(ctx); err != nil {
+			klog.ErrorS(err, "Failed to schedule the workflow runs to the shards")
+		}
+	}, s.ScheduleInterval)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (s *Scheduler) NeedLeaderElection() bool {
+	return true
+}
+
+// Schedule assigns the workflow runs once
+func (s *Scheduler) Schedule(ctx context.Context) error {
+	shards, err := s.GetHealthyShards(ctx)
+	if err != nil {
+		return err
+	}
+	if len(shards) == 0 {
+		klog.InfoS("No healthy shard to schedule the workflow runs")
+		return nil
+	}
+	// the selector matches the runs without the label as well
+	selector, err := labels.Parse(fmt.Sprintf("%s notin (%s)", LabelShardID, strings.Join(shards, ",")))
+	if err != nil {
+		return err
+	}
+	runs := &v1alpha1.WorkflowRunList{}
+	if err := s.reader.List(ctx, runs, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return errors.WithMessage(err, "list workflow runs")
+	}
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if run.DeletionTimestamp != nil {
+			continue
+		}
+		shard := PickShard(run.Namespace+"/"+run.Name, shards)
+		patch := client.MergeFromWithOptions(run.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		from := run.Labels[LabelShardID]
+		if run.Labels == nil {
+			run.Labels = map[string]string{}
+		}
+		run.Labels[LabelShardID] = shard
+		if err := s.cli.Patch(ctx, run, patch); err != nil {
+			// the run is changed or deleted, it's scheduled in the next round
+			if kerrors.IsConflict(err) || kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.WithMessagef(err, "schedule workflow run %s/%s", run.Namespace, run.Name)
+		}
+		klog.InfoS("Schedule the workflow run to the shard", "workflowrun", client.ObjectKeyFromObject(run), "shard", shard, "from", from)
+	}
+	return nil
+}
+
+// GetHealthyShards returns the shards whose leases are renewed in the lease duration
+func (s *Scheduler) GetHealthyShards(ctx context.Context) ([]string, error) {
+	leases := &coordinationv1.LeaseList{}
+	if err := s.cli.List(ctx, leases, client.InNamespace(s.Namespace), client.HasLabels{LabelShardID}); err != nil {
+		return nil, errors.WithMessage(err, "list leases of shards")
+	}
+	now := s.now()
+	var shards []string
+	for _, lease := range leases.Items {
+		if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expire := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if now.Before(expire) {
+			shards = append(shards, lease.Labels[LabelShardID])
+		}
+	}
+	sort.Strings(shards)
+	return shards, nil
+}
+
+// PickShard picks the shard for the key from the sorted shards, the same shard is picked for the key by all the
+// replicas as long as they see the same shards.
+func PickShard(key string, shards []string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return shards[h.Sum32()%uint32(len(shards))]
+}
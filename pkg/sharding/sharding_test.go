@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+func TestPickShard(t *testing.T) {
+	r := require.New(t)
+	shards := []string{"master", "shard-1", "shard-2"}
+	picked := map[string]bool{}
+	for _, key := range []string{"default/a", "default/b", "default/c", "default/d", "default/e", "default/f"} {
+		shard := PickShard(key, shards)
+		r.Contains(shards, shard)
+		r.Equal(shard, PickShard(key, shards))
+		picked[shard] = true
+	}
+	r.True(len(picked) > 1)
+	r.Equal("master", PickShard("default/a", []string{"master"}))
+}
+
+func TestSchedule(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	r.NoError(clientgoscheme.AddToScheme(scheme))
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default"}},
+		&v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "healthy", Namespace: "default", Labels: map[string]string{LabelShardID: "shard-1"}}},
+		&v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: "default", Labels: map[string]string{LabelShardID: "shard-2"}}},
+	).Build()
+	args := Args{EnableSharding: true, Namespace: "vela-system", LeaseDuration: 30 * time.Second}
+	now := time.Now()
+
+	scheduler := NewScheduler(cli, cli, args)
+	scheduler.now = func() time.Time { return now }
+	// nothing is scheduled without the healthy shards
+	r.NoError(scheduler.Schedule(ctx))
+	run := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "new"}, run))
+	r.Empty(run.Labels[LabelShardID])
+
+	for _, shard := range []string{MasterShardID, "shard-1", "shard-2"} {
+		args.ShardID = shard
+		heartbeat := NewHeartbeat(cli, args)
+		heartbeat.now = func() time.Time { return now }
+		r.NoError(heartbeat.Renew(ctx))
+	}
+	// the lease of shard-2 expires
+	now = now.Add(20 * time.Second)
+	for _, shard := range []string{MasterShardID, "shard-1"} {
+		args.ShardID = shard
+		heartbeat := NewHeartbeat(cli, args)
+		heartbeat.now = func() time.Time { return now }
+		r.NoError(heartbeat.Renew(ctx))
+	}
+	now = now.Add(20 * time.Second)
+	shards, err := scheduler.GetHealthyShards(ctx)
+	r.NoError(err)
+	r.Equal([]string{MasterShardID, "shard-1"}, shards)
+
+	r.NoError(scheduler.Schedule(ctx))
+	for _, name := range []string{"new", "healthy", "orphan"} {
+		r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, run))
+		r.Contains(shards, run.Labels[LabelShardID])
+		if name == "healthy" {
+			r.Equal("shard-1", run.Labels[LabelShardID])
+		} else {
+			r.Equal(PickShard("default/"+name, shards), run.Labels[LabelShardID])
+		}
+	}
+}
+
+func TestInShard(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default", Labels: map[string]string{LabelShardID: "shard-1"}}}
+	r.True(Args{}.InShard(run))
+	r.True(Args{EnableSharding: true, ShardID: "shard-1"}.InShard(run))
+	r.False(Args{EnableSharding: true, ShardID: MasterShardID}.InShard(run))
+	r.False(Args{EnableSharding: true, ShardID: MasterShardID}.ShardPredicate().Generic(event.GenericEvent{Object: run}))
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// ConfigMapPrefix is the name prefix of the ConfigMaps which store the cached step results, each of them stores
+	// the result of a cache key
+	ConfigMapPrefix = "workflow-step-cache-"
+	// LabelStepCache is the label of the ConfigMaps which store the cached step results
+	LabelStepCache = "workflow.oam.dev/step-cache"
+	// AnnotationLastUsedTime is the annotation of the time when the cached result is set or hit last time, the least
+	// recently used results are evicted first
+	AnnotationLastUsedTime = "workflow.oam.dev/last-used-time"
+
+	dataKeyKey   = "key"
+	dataKeyEntry = "entry"
+)
+
+var (
+	// MaxEntries is the max number of the cached step results in a namespace, the least recently used ones are
+	// evicted when it's exceeded
+	MaxEntries = 256
+	// touchInterval is the min interval to update the last used time of a cached result when it's hit
+	touchInterval = time.Minute
+)
+
+type configMapStore struct {
+	cli       client.Client
+	namespace string
+	now       func() time.Time
+}
+
+// NewConfigMapStore returns the step cache stored in the ConfigMaps of the namespace, the cached results are shared
+// by the workflow runs in the namespace.
+func NewConfigMapStore(cli client.Client, namespace string) types.StepCache {
+	return &configMapStore{
+		cli:       cli,
+		namespace: namespace,
+		now:       time.Now,
+	}
+}
+
+// GenerateConfigMapName generates the name of the ConfigMap which stores the cached result of the key
+func GenerateConfigMapName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return ConfigMapPrefix + hex.EncodeToString(h[:])[:32]
+}
+
+// Get gets the cached result by the key, the expired result is deleted
+func (s *configMapStore) Get(ctx context.Context, key string) (*types.StepCacheEntry, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.cli.Get(ctx, ktypes.NamespacedName{Namespace: s.namespace, Name: GenerateConfigMapName(key)}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cm.Data[dataKeyKey] != key {
+		return nil, nil
+	}
+	entry, err := decodeEntry(cm)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if isExpired(entry, now) {
+		return nil, s.delete(ctx, cm)
+	}
+	if lastUsed, err := time.Parse(time.RFC3339, cm.Annotations[AnnotationLastUsedTime]); err != nil || now.Sub(lastUsed) > touchInterval {
+		// the last used time is only used to evict the results, it's fine to fail to update it
+		touched := cm.DeepCopy()
+		setLastUsedTime(touched, now)
+		_ = s.cli.Patch(ctx, touched, client.MergeFrom(cm))
+	}
+	return entry, nil
+}
+
+// Set sets the cached result of the key, the expired results and the least recently used ones over MaxEntries are
+// pruned at the same time
+func (s *configMapStore) Set(ctx context.Context, key string, entry *types.StepCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	cm := &corev1.ConfigMap{}
+	if err := s.cli.Get(ctx, ktypes.NamespacedName{Namespace: s.namespace, Name: GenerateConfigMapName(key)}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cm.Name = GenerateConfigMapName(key)
+		cm.Namespace = s.namespace
+		cm.Labels = map[string]string{LabelStepCache: "true"}
+		cm.Data = map[string]string{dataKeyKey: key, dataKeyEntry: string(data)}
+		setLastUsedTime(cm, now)
+		if err := s.cli.Create(ctx, cm); err != nil {
+			return err
+		}
+	} else {
+		cm.Data = map[string]string{dataKeyKey: key, dataKeyEntry: string(data)}
+		setLastUsedTime(cm, now)
+		if err := s.cli.Update(ctx, cm); err != nil {
+			return err
+		}
+	}
+	return s.prune(ctx, now)
+}
+
+// prune deletes the expired results and evicts the least recently used ones over MaxEntries
+func (s *configMapStore) prune(ctx context.Context, now time.Time) error {
+	cms := &corev1.ConfigMapList{}
+	if err := s.cli.List(ctx, cms, client.InNamespace(s.namespace), client.MatchingLabels{LabelStepCache: "true"}); err != nil {
+		return err
+	}
+	var errs []error
+	var kept []*corev1.ConfigMap
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		if entry, err := decodeEntry(cm); err != nil || isExpired(entry, now) {
+			errs = append(errs, s.delete(ctx, cm))
+			continue
+		}
+		kept = append(kept, cm)
+	}
+	if len(kept) > MaxEntries {
+		sort.Slice(kept, func(i, j int) bool {
+			return kept[i].Annotations[AnnotationLastUsedTime] < kept[j].Annotations[AnnotationLastUsedTime]
+		})
+		for _, cm := range kept[:len(kept)-MaxEntries] {
+			errs = append(errs, s.delete(ctx, cm))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s *configMapStore) delete(ctx context.Context, cm *corev1.ConfigMap) error {
+	return client.IgnoreNotFound(s.cli.Delete(ctx, cm))
+}
+
+func decodeEntry(cm *corev1.ConfigMap) (*types.StepCacheEntry, error) {
+	entry := &types.StepCacheEntry{}
+	if err := json.Unmarshal([]byte(cm.Data[dataKeyEntry]), entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func isExpired(entry *types.StepCacheEntry, now time.Time) bool {
+	return entry.ExpirationTime != nil && entry.ExpirationTime.Time.Before(now)
+}
+
+func setLastUsedTime(cm *corev1.ConfigMap, now time.Time) {
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[AnnotationLastUsedTime] = now.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	store := NewConfigMapStore(cli, "default")
+
+	entry, err := store.Get(ctx, "key1")
+	r.NoError(err)
+	r.Nil(entry)
+
+	expired := metav1.NewTime(time.Now().Add(-time.Minute))
+	r.NoError(store.Set(ctx, "expired", &types.StepCacheEntry{ExpirationTime: &expired}))
+	r.NoError(store.Set(ctx, "key1", &types.StepCacheEntry{Outputs: map[string]string{"ip": `"1.1.1.1"`}}))
+
+	entry, err = store.Get(ctx, "key1")
+	r.NoError(err)
+	r.Equal(map[string]string{"ip": `"1.1.1.1"`}, entry.Outputs)
+	r.Nil(entry.ExpirationTime)
+
+	// each result is stored in its own ConfigMap, the expired ones are pruned on set
+	cms := &corev1.ConfigMapList{}
+	r.NoError(cli.List(ctx, cms, client.InNamespace("default"), client.MatchingLabels{LabelStepCache: "true"}))
+	r.Equal(1, len(cms.Items))
+	r.Equal(GenerateConfigMapName("key1"), cms.Items[0].Name)
+	r.Equal("key1", cms.Items[0].Data[dataKeyKey])
+
+	// the expired result is deleted on get
+	r.NoError(store.Set(ctx, "key2", &types.StepCacheEntry{ExpirationTime: &expired}))
+	entry, err = store.Get(ctx, "key2")
+	r.NoError(err)
+	r.Nil(entry)
+	cm := &corev1.ConfigMap{}
+	r.Error(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: GenerateConfigMapName("key2")}, cm))
+}
+
+func TestConfigMapStoreEviction(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	store := NewConfigMapStore(cli, "default").(*configMapStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	defer func(max int) { MaxEntries = max }(MaxEntries)
+	MaxEntries = 2
+
+	r.NoError(store.Set(ctx, "key1", &types.StepCacheEntry{}))
+	now = now.Add(time.Hour)
+	r.NoError(store.Set(ctx, "key2", &types.StepCacheEntry{}))
+	now = now.Add(time.Hour)
+	// key1 is hit, so key2 is the least recently used one
+	entry, err := store.Get(ctx, "key1")
+	r.NoError(err)
+	r.NotNil(entry)
+	now = now.Add(time.Hour)
+	r.NoError(store.Set(ctx, "key3", &types.StepCacheEntry{}))
+
+	for key, cached := range map[string]bool{"key1": true, "key2": false, "key3": true} {
+		entry, err := store.Get(ctx, key)
+		r.NoError(err)
+		r.Equal(cached, entry != nil, key)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kubevela/pkg/util/rand"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/cue/process"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+const (
+	// foreachItemsKey is the parameter key of the items to fan out
+	foreachItemsKey = "items"
+	// foreachDefaultItemKey is the default parameter key of the item in the generated sub steps
+	foreachDefaultItemKey = "item"
+)
+
+// ForeachProperties is the properties of the foreach step
+type ForeachProperties struct {
+	// Items is the list to fan out, it can also be set by the input with parameterKey `items`
+	Items []interface{} `json:"items,omitempty"`
+	// ItemKey is the parameter key of the item in the generated sub steps, default to `item`
+	ItemKey string `json:"itemKey,omitempty"`
+	// MaxParallelism is the max number of the sub steps running at the same time, no limit if it's not set
+	MaxParallelism int `json:"maxParallelism,omitempty"`
+	// Step is the template of the generated sub steps
+	Step v1alpha1.WorkflowStepBase `json:"step"`
+}
+
+// NewForeachGenerator returns the generator of the foreach step, the sub steps are generated
+// at runtime by the given task discover since the items may come from the inputs.
+func NewForeachGenerator(discover types.TaskDiscover) types.TaskGenerator {
+	return func(step v1alpha1.WorkflowStep, opt *types.TaskGeneratorOptions) (types.TaskRunner, error) {
+		return &foreachTaskRunner{
+			id:       opt.ID,
+			name:     step.Name,
+			step:     step,
+			discover: discover,
+			pd:       opt.PackageDiscover,
+			pCtx:     opt.ProcessContext,
+			cache:    opt.StepCache,
+		}, nil
+	}
+}
+
+type foreachTaskRunner struct {
+	id       string
+	name     string
+	step     v1alpha1.WorkflowStep
+	discover types.TaskDiscover
+	pd       *packages.PackageDiscover
+	pCtx     process.Context
+	cache    types.StepCache
+}
+
+// Name return foreach step name.
+func (tr *foreachTaskRunner) Name() string {
+	return tr.name
+}
+
+// Pending check task should be executed or not.
+func (tr *foreachTaskRunner) Pending(ctx wfContext.Context, stepStatus map[string]v1alpha1.StepStatus) (bool, v1alpha1.StepStatus) {
+	return custom.CheckPending(ctx, tr.step, tr.id, stepStatus)
+}
+
+// Run fans out the items into sub steps and runs them.
+func (tr *foreachTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOptions) (status v1alpha1.StepStatus, operations *types.Operation, rErr error) {
+	status = v1alpha1.StepStatus{
+		ID:      tr.id,
+		Name:    tr.name,
+		Type:    types.WorkflowStepTypeForeach,
+		Message: "",
+	}
+	operations = &types.Operation{}
+
+	defer func() {
+		handleOutput(ctx, &status, operations, tr.step, options.PostStopHooks, tr.pd, tr.id, tr.pCtx)
+	}()
+	for _, hook := range options.PreCheckHooks {
+		result, err := hook(tr.step, &types.PreCheckOptions{
+			PackageDiscover: tr.pd,
+			ProcessContext:  options.PCtx,
+		})
+		if err != nil {
+			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
+			status.Reason = types.StatusReasonSkip
+			status.Message = fmt.Sprintf("pre check error: %s", err.Error())
+			continue
+		}
+		if result.Fail {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = result.GetFailedReason()
+			status.Message = result.Message
+			operations.Terminated = true
+			return status, operations, nil
+		}
+		if result.Skip {
+			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
+			status.Reason = types.StatusReasonSkip
+			options.StepStatus[tr.step.Name] = status
+			break
+		}
+		if result.Timeout {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = types.StatusReasonTimeout
+			options.StepStatus[tr.step.Name] = status
+		}
+	}
+
+	if status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+		return status, &types.Operation{Skip: true}, nil
+	}
+
+	e := options.Engine
+	stepStatus := e.GetStepStatus(tr.name)
+	var subTaskRunners []types.TaskRunner
+	props, err := tr.getProperties(ctx)
+	if err == nil {
+		subTaskRunners, err = tr.generateSubTaskRunners(props, stepStatus)
+	}
+	if err != nil {
+		if status.Phase != v1alpha1.WorkflowStepPhaseFailed {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = types.StatusReasonParameter
+			status.Message = err.Error()
+		}
+		operations.Terminated = true
+		return status, operations, nil
+	}
+
+	if runners := limitParallelism(subTaskRunners, stepStatus, props.MaxParallelism); len(runners) > 0 {
+		e.SetParentRunner(tr.name)
+		err := e.Run(runners, true)
+		e.SetParentRunner("")
+		if err != nil {
+			return v1alpha1.StepStatus{
+				ID:    tr.id,
+				Name:  tr.name,
+				Type:  types.WorkflowStepTypeForeach,
+				Phase: v1alpha1.WorkflowStepPhaseRunning,
+			}, e.GetOperation(), err
+		}
+	}
+
+	status, operations = getStepGroupStatus(status, e.GetStepStatus(tr.name), e.GetOperation(), len(subTaskRunners))
+	return status, operations, nil
+}
+
+func (tr *foreachTaskRunner) getProperties(ctx wfContext.Context) (*ForeachProperties, error) {
+	props := &ForeachProperties{}
+	if tr.step.Properties != nil && len(tr.step.Properties.Raw) > 0 {
+		if err := json.Unmarshal(tr.step.Properties.Raw, props); err != nil {
+			return nil, errors.WithMessage(err, "invalid foreach properties")
+		}
+	}
+	for _, input := range tr.step.Inputs {
+		if input.ParameterKey != foreachItemsKey || input.FromSecret != nil {
+			continue
+		}
+		v, err := ctx.GetVar(strings.Split(input.From, ".")...)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get input from [%s]", input.From)
+		}
+		var items []interface{}
+		if err := v.CueValue().Decode(&items); err != nil {
+			return nil, errors.WithMessagef(err, "input value from [%s] is not a valid list", input.From)
+		}
+		props.Items = items
+	}
+	if props.Step.Type == "" {
+		return nil, errors.New("the type of the foreach step is required")
+	}
+	if props.ItemKey == "" {
+		props.ItemKey = foreachDefaultItemKey
+	}
+	return props, nil
+}
+
+func (tr *foreachTaskRunner) generateSubTaskRunners(props *ForeachProperties, stepStatus v1alpha1.WorkflowStepStatus) ([]types.TaskRunner, error) {
+	generator, err := tr.discover.GetTaskGenerator(context.Background(), props.Step.Type)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]interface{}{}
+	if props.Step.Properties != nil && len(props.Step.Properties.Raw) > 0 {
+		if err := json.Unmarshal(props.Step.Properties.Raw, &params); err != nil {
+			return nil, errors.WithMessage(err, "invalid properties of the foreach step")
+		}
+	}
+	var runners []types.TaskRunner
+	for i, item := range props.Items {
+		sub := *props.Step.DeepCopy()
+		sub.Name = fmt.Sprintf("%s-%d", tr.name, i)
+		params[props.ItemKey] = item
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		sub.Properties = &runtime.RawExtension{Raw: raw}
+		id := rand.RandomString(10)
+		for _, ss := range stepStatus.SubStepsStatus {
+			if ss.Name == sub.Name {
+				id = ss.ID
+				break
+			}
+		}
+		runner, err := generator(v1alpha1.WorkflowStep{WorkflowStepBase: sub}, &types.TaskGeneratorOptions{
+			ID:              id,
+			PackageDiscover: tr.pd,
+			ProcessContext:  tr.pCtx,
+			StepCache:       tr.cache,
+		})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "generate sub step %s", sub.Name)
+		}
+		runners = append(runners, runner)
+	}
+	return runners, nil
+}
+
+// limitParallelism returns the runners to run in this round, the finished runners are kept
+// so that the engine can check the dependencies, and at most maxParallelism unfinished runners are started.
+func limitParallelism(runners []types.TaskRunner, stepStatus v1alpha1.WorkflowStepStatus, maxParallelism int) []types.TaskRunner {
+	if maxParallelism <= 0 {
+		return runners
+	}
+	finished := make(map[string]bool)
+	for _, ss := range stepStatus.SubStepsStatus {
+		finished[ss.Name] = types.IsStepStatusFinish(ss.StepStatus)
+	}
+	var result []types.TaskRunner
+	running := 0
+	for _, runner := range runners {
+		if finished[runner.Name()] {
+			result = append(result, runner)
+			continue
+		}
+		if running < maxParallelism {
+			result = append(result, runner)
+			running++
+		}
+	}
+	return result
+}
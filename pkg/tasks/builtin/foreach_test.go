@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+type testDiscover struct{}
+
+func (d *testDiscover) GetTaskGenerator(ctx context.Context, name string) (types.TaskGenerator, error) {
+	if name != "apply" {
+		return nil, errors.Errorf("can't find task generator: %s", name)
+	}
+	return Suspend, nil
+}
+
+type recordEngine struct {
+	testEngine
+	runners []types.TaskRunner
+}
+
+func (e *recordEngine) Run(taskRunners []types.TaskRunner, dag bool) error {
+	e.runners = taskRunners
+	return nil
+}
+
+func TestForeachStep(t *testing.T) {
+	r := require.New(t)
+	gen := NewForeachGenerator(&testDiscover{})
+	runner, err := gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "test",
+			Type: types.WorkflowStepTypeForeach,
+			Properties: &runtime.RawExtension{Raw: []byte(`{
+				"items": ["a", "b", "c"],
+				"maxParallelism": 1,
+				"step": {"type": "apply", "properties": {"cluster": "local"}}
+			}`)},
+		},
+	}, &types.TaskGeneratorOptions{ID: "124"})
+	r.NoError(err)
+	r.Equal(runner.Name(), "test")
+
+	e := &recordEngine{
+		testEngine: testEngine{
+			stepStatus: v1alpha1.WorkflowStepStatus{
+				SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+					{StepStatus: v1alpha1.StepStatus{
+						ID:    "sub-0",
+						Name:  "test-0",
+						Phase: v1alpha1.WorkflowStepPhaseSucceeded,
+					}},
+				},
+			},
+			operation: &types.Operation{},
+		},
+	}
+	status, _, err := runner.Run(nil, &types.TaskRunOptions{
+		StepStatus: map[string]v1alpha1.StepStatus{},
+		Engine:     e,
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseRunning)
+	r.Equal(len(e.runners), 2)
+	r.Equal(e.runners[0].Name(), "test-0")
+	r.Equal(e.runners[0].(*suspendTaskRunner).id, "sub-0")
+	r.Equal(e.runners[1].Name(), "test-1")
+	r.Equal(string(e.runners[1].(*suspendTaskRunner).step.Properties.Raw), `{"cluster":"local","item":"b"}`)
+
+	// test invalid step type
+	runner, err = gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "invalid",
+			Type:       types.WorkflowStepTypeForeach,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"items": ["a"], "step": {"type": "not-exist"}}`)},
+		},
+	}, &types.TaskGeneratorOptions{ID: "125"})
+	r.NoError(err)
+	status, operations, err := runner.Run(nil, &types.TaskRunOptions{
+		StepStatus: map[string]v1alpha1.StepStatus{},
+		Engine:     &recordEngine{testEngine: testEngine{operation: &types.Operation{}}},
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, types.StatusReasonParameter)
+	r.Equal(operations.Terminated, true)
+}
@@ -82,6 +82,12 @@ func (tr *stepGroupTaskRunner) Run(ctx wfContext.Context, options *types.TaskRun
 			status.Message = fmt.Sprintf("pre check error: %s", err.Error())
 			continue
 		}
+		if result.Fail {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = result.GetFailedReason()
+			status.Message = result.Message
+			return status, &types.Operation{Terminated: true}, nil
+		}
 		if result.Skip {
 			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
 			status.Reason = types.StatusReasonSkip
@@ -103,7 +109,10 @@ func (tr *stepGroupTaskRunner) Run(ctx wfContext.Context, options *types.TaskRun
 		if tr.mode == v1alpha1.WorkflowModeStep {
 			dag = false
 		}
-		if err := e.Run(tr.subTaskRunners, dag); err != nil {
+		err := e.Run(tr.subTaskRunners, dag)
+		// restore the parent runner of the step group, it can be a sub step of another step group
+		e.SetParentRunner("")
+		if err != nil {
 			return v1alpha1.StepStatus{
 				ID:    tr.id,
 				Name:  tr.name,
@@ -111,7 +120,6 @@ func (tr *stepGroupTaskRunner) Run(ctx wfContext.Context, options *types.TaskRun
 				Phase: v1alpha1.WorkflowStepPhaseRunning,
 			}, e.GetOperation(), err
 		}
-		e.SetParentRunner("")
 	}
 
 	stepStatus := e.GetStepStatus(tr.name)
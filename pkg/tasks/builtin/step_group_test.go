@@ -97,6 +97,25 @@ func TestStepGroupStep(t *testing.T) {
 	r.Equal(status.Reason, types.StatusReasonTimeout)
 	r.Equal(operations.Terminated, true)
 
+	// test pre check fail
+	status, operations, err = runner.Run(nil, &types.TaskRunOptions{
+		PreCheckHooks: []types.TaskPreCheckHook{
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return &types.PreCheckResult{Fail: true, Reason: "Denied", Message: "denied by the policy"}, nil
+			},
+		},
+		StepStatus: map[string]v1alpha1.StepStatus{},
+		Engine: &testEngine{
+			stepStatus: v1alpha1.WorkflowStepStatus{},
+			operation:  &types.Operation{},
+		},
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, "Denied")
+	r.Equal(status.Message, "denied by the policy")
+	r.Equal(operations.Terminated, true)
+
 	// test run
 	testCases := []struct {
 		name          string
@@ -115,10 +134,10 @@ func TestStepGroupStep(t *testing.T) {
 			name: "running2",
 			engine: &testEngine{
 				stepStatus: v1alpha1.WorkflowStepStatus{
-					SubStepsStatus: []v1alpha1.StepStatus{
-						{
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseRunning,
-						},
+						}},
 					},
 				},
 				operation: &types.Operation{},
@@ -129,13 +148,13 @@ func TestStepGroupStep(t *testing.T) {
 			name: "stop",
 			engine: &testEngine{
 				stepStatus: v1alpha1.WorkflowStepStatus{
-					SubStepsStatus: []v1alpha1.StepStatus{
-						{
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseStopped,
-						},
-						{
+						}},
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseFailed,
-						},
+						}},
 					},
 				},
 				operation: &types.Operation{},
@@ -146,13 +165,13 @@ func TestStepGroupStep(t *testing.T) {
 			name: "fail",
 			engine: &testEngine{
 				stepStatus: v1alpha1.WorkflowStepStatus{
-					SubStepsStatus: []v1alpha1.StepStatus{
-						{
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseFailed,
-						},
-						{
+						}},
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-						},
+						}},
 					},
 				},
 				operation: &types.Operation{},
@@ -163,10 +182,10 @@ func TestStepGroupStep(t *testing.T) {
 			name: "success",
 			engine: &testEngine{
 				stepStatus: v1alpha1.WorkflowStepStatus{
-					SubStepsStatus: []v1alpha1.StepStatus{
-						{
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-						},
+						}},
 					},
 				},
 				operation: &types.Operation{},
@@ -177,10 +196,10 @@ func TestStepGroupStep(t *testing.T) {
 			name: "operation",
 			engine: &testEngine{
 				stepStatus: v1alpha1.WorkflowStepStatus{
-					SubStepsStatus: []v1alpha1.StepStatus{
-						{
+					SubStepsStatus: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: v1alpha1.StepStatus{
 							Phase: v1alpha1.WorkflowStepPhaseSucceeded,
-						},
+						}},
 					},
 				},
 				operation: &types.Operation{
@@ -0,0 +1,363 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/cue/packages"
+	"github.com/kubevela/workflow/pkg/sharding"
+	"github.com/kubevela/workflow/pkg/tasks/custom"
+	"github.com/kubevela/workflow/pkg/types"
+	"github.com/kubevela/workflow/pkg/utils"
+)
+
+// SubWorkflowProperties is the properties of the sub-workflow step
+type SubWorkflowProperties struct {
+	// WorkflowRef is the name of the Workflow to run, it must be in the same namespace
+	WorkflowRef string `json:"workflowRef"`
+	// Context is the initial context of the child WorkflowRun, the inputs of the step are also filled in
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// NewSubWorkflowGenerator returns the generator of the sub-workflow step, the step creates a child
+// WorkflowRun from the referenced Workflow and mirrors its phase.
+func NewSubWorkflowGenerator(cli client.Client) types.TaskGenerator {
+	return func(step v1alpha1.WorkflowStep, opt *types.TaskGeneratorOptions) (types.TaskRunner, error) {
+		return &subWorkflowTaskRunner{
+			id:   opt.ID,
+			name: step.Name,
+			step: step,
+			cli:  cli,
+			pd:   opt.PackageDiscover,
+		}, nil
+	}
+}
+
+type subWorkflowTaskRunner struct {
+	id   string
+	name string
+	step v1alpha1.WorkflowStep
+	cli  client.Client
+	pd   *packages.PackageDiscover
+}
+
+// Name return sub-workflow step name.
+func (tr *subWorkflowTaskRunner) Name() string {
+	return tr.name
+}
+
+// Pending check task should be executed or not.
+func (tr *subWorkflowTaskRunner) Pending(ctx wfContext.Context, stepStatus map[string]v1alpha1.StepStatus) (bool, v1alpha1.StepStatus) {
+	return custom.CheckPending(ctx, tr.step, tr.id, stepStatus)
+}
+
+// Run creates the child WorkflowRun if not exists and mirrors its phase.
+func (tr *subWorkflowTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOptions) (v1alpha1.StepStatus, *types.Operation, error) {
+	status := v1alpha1.StepStatus{
+		ID:      tr.id,
+		Name:    tr.name,
+		Type:    types.WorkflowStepTypeSubWorkflow,
+		Phase:   v1alpha1.WorkflowStepPhaseRunning,
+		Message: "",
+	}
+	operations := &types.Operation{}
+	for _, hook := range options.PreCheckHooks {
+		result, err := hook(tr.step, &types.PreCheckOptions{
+			PackageDiscover: tr.pd,
+			ProcessContext:  options.PCtx,
+		})
+		if err != nil {
+			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
+			status.Reason = types.StatusReasonSkip
+			status.Message = fmt.Sprintf("pre check error: %s", err.Error())
+			continue
+		}
+		if result.Fail {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = result.GetFailedReason()
+			status.Message = result.Message
+			break
+		}
+		if result.Skip {
+			status.Phase = v1alpha1.WorkflowStepPhaseSkipped
+			status.Reason = types.StatusReasonSkip
+			options.StepStatus[tr.step.Name] = status
+			break
+		}
+		if result.Timeout {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			status.Reason = types.StatusReasonTimeout
+			options.StepStatus[tr.step.Name] = status
+		}
+	}
+	if status.Phase == v1alpha1.WorkflowStepPhaseSkipped {
+		return status, &types.Operation{Skip: true}, nil
+	}
+	if status.Phase == v1alpha1.WorkflowStepPhaseFailed {
+		// the child workflow run is terminated with the timed out or failed step
+		if err := tr.terminateChild(ctx); err != nil {
+			return status, nil, err
+		}
+		operations.Terminated = true
+		return status, operations, nil
+	}
+
+	child, err := tr.getOrCreateChild(ctx)
+	if err != nil {
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonParameter
+		status.Message = err.Error()
+		operations.Terminated = true
+		return status, operations, nil
+	}
+
+	if child.DeletionTimestamp != nil {
+		// the child of the previous execution is deleted when the step is restarted
+		status.Message = fmt.Sprintf("waiting for the previous sub workflow run %s to be deleted", child.Name)
+		operations.Waiting = true
+		return status, operations, nil
+	}
+
+	switch child.Status.Phase {
+	case v1alpha1.WorkflowStateSucceeded:
+		status.Phase = v1alpha1.WorkflowStepPhaseSucceeded
+	case v1alpha1.WorkflowStateTerminated:
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonTerminate
+		status.Message = fmt.Sprintf("sub workflow run %s is terminated", child.Name)
+		operations.Terminated = true
+	case v1alpha1.WorkflowStateFailed:
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonSubWorkflow
+		status.Message = fmt.Sprintf("sub workflow run %s is failed: %s", child.Name, child.Status.Message)
+		operations.Terminated = true
+	default:
+		status.Message = fmt.Sprintf("waiting for sub workflow run %s", child.Name)
+		operations.Waiting = true
+		return status, operations, nil
+	}
+
+	tr.handleOutput(ctx, child, &status, operations, options.PostStopHooks)
+	return status, operations, nil
+}
+
+func (tr *subWorkflowTaskRunner) getParent(ctx wfContext.Context) (*v1alpha1.WorkflowRun, error) {
+	store := ctx.GetStore()
+	name := utils.GetParentWorkflowRunName(store)
+	if name == "" {
+		return nil, errors.New("failed to find the parent workflow run of the sub workflow")
+	}
+	parent := &v1alpha1.WorkflowRun{}
+	if err := tr.cli.Get(context.Background(), client.ObjectKey{Namespace: store.Namespace, Name: name}, parent); err != nil {
+		return nil, errors.WithMessagef(err, "get parent workflow run %s", name)
+	}
+	return parent, nil
+}
+
+func (tr *subWorkflowTaskRunner) getChild(parent *v1alpha1.WorkflowRun) (*v1alpha1.WorkflowRun, error) {
+	child := &v1alpha1.WorkflowRun{}
+	name := utils.GenerateSubWorkflowRunName(parent.Name, tr.name)
+	if err := tr.cli.Get(context.Background(), client.ObjectKey{Namespace: parent.Namespace, Name: name}, child); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.WithMessagef(err, "get sub workflow run %s", name)
+	}
+	if !utils.IsSubWorkflowOf(child, parent) {
+		return nil, errors.Errorf("workflow run %s already exists and is not created by workflow run %s", name, parent.Name)
+	}
+	return child, nil
+}
+
+// terminateChild terminates the child workflow run if it's running, e.g. the step is timed out
+func (tr *subWorkflowTaskRunner) terminateChild(ctx wfContext.Context) error {
+	parent, err := tr.getParent(ctx)
+	if err != nil {
+		return err
+	}
+	child, err := tr.getChild(parent)
+	if err != nil || child == nil || child.Status.Finished || child.Status.Terminated {
+		return err
+	}
+	return utils.TerminateWorkflow(context.Background(), tr.cli, child)
+}
+
+func (tr *subWorkflowTaskRunner) getOrCreateChild(ctx wfContext.Context) (*v1alpha1.WorkflowRun, error) {
+	parent, err := tr.getParent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if child, err := tr.getChild(parent); err != nil || child != nil {
+		return child, err
+	}
+
+	props, err := tr.getProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.checkAncestors(parent, props.WorkflowRef); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(props.Context)
+	if err != nil {
+		return nil, err
+	}
+	child := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            utils.GenerateSubWorkflowRunName(parent.Name, tr.name),
+			Namespace:       parent.Namespace,
+			OwnerReferences: ctx.GetStore().OwnerReferences,
+			Labels: map[string]string{
+				types.LabelParentWorkflowRunUID: string(parent.UID),
+			},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowRef: props.WorkflowRef,
+			Context:     &runtime.RawExtension{Raw: raw},
+		},
+	}
+	// the child of the dry run parent is dry run as well, so that it doesn't change the cluster either
+	if parent.Spec.DryRun || parent.Annotations[types.AnnotationWorkflowRunDryRun] == "true" {
+		child.Spec.DryRun = true
+		child.Annotations = map[string]string{types.AnnotationWorkflowRunDryRun: "true"}
+	}
+	// the child is assigned to the shard of the parent, so that it's in the cache of the replica
+	if shard := parent.Labels[sharding.LabelShardID]; shard != "" {
+		child.Labels[sharding.LabelShardID] = shard
+	}
+	if err := tr.cli.Create(context.Background(), child); err != nil {
+		return nil, errors.WithMessagef(err, "create sub workflow run %s", child.Name)
+	}
+	return child, nil
+}
+
+// checkAncestors rejects the workflow referenced by the parent or its ancestors and caps the nesting depth of the
+// sub workflows, so that they don't create the child workflow runs endlessly.
+func (tr *subWorkflowTaskRunner) checkAncestors(parent *v1alpha1.WorkflowRun, workflowRef string) error {
+	run := parent
+	for depth := 1; ; depth++ {
+		if run.Spec.WorkflowRef == workflowRef {
+			return errors.Errorf("workflow %s is referenced by its sub workflow recursively", workflowRef)
+		}
+		if depth > types.MaxSubWorkflowDepth {
+			return errors.Errorf("the sub workflows are nested deeper than %d", types.MaxSubWorkflowDepth)
+		}
+		name := utils.GetParentWorkflowRunName(run)
+		if name == "" {
+			return nil
+		}
+		ancestor := &v1alpha1.WorkflowRun{}
+		if err := tr.cli.Get(context.Background(), client.ObjectKey{Namespace: run.Namespace, Name: name}, ancestor); err != nil {
+			if kerrors.IsNotFound(err) {
+				return nil
+			}
+			return errors.WithMessagef(err, "get ancestor workflow run %s", name)
+		}
+		run = ancestor
+	}
+}
+
+func (tr *subWorkflowTaskRunner) getProperties(ctx wfContext.Context) (*SubWorkflowProperties, error) {
+	props := &SubWorkflowProperties{}
+	if tr.step.Properties != nil && len(tr.step.Properties.Raw) > 0 {
+		if err := json.Unmarshal(tr.step.Properties.Raw, props); err != nil {
+			return nil, errors.WithMessage(err, "invalid sub workflow properties")
+		}
+	}
+	if props.WorkflowRef == "" {
+		return nil, errors.New("the workflowRef of the sub workflow step is required")
+	}
+	if props.Context == nil {
+		props.Context = map[string]interface{}{}
+	}
+	for _, input := range tr.step.Inputs {
+		// the secrets are not passed to the context of the child workflow run
+		if input.FromSecret != nil {
+			continue
+		}
+		v, err := ctx.GetVar(strings.Split(input.From, ".")...)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "get input from [%s]", input.From)
+		}
+		var data interface{}
+		if err := v.CueValue().Decode(&data); err != nil {
+			return nil, errors.WithMessagef(err, "decode input from [%s]", input.From)
+		}
+		setNestedValue(props.Context, strings.Split(input.ParameterKey, "."), data)
+	}
+	return props, nil
+}
+
+// handleOutput resolves the outputs of the step against the context of the child workflow run.
+func (tr *subWorkflowTaskRunner) handleOutput(ctx wfContext.Context, child *v1alpha1.WorkflowRun, status *v1alpha1.StepStatus, operations *types.Operation, postStopHooks []types.TaskPostStopHook) {
+	if len(tr.step.Outputs) == 0 {
+		return
+	}
+	childCtx, err := wfContext.LoadContext(tr.cli, child.Namespace, child.Name)
+	var childValue *value.Value
+	if err == nil {
+		childValue, err = childCtx.GetVar()
+	}
+	if err != nil {
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		if status.Reason == "" {
+			status.Reason = types.StatusReasonOutput
+		}
+		operations.Terminated = true
+		status.Message = fmt.Sprintf("load sub workflow context error: %s", err.Error())
+		return
+	}
+	for _, hook := range postStopHooks {
+		if err := hook(ctx, childValue, tr.step, *status, nil); err != nil {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			if status.Reason == "" {
+				status.Reason = types.StatusReasonOutput
+			}
+			operations.Terminated = true
+			status.Message = fmt.Sprintf("output error: %s", err.Error())
+			return
+		}
+	}
+}
+
+func setNestedValue(m map[string]interface{}, paths []string, v interface{}) {
+	for i, p := range paths {
+		if i == len(paths)-1 {
+			m[p] = v
+			return
+		}
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+}
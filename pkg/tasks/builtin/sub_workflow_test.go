@@ -0,0 +1,307 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/hooks"
+	"github.com/kubevela/workflow/pkg/sharding"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+func TestSubWorkflowStep(t *testing.T) {
+	r := require.New(t)
+	parentCli := &test.MockClient{
+		MockCreate: func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			return nil
+		},
+		MockUpdate: func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return nil
+		},
+		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			return nil
+		},
+	}
+	ctx, err := wfContext.NewContext(parentCli, "default", "parent", []metav1.OwnerReference{
+		{Kind: v1alpha1.WorkflowRunKind, Name: "parent", UID: "parent-uid", Controller: pointer.Bool(true)},
+	})
+	r.NoError(err)
+	v, err := value.NewValue(`"bar"`, nil, "")
+	r.NoError(err)
+	r.NoError(ctx.SetVar(v, "foo"))
+
+	var created *v1alpha1.WorkflowRun
+	cli := &test.MockClient{
+		MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+			created = obj.(*v1alpha1.WorkflowRun)
+			return nil
+		},
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *v1alpha1.WorkflowRun:
+				if key.Name == "parent" {
+					o.Name = key.Name
+					o.UID = "parent-uid"
+					o.Labels = map[string]string{sharding.LabelShardID: "shard-1"}
+					return nil
+				}
+				if created == nil || created.Name != key.Name {
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				}
+				created.DeepCopyInto(o)
+			case *corev1.ConfigMap:
+				o.Name = key.Name
+				o.Namespace = key.Namespace
+				o.Data = map[string]string{wfContext.ConfigMapKeyVars: `{"result": "ok"}`}
+			}
+			return nil
+		},
+	}
+
+	gen := NewSubWorkflowGenerator(cli)
+	runner, err := gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "sub",
+			Type:       types.WorkflowStepTypeSubWorkflow,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"workflowRef": "child", "context": {"env": "test"}}`)},
+			Inputs:     v1alpha1.StepInputs{{From: "foo", ParameterKey: "params.foo"}},
+			Outputs:    v1alpha1.StepOutputs{{Name: "out", ValueFrom: "result"}},
+		},
+	}, &types.TaskGeneratorOptions{ID: "126"})
+	r.NoError(err)
+	r.Equal(runner.Name(), "sub")
+
+	options := &types.TaskRunOptions{
+		StepStatus:    map[string]v1alpha1.StepStatus{},
+		PostStopHooks: []types.TaskPostStopHook{hooks.Output},
+	}
+	status, operations, err := runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseRunning)
+	r.Equal(operations.Waiting, true)
+	r.NotNil(created)
+	r.Equal(created.Name, "parent-sub")
+	r.Equal(created.Spec.WorkflowRef, "child")
+	r.Equal(created.Labels[types.LabelParentWorkflowRunUID], "parent-uid")
+	r.Equal(created.Labels[sharding.LabelShardID], "shard-1")
+	r.Equal(string(created.Spec.Context.Raw), `{"env":"test","params":{"foo":"bar"}}`)
+
+	created.Status.Phase = v1alpha1.WorkflowStateSucceeded
+	status, _, err = runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseSucceeded)
+	out, err := ctx.GetVar("out")
+	r.NoError(err)
+	s, err := out.String()
+	r.NoError(err)
+	r.Equal(s, `"ok"
+`)
+
+	created.Status.Phase = v1alpha1.WorkflowStateFailed
+	status, operations, err = runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, types.StatusReasonSubWorkflow)
+	r.Equal(operations.Terminated, true)
+
+	// test missing workflowRef
+	runner, err = gen(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "invalid",
+			Type: types.WorkflowStepTypeSubWorkflow,
+		},
+	}, &types.TaskGeneratorOptions{ID: "127"})
+	r.NoError(err)
+	status, operations, err = runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, types.StatusReasonParameter)
+	r.Equal(operations.Terminated, true)
+}
+
+func TestSubWorkflowAncestors(t *testing.T) {
+	r := require.New(t)
+	s := runtime.NewScheme()
+	r.NoError(scheme.AddToScheme(s))
+	r.NoError(v1alpha1.AddToScheme(s))
+	owner := func(name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind, Name: name, UID: k8stypes.UID(name + "-uid"), Controller: pointer.Bool(true)}}
+	}
+	root := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: "default", UID: "root-uid"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "a"},
+	}
+	child := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "root-sub", Namespace: "default", UID: "root-sub-uid", OwnerReferences: owner("root")},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "b"},
+	}
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(root, child).Build()
+	ctx, err := wfContext.NewContext(cli, "default", "root-sub", owner("root-sub"))
+	r.NoError(err)
+	run := func(workflowRef string) v1alpha1.StepStatus {
+		runner, err := NewSubWorkflowGenerator(cli)(v1alpha1.WorkflowStep{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name:       "sub-" + workflowRef,
+				Type:       types.WorkflowStepTypeSubWorkflow,
+				Properties: &runtime.RawExtension{Raw: []byte(`{"workflowRef": "` + workflowRef + `"}`)},
+			},
+		}, &types.TaskGeneratorOptions{ID: workflowRef})
+		r.NoError(err)
+		status, _, err := runner.Run(ctx, &types.TaskRunOptions{StepStatus: map[string]v1alpha1.StepStatus{}})
+		r.NoError(err)
+		return status
+	}
+
+	// the workflow referenced by the ancestors is rejected
+	status := run("a")
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Contains(status.Message, "referenced by its sub workflow recursively")
+
+	defer func(depth int) { types.MaxSubWorkflowDepth = depth }(types.MaxSubWorkflowDepth)
+	types.MaxSubWorkflowDepth = 1
+	status = run("c")
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Contains(status.Message, "nested deeper than 1")
+
+	types.MaxSubWorkflowDepth = 2
+	status = run("c")
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, status.Phase)
+	created := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "root-sub-sub-c"}, created))
+	r.Equal("root-sub-uid", created.Labels[types.LabelParentWorkflowRunUID])
+
+	// the child is terminated with the timed out step
+	runner, err := NewSubWorkflowGenerator(cli)(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "sub-c",
+			Type:       types.WorkflowStepTypeSubWorkflow,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"workflowRef": "c"}`)},
+		},
+	}, &types.TaskGeneratorOptions{ID: "c"})
+	r.NoError(err)
+	status, operations, err := runner.Run(ctx, &types.TaskRunOptions{
+		StepStatus: map[string]v1alpha1.StepStatus{},
+		PreCheckHooks: []types.TaskPreCheckHook{func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+			return &types.PreCheckResult{Timeout: true}, nil
+		}},
+	})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.True(operations.Terminated)
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "root-sub-sub-c"}, created))
+	r.True(created.Status.Terminated)
+}
+
+func TestSubWorkflowDryRun(t *testing.T) {
+	r := require.New(t)
+	s := runtime.NewScheme()
+	r.NoError(scheme.AddToScheme(s))
+	r.NoError(v1alpha1.AddToScheme(s))
+	parent := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "parent",
+			Namespace:   "default",
+			UID:         "parent-uid",
+			Annotations: map[string]string{types.AnnotationWorkflowRunDryRun: "true"},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{WorkflowRef: "a"},
+	}
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(parent).Build()
+	ctx, err := wfContext.NewContext(cli, "default", "parent", []metav1.OwnerReference{
+		{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind, Name: "parent", UID: "parent-uid", Controller: pointer.Bool(true)},
+	})
+	r.NoError(err)
+	runner, err := NewSubWorkflowGenerator(cli)(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "sub",
+			Type:       types.WorkflowStepTypeSubWorkflow,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"workflowRef": "b"}`)},
+		},
+	}, &types.TaskGeneratorOptions{ID: "sub"})
+	r.NoError(err)
+	status, _, err := runner.Run(ctx, &types.TaskRunOptions{StepStatus: map[string]v1alpha1.StepStatus{}})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, status.Phase)
+
+	// the child of the dry run parent doesn't change the cluster either
+	child := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "parent-sub"}, child))
+	r.True(child.Spec.DryRun)
+	r.Equal("true", child.Annotations[types.AnnotationWorkflowRunDryRun])
+}
+
+func TestSubWorkflowNameCollision(t *testing.T) {
+	r := require.New(t)
+	s := runtime.NewScheme()
+	r.NoError(scheme.AddToScheme(s))
+	r.NoError(v1alpha1.AddToScheme(s))
+	parent := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "a-b", Namespace: "default", UID: "a-b-uid"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "a"},
+	}
+	// the child of the step "b-c" of the workflow run "a" has the same name as the child of the step "c" of "a-b"
+	other := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "a-b-c",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind, Name: "a", UID: "a-uid", Controller: pointer.Bool(true)},
+			},
+		},
+		Spec:   v1alpha1.WorkflowRunSpec{WorkflowRef: "b"},
+		Status: v1alpha1.WorkflowRunStatus{Phase: v1alpha1.WorkflowStateSucceeded},
+	}
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(parent, other).Build()
+	ctx, err := wfContext.NewContext(cli, "default", "a-b", []metav1.OwnerReference{
+		{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind, Name: "a-b", UID: "a-b-uid", Controller: pointer.Bool(true)},
+	})
+	r.NoError(err)
+	runner, err := NewSubWorkflowGenerator(cli)(v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "c",
+			Type:       types.WorkflowStepTypeSubWorkflow,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"workflowRef": "b"}`)},
+		},
+	}, &types.TaskGeneratorOptions{ID: "c"})
+	r.NoError(err)
+
+	// the workflow run of the other parent is not mirrored
+	status, operations, err := runner.Run(ctx, &types.TaskRunOptions{StepStatus: map[string]v1alpha1.StepStatus{}})
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal(types.StatusReasonParameter, status.Reason)
+	r.Contains(status.Message, "is not created by workflow run a-b")
+	r.True(operations.Terminated)
+}
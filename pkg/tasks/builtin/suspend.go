@@ -27,6 +27,7 @@ import (
 
 	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/cue/packages"
 	"github.com/kubevela/workflow/pkg/cue/process"
 	"github.com/kubevela/workflow/pkg/tasks/custom"
@@ -68,7 +69,10 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 	operations = &types.Operation{Suspend: true}
 
 	status := &stepStatus
-	defer handleOutput(ctx, status, operations, tr.step, options.PostStopHooks, tr.pd, tr.id, tr.pCtx)
+	var resume *v1alpha1.StepResume
+	defer func() {
+		tr.handleOutput(ctx, status, operations, options.PostStopHooks, resume)
+	}()
 
 	for _, hook := range options.PreCheckHooks {
 		result, err := hook(tr.step, &types.PreCheckOptions{
@@ -84,6 +88,12 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 			continue
 		}
 		switch {
+		case result.Fail:
+			stepStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
+			stepStatus.Reason = result.GetFailedReason()
+			stepStatus.Message = result.Message
+			operations.Suspend = false
+			operations.Terminated = true
 		case result.Skip:
 			stepStatus.Phase = v1alpha1.WorkflowStepPhaseSkipped
 			stepStatus.Reason = types.StatusReasonSkip
@@ -100,8 +110,26 @@ func (tr *suspendTaskRunner) Run(ctx wfContext.Context, options *types.TaskRunOp
 		return stepStatus, operations, nil
 	}
 
+	resume, err := getResumeDecision(ctx, tr.id)
+	if err != nil {
+		return v1alpha1.StepStatus{}, nil, err
+	}
+	if resume != nil {
+		operations.Suspend = false
+		if resume.Reject {
+			stepStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
+			stepStatus.Reason = types.StatusReasonReject
+			stepStatus.Message = getResumeMessage("rejected", resume)
+			operations.Terminated = true
+			return stepStatus, operations, nil
+		}
+		stepStatus.Phase = v1alpha1.WorkflowStepPhaseSucceeded
+		stepStatus.Message = getResumeMessage("resumed", resume)
+		return stepStatus, operations, nil
+	}
+
 	for _, input := range tr.step.Inputs {
-		if input.ParameterKey == "duration" {
+		if input.ParameterKey == "duration" && input.FromSecret == nil {
 			inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
 			if err != nil {
 				return v1alpha1.StepStatus{}, nil, errors.WithMessagef(err, "do preStartHook: get input from [%s]", input.From)
@@ -159,6 +187,90 @@ func GetSuspendStepDurationWaiting(step v1alpha1.WorkflowStep) (time.Duration, e
 	return 0, nil
 }
 
+// getResumeDecision returns the pending resume decision of the suspend step and removes it from the context,
+// nil is returned if there's no decision.
+func getResumeDecision(ctx wfContext.Context, id string) (*v1alpha1.StepResume, error) {
+	data := ctx.GetMutableValue(types.ContextPrefixResume, id)
+	if data == "" {
+		return nil, nil
+	}
+	resume := &v1alpha1.StepResume{}
+	if err := json.Unmarshal([]byte(data), resume); err != nil {
+		return nil, errors.WithMessage(err, "invalid resume decision")
+	}
+	ctx.DeleteMutableValue(types.ContextPrefixResume, id)
+	return resume, nil
+}
+
+func getResumeMessage(action string, resume *v1alpha1.StepResume) string {
+	msg := action
+	if resume.Approver != "" {
+		msg += " by " + resume.Approver
+	}
+	if resume.Comment != "" {
+		msg += ": " + resume.Comment
+	}
+	return msg
+}
+
+// handleOutput resolves the outputs of the step, the approver, comment, data and rejected of the resume decision
+// can be referenced in the outputs.
+func (tr *suspendTaskRunner) handleOutput(ctx wfContext.Context, status *v1alpha1.StepStatus, operations *types.Operation, postStopHooks []types.TaskPostStopHook, resume *v1alpha1.StepResume) {
+	if resume == nil {
+		handleOutput(ctx, status, operations, tr.step, postStopHooks, tr.pd, tr.id, tr.pCtx)
+		return
+	}
+	if len(tr.step.Outputs) == 0 {
+		return
+	}
+	contextValue, err := custom.MakeValueForContext(ctx, tr.pd, tr.step.Name, tr.id, tr.pCtx)
+	if err == nil {
+		err = fillResumePayload(contextValue, resume)
+	}
+	if err != nil {
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		if status.Reason == "" {
+			status.Reason = types.StatusReasonOutput
+		}
+		operations.Terminated = true
+		status.Message = fmt.Sprintf("make context value error: %s", err.Error())
+		return
+	}
+	for _, hook := range postStopHooks {
+		if err := hook(ctx, contextValue, tr.step, *status, nil); err != nil {
+			status.Phase = v1alpha1.WorkflowStepPhaseFailed
+			if status.Reason == "" {
+				status.Reason = types.StatusReasonOutput
+			}
+			operations.Terminated = true
+			status.Message = fmt.Sprintf("output error: %s", err.Error())
+			return
+		}
+	}
+}
+
+func fillResumePayload(v *value.Value, resume *v1alpha1.StepResume) error {
+	payload := map[string]interface{}{
+		"approver": resume.Approver,
+		"comment":  resume.Comment,
+		"rejected": resume.Reject,
+	}
+	for k, d := range payload {
+		if err := v.FillObject(d, k); err != nil {
+			return err
+		}
+	}
+	if resume.Data == nil || len(resume.Data.Raw) == 0 {
+		return nil
+	}
+	// make the data from json directly to keep the integers in it
+	data, err := v.MakeValue("data: " + string(resume.Data.Raw))
+	if err != nil {
+		return errors.WithMessage(err, "invalid resume data")
+	}
+	return v.FillObject(data)
+}
+
 func handleOutput(ctx wfContext.Context, stepStatus *v1alpha1.StepStatus, operations *types.Operation, step v1alpha1.WorkflowStep, postStopHooks []types.TaskPostStopHook, pd *packages.PackageDiscover, id string, pCtx process.Context) {
 	if len(step.Outputs) > 0 {
 		contextValue, err := custom.MakeValueForContext(ctx, pd, step.Name, id, pCtx)
@@ -17,11 +17,16 @@ limitations under the License.
 package builtin
 
 import (
+	"context"
 	"testing"
 
+	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/hooks"
 	"github.com/kubevela/workflow/pkg/types"
 )
 
@@ -75,11 +80,93 @@ func TestSuspendStep(t *testing.T) {
 	r.Equal(operations.Suspend, false)
 	r.Equal(operations.Terminated, true)
 
+	// test pre check fail
+	status, operations, err = runner.Run(nil, &types.TaskRunOptions{
+		PreCheckHooks: []types.TaskPreCheckHook{
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return &types.PreCheckResult{Fail: true, Message: "denied by the policy"}, nil
+			},
+		},
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, types.StatusReasonPreCheck)
+	r.Equal(status.Message, "denied by the policy")
+	r.Equal(operations.Suspend, false)
+	r.Equal(operations.Terminated, true)
+
 	// test run
-	status, act, err := runner.Run(nil, &types.TaskRunOptions{})
+	ctx := newSuspendContext(t)
+	status, act, err := runner.Run(ctx, &types.TaskRunOptions{})
 	r.NoError(err)
 	r.Equal(act.Suspend, true)
 	r.Equal(status.ID, "124")
 	r.Equal(status.Name, "test")
 	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseRunning)
 }
+
+func TestSuspendStepResume(t *testing.T) {
+	r := require.New(t)
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "approve",
+			Type: "suspend",
+			Outputs: v1alpha1.StepOutputs{
+				{Name: "approver", ValueFrom: "approver"},
+				{Name: "comment", ValueFrom: "comment"},
+				{Name: "replicas", ValueFrom: "data.replicas"},
+			},
+		},
+	}
+	runner, err := Suspend(step, &types.TaskGeneratorOptions{ID: "124"})
+	r.NoError(err)
+	options := &types.TaskRunOptions{PostStopHooks: []types.TaskPostStopHook{hooks.Output}}
+
+	// test approve
+	ctx := newSuspendContext(t)
+	ctx.SetMutableValue(`{"name":"approve","approver":"admin","comment":"lgtm","data":{"replicas":3}}`, types.ContextPrefixResume, "124")
+	status, operations, err := runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+	r.Equal("resumed by admin: lgtm", status.Message)
+	r.Equal(false, operations.Suspend)
+	r.Equal("", ctx.GetMutableValue(types.ContextPrefixResume, "124"))
+	v, err := ctx.GetVar("approver")
+	r.NoError(err)
+	approver, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal("admin", approver)
+	v, err = ctx.GetVar("replicas")
+	r.NoError(err)
+	replicas, err := v.CueValue().Int64()
+	r.NoError(err)
+	r.Equal(int64(3), replicas)
+
+	// test reject
+	ctx = newSuspendContext(t)
+	ctx.SetMutableValue(`{"name":"124","approver":"admin","reject":true,"data":{"replicas":0}}`, types.ContextPrefixResume, "124")
+	status, operations, err = runner.Run(ctx, options)
+	r.NoError(err)
+	r.Equal(v1alpha1.WorkflowStepPhaseFailed, status.Phase)
+	r.Equal(types.StatusReasonReject, status.Reason)
+	r.Equal("rejected by admin", status.Message)
+	r.Equal(false, operations.Suspend)
+	r.Equal(true, operations.Terminated)
+}
+
+func newSuspendContext(t *testing.T) wfContext.Context {
+	cli := &test.MockClient{
+		MockCreate: func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			return nil
+		},
+		MockUpdate: func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return nil
+		},
+		MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			return nil
+		},
+	}
+	ctx, err := wfContext.NewContext(cli, "default", "test", nil)
+	require.NoError(t, err)
+	return ctx
+}
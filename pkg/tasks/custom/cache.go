@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+	"github.com/kubevela/workflow/pkg/cue/model/value"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// stepCacheKey hashes the type, the template and the rendered parameters of the step
+func stepCacheKey(step v1alpha1.WorkflowStep, templ, params string) string {
+	h := sha256.New()
+	for _, s := range []string{step.Type, templ, params} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayStepCache sets the cached outputs of the step in the context, it returns false if the result is not cached
+// or has expired.
+func replayStepCache(ctx context.Context, wfCtx wfContext.Context, cache types.StepCache, key string, step v1alpha1.WorkflowStep) (bool, error) {
+	entry, err := cache.Get(ctx, key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	if entry.ExpirationTime != nil && entry.ExpirationTime.Time.Before(time.Now()) {
+		return false, nil
+	}
+	outputs := make(map[string]*value.Value)
+	for _, output := range step.Outputs {
+		s, ok := entry.Outputs[output.Name]
+		if !ok {
+			// the outputs of the step are changed, the result can't be replayed
+			return false, nil
+		}
+		v, err := value.NewValue(s, nil, "")
+		if err != nil {
+			return false, errors.WithMessagef(err, "parse cached output %s", output.Name)
+		}
+		outputs[output.Name] = v
+	}
+	for name, v := range outputs {
+		if err := wfCtx.SetVar(v, name); err != nil {
+			return false, errors.WithMessagef(err, "set cached output %s", name)
+		}
+	}
+	return true, nil
+}
+
+// saveStepCache caches the outputs of the succeeded step which are already set in the context
+func saveStepCache(ctx context.Context, wfCtx wfContext.Context, cache types.StepCache, key string, step v1alpha1.WorkflowStep) error {
+	entry := &types.StepCacheEntry{Outputs: make(map[string]string)}
+	if step.Cache.TTL != "" {
+		ttl, err := time.ParseDuration(step.Cache.TTL)
+		if err != nil {
+			return errors.WithMessage(err, "parse cache ttl")
+		}
+		expiration := metav1.NewTime(time.Now().Add(ttl))
+		entry.ExpirationTime = &expiration
+	}
+	for _, output := range step.Outputs {
+		v, err := wfCtx.GetVar(output.Name)
+		if err != nil {
+			return errors.WithMessagef(err, "get output %s", output.Name)
+		}
+		s, err := v.String()
+		if err != nil {
+			return errors.WithMessagef(err, "encode output %s", output.Name)
+		}
+		entry.Outputs[output.Name] = s
+	}
+	return cache.Set(ctx, key, entry)
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"cuelang.org/go/cue"
 	"github.com/pkg/errors"
@@ -94,9 +95,11 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 		}
 
 		var err error
+		var stepCache types.StepCache
 
 		if genOpt != nil {
 			exec.wfStatus.ID = genOpt.ID
+			stepCache = genOpt.StepCache
 			if genOpt.StepConvertor != nil {
 				wfStep, err = genOpt.StepConvertor(wfStep)
 				if err != nil {
@@ -104,6 +107,7 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 				}
 			}
 		}
+		exec.retry = wfStep.Retry
 
 		params := map[string]interface{}{}
 
@@ -130,6 +134,7 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 			}
 			tracer := options.GetTracer(exec.wfStatus.ID, wfStep).AddTag("step_name", wfStep.Name, "step_type", wfStep.Type)
 			tracer.V(t.logLevel)
+			tracer.SetContext(types.SetStepNameInCtx(tracer.GetContext(), wfStep.Name))
 			defer func() {
 				tracer.Commit(string(exec.status().Phase))
 			}()
@@ -140,15 +145,20 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 
 			var taskv *value.Value
 			var err error
-			var paramFile string
+			var paramFile, cacheKey string
+			var cached bool
 
 			defer func() {
 				if r := recover(); r != nil {
-					exec.err(ctx, false, fmt.Errorf("invalid cue task for evaluation: %v", r), types.StatusReasonRendering)
+					exec.err(ctx, fmt.Errorf("invalid cue task for evaluation: %v", r), types.StatusReasonRendering)
 					stepStatus = exec.status()
 					operations = exec.operation()
 					return
 				}
+				// the outputs of the cached step are replayed from the cache
+				if cached {
+					return
+				}
 				if taskv == nil {
 					taskv, err = convertTemplate(ctx, t.pd, strings.Join([]string{templ, paramFile}, "\n"), wfStep.Name, exec.wfStatus.ID, options.PCtx)
 					if err != nil {
@@ -163,6 +173,11 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 						return
 					}
 				}
+				if op := exec.operation(); cacheKey != "" && exec.status().Phase == v1alpha1.WorkflowStepPhaseSucceeded && !op.Waiting && !op.Suspend && !op.Terminated {
+					if err := saveStepCache(tracer, ctx, stepCache, cacheKey, wfStep); err != nil {
+						tracer.Error(err, "save step cache")
+					}
+				}
 			}()
 
 			for _, hook := range options.PreCheckHooks {
@@ -175,6 +190,10 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 					exec.Skip(fmt.Sprintf("pre check error: %s", err.Error()))
 					return exec.status(), exec.operation(), nil
 				}
+				if result.Fail {
+					exec.preCheckFailed(result.GetFailedReason(), result.Message)
+					return exec.status(), exec.operation(), nil
+				}
 				if result.Skip {
 					exec.Skip("")
 					return exec.status(), exec.operation(), nil
@@ -198,10 +217,37 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 			}
 
 			if err := paramsValue.Error(); err != nil {
-				exec.err(ctx, false, err, types.StatusReasonParameter)
+				exec.err(ctx, err, types.StatusReasonParameter)
 				return exec.status(), exec.operation(), nil
 			}
 
+			// the step is paused at the breakpoint before it runs unless it has timed out
+			if options.Breakpoint != nil && !exec.terminated {
+				snapshot := func() (*value.Value, error) {
+					return makeSnapshot(ctx, t.pd, wfStep, exec.wfStatus.ID, paramsValue, options.PCtx)
+				}
+				paused, decision, err := options.Breakpoint(ctx, wfStep, exec.wfStatus.ID, snapshot)
+				if err != nil {
+					tracer.Error(err, "check breakpoint")
+					return v1alpha1.StepStatus{}, nil, errors.WithMessage(err, "check breakpoint")
+				}
+				if paused {
+					exec.pause("paused at the breakpoint")
+					return exec.status(), exec.operation(), nil
+				}
+				if decision != nil && decision.Skip {
+					exec.Skip("skipped at the breakpoint")
+					return exec.status(), exec.operation(), nil
+				}
+				if decision != nil && decision.Parameter != nil && len(decision.Parameter.Raw) > 0 {
+					paramsValue, err = overrideParameter(ctx, paramsValue, decision.Parameter.Raw)
+					if err != nil {
+						exec.err(ctx, errors.WithMessage(err, "override parameter"), types.StatusReasonParameter)
+						return exec.status(), exec.operation(), nil
+					}
+				}
+			}
+
 			paramFile = model.ParameterFieldName + ": {}\n"
 			if params != nil {
 				ps, err := paramsValue.String()
@@ -211,16 +257,28 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 				paramFile = fmt.Sprintf(model.ParameterFieldName+": {%s}\n", ps)
 			}
 
+			if stepCache != nil && wfStep.Cache != nil {
+				cacheKey = stepCacheKey(wfStep, templ, paramFile)
+				cached, err = replayStepCache(tracer, ctx, stepCache, cacheKey, wfStep)
+				if err != nil {
+					tracer.Error(err, "replay step cache")
+				}
+				if cached {
+					exec.cached("the result is replayed from the cache")
+					return exec.status(), exec.operation(), nil
+				}
+			}
+
 			taskv, err = convertTemplate(ctx, t.pd, strings.Join([]string{templ, paramFile}, "\n"), wfStep.Name, exec.wfStatus.ID, options.PCtx)
 			if err != nil {
-				exec.err(ctx, false, err, types.StatusReasonRendering)
+				exec.err(ctx, err, types.StatusReasonRendering)
 				return exec.status(), exec.operation(), nil
 			}
 
 			exec.tracer = tracer
 			if debugLog(taskv) {
-				exec.printStep("workflowStepStart", "workflow", "", taskv)
-				defer exec.printStep("workflowStepEnd", "workflow", "", taskv)
+				exec.printStep(ctx, "workflowStepStart", "workflow", "", taskv)
+				defer exec.printStep(ctx, "workflowStepEnd", "workflow", "", taskv)
 			}
 			if options.Debug != nil {
 				defer func() {
@@ -231,7 +289,7 @@ func (t *TaskLoader) makeTaskGenerator(templ string) (types.TaskGenerator, error
 			}
 			if err := exec.doSteps(tracer, ctx, taskv); err != nil {
 				tracer.Error(err, "do steps")
-				exec.err(ctx, true, err, types.StatusReasonExecute)
+				exec.err(ctx, err, types.StatusReasonExecute)
 				return exec.status(), exec.operation(), nil
 			}
 
@@ -315,6 +373,8 @@ func MakeValueForContext(ctx wfContext.Context, pd *packages.PackageDiscover, st
 	return value.NewValue(contextTempl, pd, "")
 }
 
+var processContextLock sync.Mutex
+
 func getContextTemplate(ctx wfContext.Context, step, id string, pCtx process.Context) string {
 	var contextTempl string
 	meta, _ := ctx.GetVar(types.ContextKeyMetadata)
@@ -328,6 +388,9 @@ func getContextTemplate(ctx wfContext.Context, step, id string, pCtx process.Con
 	if pCtx == nil {
 		return ""
 	}
+	// the process context is shared by the steps running concurrently
+	processContextLock.Lock()
+	defer processContextLock.Unlock()
 	pCtx.PushData(model.ContextStepSessionID, id)
 	pCtx.PushData(model.ContextStepName, step)
 	c, err := pCtx.ExtendedContextFile()
@@ -338,9 +401,52 @@ func getContextTemplate(ctx wfContext.Context, step, id string, pCtx process.Con
 	return contextTempl
 }
 
+// makeSnapshot makes the snapshot of the step paused at the breakpoint, which contains the parameter with the inputs
+// filled, the context and the inputs of the step
+func makeSnapshot(ctx wfContext.Context, pd *packages.PackageDiscover, step v1alpha1.WorkflowStep, id string, paramsValue *value.Value, pCtx process.Context) (*value.Value, error) {
+	ps, err := paramsValue.String()
+	if err != nil {
+		return nil, errors.WithMessage(err, "params encode")
+	}
+	templ := fmt.Sprintf(model.ParameterFieldName+": {%s}\n", ps)
+	templ += getContextTemplate(ctx, step.Name, id, pCtx)
+	templ += getInputsTemplate(ctx, step)
+	return value.NewValue(templ, pd, "")
+}
+
+// overrideParameter overrides the values in the parameter with the ones in the json object, the objects are merged
+// recursively and the other values are replaced
+func overrideParameter(ctx wfContext.Context, paramsValue *value.Value, override []byte) (*value.Value, error) {
+	params := map[string]interface{}{}
+	if err := paramsValue.UnmarshalTo(&params); err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(override, &values); err != nil {
+		return nil, err
+	}
+	return ctx.MakeParameter(mergeValues(params, values))
+}
+
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		dstMap, isMap := dst[k].(map[string]interface{})
+		if ok && isMap {
+			dst[k] = mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
 func getInputsTemplate(ctx wfContext.Context, step v1alpha1.WorkflowStep) string {
 	var inputsTempl string
 	for _, input := range step.Inputs {
+		if input.FromSecret != nil {
+			continue
+		}
 		inputValue, err := ctx.GetVar(strings.Split(input.From, ".")...)
 		if err != nil {
 			continue
@@ -356,6 +462,7 @@ func getInputsTemplate(ctx wfContext.Context, step v1alpha1.WorkflowStep) string
 
 type executor struct {
 	handlers types.Providers
+	retry    *v1alpha1.RetryPolicy
 
 	wfStatus           v1alpha1.StepStatus
 	suspend            bool
@@ -408,6 +515,26 @@ func (exec *executor) Skip(message string) {
 	exec.wfStatus.Message = message
 }
 
+func (exec *executor) pause(message string) {
+	exec.wait = true
+	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseRunning
+	exec.wfStatus.Reason = types.StatusReasonBreakpoint
+	exec.wfStatus.Message = message
+}
+
+func (exec *executor) cached(message string) {
+	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseSucceeded
+	exec.wfStatus.Reason = types.StatusReasonCached
+	exec.wfStatus.Message = message
+}
+
+func (exec *executor) preCheckFailed(reason, message string) {
+	exec.terminated = true
+	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
+	exec.wfStatus.Reason = reason
+	exec.wfStatus.Message = message
+}
+
 func (exec *executor) timeout(message string) {
 	exec.terminated = true
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
@@ -415,26 +542,28 @@ func (exec *executor) timeout(message string) {
 	exec.wfStatus.Message = message
 }
 
-func (exec *executor) err(ctx wfContext.Context, wait bool, err error, reason string) {
-	exec.wait = wait
+func (exec *executor) err(ctx wfContext.Context, err error, reason string) {
 	exec.wfStatus.Phase = v1alpha1.WorkflowStepPhaseFailed
 	exec.wfStatus.Message = err.Error()
 	if exec.wfStatus.Reason == "" {
 		exec.wfStatus.Reason = reason
-		if reason != types.StatusReasonExecute {
-			exec.terminated = true
-		}
 	}
-	exec.checkErrorTimes(ctx)
-}
-
-func (exec *executor) checkErrorTimes(ctx wfContext.Context) {
-	times := ctx.IncreaseCountValueInMemory(types.ContextPrefixFailedTimes, exec.wfStatus.ID)
-	if times >= types.MaxWorkflowStepErrorRetryTimes {
-		exec.wait = false
+	retryable := types.IsRetryableReason(exec.retry, reason)
+	// the counter starts from 0 at the first failure
+	attempts := ctx.IncreaseCountValueInMemory(types.ContextPrefixFailedTimes, exec.wfStatus.ID) + 1
+	// the retryable failure is retried later with its reason kept
+	exec.wait = retryable && attempts < types.GetRetryLimit(exec.retry)
+	exec.wfStatus.Retrying = exec.wait
+	if exec.wait {
+		return
+	}
+	// the retries are used up, or the execute failure is not retryable in the retry policy
+	if retryable || reason == types.StatusReasonExecute {
 		exec.failedAfterRetries = true
 		exec.wfStatus.Reason = types.StatusReasonFailedAfterRetries
+		return
 	}
+	exec.terminated = true
 }
 
 func (exec *executor) operation() *types.Operation {
@@ -451,16 +580,16 @@ func (exec *executor) status() v1alpha1.StepStatus {
 	return exec.wfStatus
 }
 
-func (exec *executor) printStep(phase string, provider string, do string, v *value.Value) {
+func (exec *executor) printStep(wfCtx wfContext.Context, phase string, provider string, do string, v *value.Value) {
 	msg, _ := v.String()
-	exec.tracer.Info("cue eval: "+msg, "phase", phase, "provider", provider, "do", do)
+	exec.tracer.Info("cue eval: "+wfContext.Redact(wfCtx, msg), "phase", phase, "provider", provider, "do", do)
 }
 
 // Handle process task-step value by provider and do.
 func (exec *executor) Handle(ctx monitorContext.Context, wfCtx wfContext.Context, provider string, do string, v *value.Value) error {
 	if debugLog(v) {
-		exec.printStep("stepStart", provider, do, v)
-		defer exec.printStep("stepEnd", provider, do, v)
+		exec.printStep(wfCtx, "stepStart", provider, do, v)
+		defer exec.printStep(wfCtx, "stepEnd", provider, do, v)
 	}
 	h, exist := exec.handlers.GetHandler(provider, do)
 	if !exist {
@@ -590,7 +719,7 @@ func CheckPending(ctx wfContext.Context, step v1alpha1.WorkflowStep, id string,
 	for _, depend := range step.DependsOn {
 		pStatus.Message = fmt.Sprintf("Pending on DependsOn: %s", depend)
 		if status, ok := stepStatus[depend]; ok {
-			if !types.IsStepFinish(status.Phase, status.Reason) {
+			if !types.IsStepStatusFinish(status) {
 				return true, pStatus
 			}
 		} else {
@@ -598,6 +727,9 @@ func CheckPending(ctx wfContext.Context, step v1alpha1.WorkflowStep, id string,
 		}
 	}
 	for _, input := range step.Inputs {
+		if input.FromSecret != nil {
+			continue
+		}
 		pStatus.Message = fmt.Sprintf("Pending on Input: %s", input.From)
 		if _, err := ctx.GetVar(strings.Split(input.From, ".")...); err != nil {
 			return true, pStatus
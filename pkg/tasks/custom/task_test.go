@@ -20,13 +20,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
@@ -254,6 +258,40 @@ close({
 				Type: "error",
 			},
 		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "retry-limit",
+				Type: "error",
+				Retry: &v1alpha1.RetryPolicy{
+					Limit: pointer.IntPtr(2),
+				},
+			},
+		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "not-retry-on-execute",
+				Type: "error",
+				Retry: &v1alpha1.RetryPolicy{
+					RetryOn: []string{types.StatusReasonRendering},
+				},
+			},
+		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "retry-on-rendering",
+				Type: "renderingError",
+				Retry: &v1alpha1.RetryPolicy{
+					Limit:   pointer.IntPtr(2),
+					RetryOn: []string{types.StatusReasonRendering},
+				},
+			},
+		},
+		{
+			WorkflowStepBase: v1alpha1.WorkflowStepBase{
+				Name: "not-retry-on-rendering",
+				Type: "renderingError",
+			},
+		},
 	}
 	for _, step := range steps {
 		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
@@ -286,6 +324,50 @@ close({
 			r.Equal(operation.FailedAfterRetries, true)
 			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
 			r.Equal(status.Reason, types.StatusReasonFailedAfterRetries)
+		case "retry-limit":
+			wfContext.CleanupMemoryStore("app-v1", "default")
+			newCtx := newWorkflowContextForTest(t)
+			status, operation, err = run.Run(newCtx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(operation.Waiting, true)
+			r.Equal(operation.FailedAfterRetries, false)
+			status, operation, err = run.Run(newCtx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(operation.Waiting, false)
+			r.Equal(operation.FailedAfterRetries, true)
+			r.Equal(status.Reason, types.StatusReasonFailedAfterRetries)
+		case "retry-on-rendering":
+			wfContext.CleanupMemoryStore("app-v1", "default")
+			newCtx := newWorkflowContextForTest(t)
+			run, err = gen(step, &types.TaskGeneratorOptions{})
+			r.NoError(err)
+			status, operation, err = run.Run(newCtx, &types.TaskRunOptions{})
+			// the reason of the retryable failure is kept
+			r.NoError(err)
+			r.Equal(operation.Waiting, true)
+			r.Equal(operation.Terminated, false)
+			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+			r.Equal(status.Reason, types.StatusReasonRendering)
+			r.True(status.Retrying)
+			r.False(types.IsStepStatusFinish(status))
+			status, operation, err = run.Run(newCtx, &types.TaskRunOptions{})
+			r.NoError(err)
+			r.Equal(operation.Waiting, false)
+			r.Equal(operation.FailedAfterRetries, true)
+			r.Equal(status.Reason, types.StatusReasonFailedAfterRetries)
+			r.False(status.Retrying)
+		case "not-retry-on-rendering":
+			r.NoError(err)
+			r.Equal(operation.Waiting, false)
+			r.Equal(operation.Terminated, true)
+			r.Equal(status.Reason, types.StatusReasonRendering)
+			r.True(types.IsStepStatusFinish(status))
+		case "not-retry-on-execute":
+			r.NoError(err)
+			r.Equal(operation.Waiting, false)
+			r.Equal(operation.FailedAfterRetries, true)
+			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+			r.Equal(status.Reason, types.StatusReasonFailedAfterRetries)
 		default:
 			r.Equal(operation.Waiting, true)
 			r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
@@ -575,6 +657,221 @@ func TestTimeout(t *testing.T) {
 	r.Equal(status.Reason, types.StatusReasonTimeout)
 }
 
+func TestPreCheckFail(t *testing.T) {
+	r := require.New(t)
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			return nil
+		},
+	})
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name: "denied",
+			Type: "ok",
+		},
+	}
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+	r.NoError(err)
+	runner, err := gen(step, &types.TaskGeneratorOptions{})
+	r.NoError(err)
+	ctx := newWorkflowContextForTest(t)
+	status, operations, err := runner.Run(ctx, &types.TaskRunOptions{
+		PreCheckHooks: []types.TaskPreCheckHook{
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return &types.PreCheckResult{Fail: true, Message: "denied by the policy"}, nil
+			},
+		},
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseFailed)
+	r.Equal(status.Reason, types.StatusReasonPreCheck)
+	r.Equal(status.Message, "denied by the policy")
+	r.Equal(operations.Terminated, true)
+	r.Equal(operations.Waiting, false)
+
+	// the error of the pre check skips the step
+	status, operations, err = runner.Run(ctx, &types.TaskRunOptions{
+		PreCheckHooks: []types.TaskPreCheckHook{
+			func(step v1alpha1.WorkflowStep, options *types.PreCheckOptions) (*types.PreCheckResult, error) {
+				return nil, errors.New("mock error")
+			},
+		},
+	})
+	r.NoError(err)
+	r.Equal(status.Phase, v1alpha1.WorkflowStepPhaseSkipped)
+	r.Equal(status.Message, "pre check error: mock error")
+	r.Equal(operations.Skip, true)
+}
+
+type testStepCache map[string]*types.StepCacheEntry
+
+func (c testStepCache) Get(_ context.Context, key string) (*types.StepCacheEntry, error) {
+	return c[key], nil
+}
+
+func (c testStepCache) Set(_ context.Context, key string, entry *types.StepCacheEntry) error {
+	c[key] = entry
+	return nil
+}
+
+func TestStepCache(t *testing.T) {
+	r := require.New(t)
+	executed := 0
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"output": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			executed++
+			ip, _ := v.MakeValue(`myIP: value: "1.1.1.1"`)
+			return v.FillObject(ip)
+		},
+	})
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:    "cached",
+			Type:    "output",
+			Cache:   &v1alpha1.StepCache{TTL: "1h"},
+			Outputs: v1alpha1.StepOutputs{{Name: "ip", ValueFrom: "myIP.value"}},
+		},
+	}
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	cache := testStepCache{}
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	run := func(step v1alpha1.WorkflowStep) (v1alpha1.StepStatus, wfContext.Context) {
+		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+		r.NoError(err)
+		runner, err := gen(step, &types.TaskGeneratorOptions{StepCache: cache})
+		r.NoError(err)
+		wfCtx := newWorkflowContextForTest(t)
+		status, _, err := runner.Run(wfCtx, &types.TaskRunOptions{})
+		r.NoError(err)
+		return status, wfCtx
+	}
+
+	status, _ := run(step)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+	r.Equal("", status.Reason)
+	r.Equal(1, executed)
+	r.Equal(1, len(cache))
+
+	// the outputs are replayed from the cache
+	status, wfCtx := run(step)
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+	r.Equal(types.StatusReasonCached, status.Reason)
+	r.Equal(1, executed)
+	v, err := wfCtx.GetVar("ip")
+	r.NoError(err)
+	ip, err := v.CueValue().String()
+	r.NoError(err)
+	r.Equal("1.1.1.1", ip)
+
+	// the changed parameters miss the cache
+	changed := step.DeepCopy()
+	changed.Properties = &runtime.RawExtension{Raw: []byte(`{"key":"value"}`)}
+	status, _ = run(*changed)
+	r.Equal("", status.Reason)
+	r.Equal(2, executed)
+
+	// the expired result is not replayed
+	for _, entry := range cache {
+		expiration := metav1.NewTime(time.Now().Add(-time.Minute))
+		entry.ExpirationTime = &expiration
+	}
+	status, _ = run(step)
+	r.Equal("", status.Reason)
+	r.Equal(3, executed)
+}
+
+func TestBreakpoint(t *testing.T) {
+	r := require.New(t)
+	var executed []string
+	discover := providers.NewProviders()
+	discover.Register("test", map[string]types.Handler{
+		"ok": func(mCtx monitorContext.Context, ctx wfContext.Context, v *value.Value, act types.Action) error {
+			var values []string
+			for _, path := range [][]string{{"key"}, {"nested", "a"}, {"nested", "b"}} {
+				s, err := v.GetString(path...)
+				if err != nil {
+					return err
+				}
+				values = append(values, s)
+			}
+			executed = append(executed, strings.Join(values, ","))
+			return nil
+		},
+	})
+	step := v1alpha1.WorkflowStep{
+		WorkflowStepBase: v1alpha1.WorkflowStepBase{
+			Name:       "paused",
+			Type:       "ok",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"key":"value","nested":{"a":"1","b":"2"}}`)},
+		},
+	}
+	pCtx := process.NewContext(process.ContextData{
+		Name:      "app",
+		Namespace: "default",
+	})
+	tasksLoader := NewTaskLoader(mockLoadTemplate, nil, discover, 0, pCtx)
+	var decision *types.StepContinue
+	var snapshot *value.Value
+	options := &types.TaskRunOptions{
+		PCtx: pCtx,
+		Breakpoint: func(_ wfContext.Context, step v1alpha1.WorkflowStep, id string, makeSnapshot func() (*value.Value, error)) (bool, *types.StepContinue, error) {
+			r.Equal("paused-id", id)
+			if decision != nil {
+				return false, decision, nil
+			}
+			v, err := makeSnapshot()
+			r.NoError(err)
+			snapshot = v
+			return true, nil, nil
+		},
+	}
+	run := func() (v1alpha1.StepStatus, *types.Operation) {
+		gen, err := tasksLoader.GetTaskGenerator(context.Background(), step.Type)
+		r.NoError(err)
+		runner, err := gen(step, &types.TaskGeneratorOptions{ID: "paused-id"})
+		r.NoError(err)
+		status, operation, err := runner.Run(newWorkflowContextForTest(t), options)
+		r.NoError(err)
+		return status, operation
+	}
+
+	// the step is paused before it runs
+	status, operation := run()
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, status.Phase)
+	r.Equal(types.StatusReasonBreakpoint, status.Reason)
+	r.True(operation.Waiting)
+	r.Empty(executed)
+	key, err := snapshot.GetString("parameter", "key")
+	r.NoError(err)
+	r.Equal("value", key)
+	stepName, err := snapshot.GetString("context", "stepName")
+	r.NoError(err)
+	r.Equal("paused", stepName)
+
+	// the step continues with the overridden parameter
+	decision = &types.StepContinue{Name: "paused", Parameter: &runtime.RawExtension{Raw: []byte(`{"nested":{"a":"x"}}`)}}
+	status, _ = run()
+	r.Equal(v1alpha1.WorkflowStepPhaseSucceeded, status.Phase)
+	r.Equal([]string{"value,x,2"}, executed)
+
+	// the step is skipped
+	decision = &types.StepContinue{Name: "paused", Skip: true}
+	status, operation = run()
+	r.Equal(v1alpha1.WorkflowStepPhaseSkipped, status.Phase)
+	r.True(operation.Skip)
+	r.Equal(1, len(executed))
+}
+
 func TestValidateIfValue(t *testing.T) {
 	ctx := newWorkflowContextForTest(t)
 	pCtx := process.NewContext(process.ContextData{
@@ -763,6 +1060,10 @@ output: xx
 `, nil
 	case "executeFailed":
 		return fmt.Sprintf(templ, "executeFailed"), nil
+	case "renderingError":
+		return `
+output: {
+`, nil
 	case "ok":
 		return fmt.Sprintf(templ, "ok"), nil
 	case "error":
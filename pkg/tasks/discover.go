@@ -35,13 +35,19 @@ type taskDiscover struct {
 
 // NewTaskDiscover new task discover
 func NewTaskDiscover(ctx monitorContext.Context, options types.StepGeneratorOptions) types.TaskDiscover {
-	return &taskDiscover{
+	td := &taskDiscover{
 		builtin: map[string]types.TaskGenerator{
 			types.WorkflowStepTypeSuspend:   builtin.Suspend,
 			types.WorkflowStepTypeStepGroup: builtin.StepGroup,
 		},
 		customTaskDiscover: custom.NewTaskLoader(options.TemplateLoader.LoadTemplate, options.PackageDiscover, options.Providers, options.LogLevel, options.ProcessCtx),
 	}
+	// the foreach step generates its sub steps at runtime, so it needs the task discover itself
+	td.builtin[types.WorkflowStepTypeForeach] = builtin.NewForeachGenerator(td)
+	if options.Client != nil {
+		td.builtin[types.WorkflowStepTypeSubWorkflow] = builtin.NewSubWorkflowGenerator(options.Client)
+	}
+	return td
 }
 
 // GetTaskGenerator get task generator by name.
@@ -17,16 +17,26 @@ limitations under the License.
 package template
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/pkg/common"
 )
 
 var (
@@ -45,6 +55,9 @@ const (
 	DefinitionNamespace namespaceContextKey = iota
 	// SystemDefinitionNamespace is the system definition namespace
 	systemDefinitionNamespace string = "vela-system"
+
+	definitionAPIVersion       = "core.oam.dev/v1beta1"
+	kindWorkflowStepDefinition = "WorkflowStepDefinition"
 )
 
 // Loader load task definition template.
@@ -85,6 +98,83 @@ func NewWorkflowStepTemplateLoader(client client.Client) Loader {
 	}
 }
 
+// NewWorkflowStepFileLoader create a task template loader which loads the definitions from the files in the paths
+// instead of the cluster, e.g. to run the workflow locally. A definition is either a cue file named after the step
+// type, or a WorkflowStepDefinition in a yaml file. The directories in the paths are walked recursively.
+func NewWorkflowStepFileLoader(paths ...string) Loader {
+	return &WorkflowStepLoader{
+		loadDefinition: func(ctx context.Context, capName string) (string, error) {
+			return getDefinitionTemplateFromFiles(paths, capName)
+		},
+	}
+}
+
+func getDefinitionTemplateFromFiles(paths []string, definitionName string) (string, error) {
+	var template string
+	found := false
+	errFound := errors.New("found")
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			switch filepath.Ext(file) {
+			case ".cue":
+				if filepath.Base(file) != definitionName+".cue" {
+					return nil
+				}
+				content, err := os.ReadFile(filepath.Clean(file))
+				if err != nil {
+					return err
+				}
+				template, found = string(content), true
+			case ".yaml", ".yml", ".json":
+				content, err := os.ReadFile(filepath.Clean(file))
+				if err != nil {
+					return err
+				}
+				if template, found, err = findDefinitionTemplate(file, content, definitionName); err != nil {
+					return errors.WithMessagef(err, "load definitions from %s", file)
+				}
+			}
+			if found {
+				return errFound
+			}
+			return nil
+		})
+		if found {
+			return template, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", apierrors.NewNotFound(schema.GroupResource{Group: "core.oam.dev", Resource: "workflowstepdefinitions"}, definitionName)
+}
+
+// findDefinitionTemplate finds the template of the definition in the yaml documents, the rest of the file is skipped
+// if a document can't be decoded since the paths may contain other yaml or json files, e.g. the values of a chart
+func findDefinitionTemplate(file string, content []byte, definitionName string) (string, bool, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err != io.EOF {
+				klog.V(common.LogDebug).InfoS("Skip the file which can't be decoded when loading the definitions", "file", file, "err", err)
+			}
+			return "", false, nil
+		}
+		if obj.GetKind() != kindWorkflowStepDefinition || obj.GetName() != definitionName {
+			continue
+		}
+		d := new(def)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, d); err != nil {
+			return "", false, errors.Wrap(err, "invalid workflow step definition")
+		}
+		return d.Spec.Schematic.CUE.Template, true, nil
+	}
+}
+
 type def struct {
 	Spec struct {
 		Schematic struct {
@@ -96,10 +186,6 @@ type def struct {
 }
 
 func getDefinitionTemplate(ctx context.Context, cli client.Client, definitionName string) (string, error) {
-	const (
-		definitionAPIVersion       = "core.oam.dev/v1beta1"
-		kindWorkflowStepDefinition = "WorkflowStepDefinition"
-	)
 	definition := &unstructured.Unstructured{}
 	definition.SetAPIVersion(definitionAPIVersion)
 	definition.SetKind(kindWorkflowStepDefinition)
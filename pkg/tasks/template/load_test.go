@@ -20,10 +20,12 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
@@ -73,6 +75,43 @@ parameter: {
 }`)
 }
 
+func TestLoadFromFiles(t *testing.T) {
+	r := require.New(t)
+	dir := t.TempDir()
+	r.NoError(os.MkdirAll(filepath.Join(dir, "nested"), 0750))
+	r.NoError(os.WriteFile(filepath.Join(dir, "nested", "print.cue"), []byte("parameter: message: string"), 0600))
+	r.NoError(os.WriteFile(filepath.Join(dir, "defs.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: apply-oam-component\n---\n"+stepDefYaml), 0600))
+	// the files which can't be decoded are skipped
+	r.NoError(os.WriteFile(filepath.Join(dir, "nested", "chart.yaml"), []byte("name: {{ .Values.name }}\n  image: [\n"), 0600))
+	r.NoError(os.WriteFile(filepath.Join(dir, "nested", "list.json"), []byte(`["a", "b"]`), 0600))
+	loader := NewWorkflowStepFileLoader(dir)
+	ctx := context.Background()
+
+	tmpl, err := loader.LoadTemplate(ctx, "builtin-apply-component")
+	r.NoError(err)
+	expected, err := os.ReadFile("./static/builtin-apply-component.cue")
+	r.NoError(err)
+	r.Equal(string(expected), tmpl)
+
+	tmpl, err = loader.LoadTemplate(ctx, "print")
+	r.NoError(err)
+	r.Equal("parameter: message: string", tmpl)
+
+	tmpl, err = loader.LoadTemplate(ctx, "apply-oam-component")
+	r.NoError(err)
+	r.Contains(tmpl, "apply: op.#ApplyComponent & {")
+
+	_, err = loader.LoadTemplate(ctx, "not-found")
+	r.Error(err)
+	r.True(apierrors.IsNotFound(err))
+
+	invalidDir := t.TempDir()
+	r.NoError(os.WriteFile(filepath.Join(invalidDir, "invalid.yaml"), []byte("apiVersion: core.oam.dev/v1beta1\nkind: WorkflowStepDefinition\nmetadata:\n  name: invalid\nspec:\n  schematic: invalid\n"), 0600))
+	_, err = NewWorkflowStepFileLoader(invalidDir).LoadTemplate(ctx, "invalid")
+	r.Error(err)
+	r.Contains(err.Error(), "invalid workflow step definition")
+}
+
 var (
 	stepDefYaml = `apiVersion: core.oam.dev/v1beta1
 kind: WorkflowStepDefinition
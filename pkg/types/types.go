@@ -18,9 +18,12 @@ package types
 
 import (
 	"context"
+	"math"
+	"time"
 
 	"cuelang.org/go/cue"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/util/feature"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -40,9 +43,17 @@ type WorkflowInstance struct {
 	WorkflowMeta
 	OwnerInfo []metav1.OwnerReference
 	Debug     bool
-	Mode      *v1alpha1.WorkflowExecuteMode
-	Steps     []v1alpha1.WorkflowStep
-	Status    v1alpha1.WorkflowRunStatus
+	DryRun    bool
+	// Breakpoints are the names of the steps paused before they run, BreakpointAll pauses every step
+	Breakpoints []string
+	Mode        *v1alpha1.WorkflowExecuteMode
+	Timeout     string
+	Context     *runtime.RawExtension
+	Steps       []v1alpha1.WorkflowStep
+	OnSuccess   []v1alpha1.WorkflowStep
+	OnFailure   []v1alpha1.WorkflowStep
+	Finally     []v1alpha1.WorkflowStep
+	Status      v1alpha1.WorkflowRunStatus
 }
 
 // WorkflowMeta is the meta information for workflow instance
@@ -85,6 +96,7 @@ type TaskRunOptions struct {
 	GetTracer     func(id string, step v1alpha1.WorkflowStep) monitorContext.Context
 	RunSteps      func(isDag bool, runners ...TaskRunner) (*v1alpha1.WorkflowRunStatus, error)
 	Debug         func(step string, v *value.Value) error
+	Breakpoint    TaskBreakpoint
 	StepStatus    map[string]v1alpha1.StepStatus
 	Engine        Engine
 }
@@ -93,6 +105,18 @@ type TaskRunOptions struct {
 type PreCheckResult struct {
 	Skip    bool
 	Timeout bool
+	// Fail fails the step before it runs with the reason and the message, e.g. the step is denied by a policy
+	Fail    bool
+	Reason  string
+	Message string
+}
+
+// GetFailedReason returns the reason of the step failed by the pre check, it's PreCheck if the reason is not set.
+func (r *PreCheckResult) GetFailedReason() string {
+	if r.Reason == "" {
+		return StatusReasonPreCheck
+	}
+	return r.Reason
 }
 
 // PreCheckOptions is the options for pre check.
@@ -110,6 +134,21 @@ type TaskPreStartHook func(ctx wfContext.Context, paramValue *value.Value, step
 // TaskPostStopHook  run after task execution.
 type TaskPostStopHook func(ctx wfContext.Context, taskValue *value.Value, step v1alpha1.WorkflowStep, status v1alpha1.StepStatus, stepStatus map[string]v1alpha1.StepStatus) error
 
+// TaskBreakpoint pauses the step at the breakpoint before it runs, the snapshot makes what the step sees, e.g. the
+// parameter with the inputs filled. It returns true if the step is paused, otherwise the decision to continue the
+// step, which is nil if there's no breakpoint at the step.
+type TaskBreakpoint func(ctx wfContext.Context, step v1alpha1.WorkflowStep, id string, snapshot func() (*value.Value, error)) (bool, *StepContinue, error)
+
+// StepContinue is the decision to continue the step paused at the breakpoint
+type StepContinue struct {
+	// Name is the name or the id of the paused step
+	Name string `json:"name"`
+	// Skip skips the step instead of running it
+	Skip bool `json:"skip,omitempty"`
+	// Parameter overrides the values in the parameter of the step
+	Parameter *runtime.RawExtension `json:"parameter,omitempty"`
+}
+
 // Operation is workflow operation object.
 type Operation struct {
 	Suspend            bool
@@ -131,6 +170,7 @@ type TaskGeneratorOptions struct {
 	SubStepExecuteMode v1alpha1.WorkflowMode
 	PackageDiscover    *packages.PackageDiscover
 	ProcessContext     process.Context
+	StepCache          StepCache
 }
 
 // Handler is provider's processing method.
@@ -151,6 +191,20 @@ type StepGeneratorOptions struct {
 	Client          client.Client
 	StepConvertor   map[string]func(step v1alpha1.WorkflowStep) (v1alpha1.WorkflowStep, error)
 	LogLevel        int
+	StepCache       StepCache
+}
+
+// StepCacheEntry is the cached result of a step
+type StepCacheEntry struct {
+	// Outputs is the outputs of the step in cue
+	Outputs        map[string]string `json:"outputs,omitempty"`
+	ExpirationTime *metav1.Time      `json:"expirationTime,omitempty"`
+}
+
+// StepCache is the store of the cached step results, the entry is nil if it's not cached.
+type StepCache interface {
+	Get(ctx context.Context, key string) (*StepCacheEntry, error)
+	Set(ctx context.Context, key string, entry *StepCacheEntry) error
 }
 
 // Action is that workflow provider can do.
@@ -201,15 +255,21 @@ const (
 	// ContextKeyMetadata is key that refer to workflow metadata.
 	ContextKeyMetadata = "metadata__"
 	// ContextPrefixFailedTimes is the prefix that refer to the failed times of the step in workflow context config map.
-	ContextPrefixFailedTimes = "failed_times"
+	ContextPrefixFailedTimes = wfContext.MemoryPrefixFailedTimes
 	// ContextPrefixBackoffTimes is the prefix that refer to the backoff times in workflow context config map.
-	ContextPrefixBackoffTimes = "backoff_times"
+	ContextPrefixBackoffTimes = wfContext.MemoryPrefixBackoffTimes
 	// ContextPrefixBackoffReason is the prefix that refer to the current backoff reason in workflow context config map
-	ContextPrefixBackoffReason = "backoff_reason"
+	ContextPrefixBackoffReason = wfContext.MemoryPrefixBackoffReason
+	// ContextPrefixResume is the prefix that refer to the pending resume decision of the suspend step in workflow context config map.
+	ContextPrefixResume = "resume"
+	// ContextPrefixContinue is the prefix that refer to the decision to continue the step paused at the breakpoint in workflow context config map.
+	ContextPrefixContinue = "continue"
+	// ContextPrefixDryRun is the prefix that refer to the recorded plan of the step in the memory of workflow context.
+	ContextPrefixDryRun = "dryrun"
 	// ContextKeyLastExecuteTime is the key that refer to the last execute time in workflow context config map.
-	ContextKeyLastExecuteTime = "last_execute_time"
+	ContextKeyLastExecuteTime = wfContext.MemoryKeyLastExecuteTime
 	// ContextKeyNextExecuteTime is the key that refer to the next execute time in workflow context config map.
-	ContextKeyNextExecuteTime = "next_execute_time"
+	ContextKeyNextExecuteTime = wfContext.MemoryKeyNextExecuteTime
 	// ContextKeyLogConfig is key for log config.
 	ContextKeyLogConfig = "logConfig"
 )
@@ -223,6 +283,10 @@ const (
 	WorkflowStepTypeBuiltinApplyComponent = "builtin-apply-component"
 	// WorkflowStepTypeStepGroup type step-group
 	WorkflowStepTypeStepGroup = "step-group"
+	// WorkflowStepTypeForeach type foreach
+	WorkflowStepTypeForeach = "foreach"
+	// WorkflowStepTypeSubWorkflow type sub-workflow
+	WorkflowStepTypeSubWorkflow = "sub-workflow"
 )
 
 const (
@@ -230,6 +294,11 @@ const (
 	LabelWorkflowRunName = "workflowrun.oam.dev/name"
 	// LabelWorkflowRunNamespace is the label key for workflow run namespace
 	LabelWorkflowRunNamespace = "workflowrun.oam.dev/namespace"
+	// LabelParentWorkflowRunUID is the label key for the uid of the workflow run which creates the child workflow run
+	// by its sub-workflow step, the uid is used since the name of the parent may exceed the length of the label value
+	LabelParentWorkflowRunUID = "workflowrun.oam.dev/parent-uid"
+	// LabelCronWorkflowName is the label key for the name of the cron workflow which creates the workflow run
+	LabelCronWorkflowName = "cronworkflow.oam.dev/name"
 )
 
 var (
@@ -239,6 +308,11 @@ var (
 	MaxWorkflowWaitBackoffTime = 60
 	// MaxWorkflowFailedBackoffTime is the max time to wait before reconcile failed workflow again
 	MaxWorkflowFailedBackoffTime = 300
+	// MaxSubWorkflowDepth is the max nesting depth of the child workflow runs created by the sub-workflow steps
+	MaxSubWorkflowDepth = 5
+	// MaxWorkflowStepParallelism is the default max number of the steps running concurrently in DAG mode, the steps
+	// run one by one if it's not larger than 1
+	MaxWorkflowStepParallelism = 1
 )
 
 const (
@@ -264,11 +338,33 @@ const (
 	StatusReasonTimeout = "Timeout"
 	// StatusReasonAction is the reason of the workflow progress condition which is Action.
 	StatusReasonAction = "Action"
+	// StatusReasonSubWorkflow is the reason of the workflow progress condition which is SubWorkflow.
+	StatusReasonSubWorkflow = "SubWorkflow"
+	// StatusReasonReject is the reason of the workflow progress condition which is Reject.
+	StatusReasonReject = "Reject"
+	// StatusReasonCached is the reason of the workflow progress condition which is Cached.
+	StatusReasonCached = "Cached"
+	// StatusReasonBreakpoint is the reason of the workflow progress condition which is Breakpoint.
+	StatusReasonBreakpoint = "Breakpoint"
+	// StatusReasonPreCheck is the reason of the workflow progress condition which is PreCheck.
+	StatusReasonPreCheck = "PreCheck"
+)
+
+const (
+	// StatusKeyWorkflow is the key of the workflow outcome in the status map of the exit handlers.
+	StatusKeyWorkflow = "workflow"
+)
+
+const (
+	// SuspendStateManual is the suspend state of the workflow run which is suspended by its spec
+	SuspendStateManual = "manual"
 )
 
 const (
 	// MessageTerminated is the message of failed workflow
 	MessageTerminated = "The workflow terminates because of the failed steps"
+	// MessageTimeout is the message of timeout workflow
+	MessageTimeout = "The workflow terminates because of the timeout"
 	// MessageSuspendFailedAfterRetries is the message of failed after retries
 	MessageSuspendFailedAfterRetries = "The workflow suspends automatically because the failed times of steps have reached the limit"
 )
@@ -276,8 +372,37 @@ const (
 const (
 	// AnnotationWorkflowRunDebug is the annotation for debug
 	AnnotationWorkflowRunDebug = "workflowrun.oam.dev/debug"
+	// AnnotationWorkflowRunDryRun is the annotation for dry run, it works the same as spec.dryRun
+	AnnotationWorkflowRunDryRun = "workflowrun.oam.dev/dry-run"
+	// AnnotationCronWorkflowScheduledTime is the annotation for the scheduled time of the workflow run created by cron workflow
+	AnnotationCronWorkflowScheduledTime = "cronworkflow.oam.dev/scheduled-time"
+	// AnnotationWorkflowRunRestart is the annotation to restart the workflow run from the step in the value,
+	// the run is restarted from the first failed step if the value is empty
+	AnnotationWorkflowRunRestart = "workflowrun.oam.dev/restart"
+	// AnnotationWorkflowRunBreakpoints is the annotation of the comma separated names of the steps which are paused
+	// before they run, every step is paused if it's BreakpointAll. The snapshots of the steps are kept as in debug.
+	AnnotationWorkflowRunBreakpoints = "workflowrun.oam.dev/breakpoints"
+	// AnnotationWorkflowRunContinue is the annotation of the json list of StepContinue to continue the steps
+	// paused at the breakpoints, it's removed after the decisions are stored in the workflow context
+	AnnotationWorkflowRunContinue = "workflowrun.oam.dev/continue"
+	// AnnotationWorkflowRunControlUser is the annotation of the user who changed the controls in the spec lastly,
+	// it's stamped by the mutating webhook
+	AnnotationWorkflowRunControlUser = "workflowrun.oam.dev/control-user"
 )
 
+// FinalizerWorkflowRunArtifacts is the finalizer of the workflow run to delete the artifacts referenced by its context
+// before the context is garbage collected
+const FinalizerWorkflowRunArtifacts = "workflowrun.oam.dev/artifacts"
+
+// BreakpointAll is the breakpoint which pauses every step
+const BreakpointAll = "*"
+
+// IsStepStatusFinish will decide whether step is finish by its status, the failed step which is retried later is not
+// finished whatever the reason of the failure is.
+func IsStepStatusFinish(status v1alpha1.StepStatus) bool {
+	return !status.Retrying && IsStepFinish(status.Phase, status.Reason)
+}
+
 // IsStepFinish will decide whether step is finish.
 func IsStepFinish(phase v1alpha1.WorkflowStepPhase, reason string) bool {
 	if feature.DefaultMutableFeatureGate.Enabled(features.EnableSuspendOnFailure) {
@@ -295,6 +420,102 @@ func IsStepFinish(phase v1alpha1.WorkflowStepPhase, reason string) bool {
 	}
 }
 
+// RangeSteps calls fn with the steps and their sub steps in any depth, the parent step is visited before its sub steps.
+func RangeSteps(steps []v1alpha1.WorkflowStep, fn func(step v1alpha1.WorkflowStep)) {
+	for _, step := range steps {
+		fn(step)
+		RangeSteps(step.SubSteps, fn)
+	}
+}
+
+// RangeStepStatus calls fn with the status of the steps and their sub steps in any depth, the parent step is visited
+// before its sub steps. It stops and returns false once fn returns false.
+func RangeStepStatus(steps []v1alpha1.WorkflowStepStatus, fn func(status *v1alpha1.WorkflowStepStatus) bool) bool {
+	for i := range steps {
+		if !fn(&steps[i]) || !RangeStepStatus(steps[i].SubStepsStatus, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindStepStatus returns the status of the step or the sub step in any depth by name, it returns nil if not found.
+func FindStepStatus(steps []v1alpha1.WorkflowStepStatus, name string) *v1alpha1.WorkflowStepStatus {
+	var found *v1alpha1.WorkflowStepStatus
+	RangeStepStatus(steps, func(status *v1alpha1.WorkflowStepStatus) bool {
+		if status.Name == name {
+			found = status
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// GetRetryLimit returns the max failed attempts of the step, the step is failed after it fails as many times. The
+// step is retried MaxWorkflowStepErrorRetryTimes times if the limit is not specified.
+func GetRetryLimit(retry *v1alpha1.RetryPolicy) int {
+	if retry == nil || retry.Limit == nil {
+		return MaxWorkflowStepErrorRetryTimes + 1
+	}
+	return *retry.Limit
+}
+
+// IsRetryableReason will decide whether the step failed with the reason should be retried.
+func IsRetryableReason(retry *v1alpha1.RetryPolicy, reason string) bool {
+	if retry == nil || len(retry.RetryOn) == 0 {
+		return reason == StatusReasonExecute
+	}
+	for _, r := range retry.RetryOn {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRetryBackoffTime returns the seconds to wait before the next retry of the failed step.
+// The second return value is false if the retry policy doesn't specify the delay.
+func GetRetryBackoffTime(retry *v1alpha1.RetryPolicy, failedTimes int) (int, bool) {
+	if retry == nil || retry.Delay == "" {
+		return 0, false
+	}
+	delay, err := time.ParseDuration(retry.Delay)
+	if err != nil {
+		return 0, false
+	}
+	maxDelay := time.Duration(MaxWorkflowFailedBackoffTime) * time.Second
+	if retry.MaxDelay != "" {
+		if d, err := time.ParseDuration(retry.MaxDelay); err == nil {
+			maxDelay = d
+		}
+	}
+	// the first retry always waits for the base delay
+	retried := failedTimes - 1
+	if retried < 0 {
+		retried = 0
+	}
+	switch retry.Backoff {
+	case v1alpha1.RetryBackoffFixed:
+	case v1alpha1.RetryBackoffLinear:
+		delay *= time.Duration(retried + 1)
+	default:
+		// avoid overflow when the failed times is large
+		if retried > 30 {
+			retried = 30
+		}
+		delay *= time.Duration(math.Pow(2, float64(retried)))
+	}
+	if delay > maxDelay || delay < 0 {
+		delay = maxDelay
+	}
+	seconds := int(math.Ceil(delay.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds, true
+}
+
 // SetNamespaceInCtx set namespace in context.
 func SetNamespaceInCtx(ctx context.Context, namespace string) context.Context {
 	if namespace == "" {
@@ -304,3 +525,16 @@ func SetNamespaceInCtx(ctx context.Context, namespace string) context.Context {
 	ctx = context.WithValue(ctx, template.DefinitionNamespace, namespace)
 	return ctx
 }
+
+type stepNameKey struct{}
+
+// SetStepNameInCtx set the name of the running step in context.
+func SetStepNameInCtx(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, stepNameKey{}, step)
+}
+
+// GetStepNameFromCtx get the name of the running step from context.
+func GetStepNameFromCtx(ctx context.Context) string {
+	step, _ := ctx.Value(stepNameKey{}).(string)
+	return step
+}
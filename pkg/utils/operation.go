@@ -24,12 +24,15 @@ import (
 	"net/http"
 
 	"github.com/kubevela/pkg/multicluster"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	wfContext "github.com/kubevela/workflow/pkg/context"
 	"github.com/kubevela/workflow/pkg/cue/model/value"
 	"github.com/kubevela/workflow/pkg/types"
@@ -90,6 +93,7 @@ func GetPodListFromResources(ctx context.Context, cli client.Client, resources [
 			var podList corev1.PodList
 			err = cli.List(cliCtx, &podList, &client.ListOptions{
 				LabelSelector: selector,
+				Namespace:     resource.Namespace,
 			})
 			if err != nil {
 				return nil, err
@@ -102,6 +106,7 @@ func GetPodListFromResources(ctx context.Context, cli client.Client, resources [
 		if err != nil {
 			return nil, err
 		}
+		pods = append(pods, pod)
 	}
 	return pods, nil
 }
@@ -133,3 +138,390 @@ func GetLogsFromPod(ctx context.Context, cfg *rest.Config, cli client.Client, po
 	}
 	return readCloser, nil
 }
+
+// TerminateWorkflow terminates the workflow run, the running steps are marked as failed with the reason Terminate
+func TerminateWorkflow(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+	TerminateWorkflowStatus(&run.Status)
+	return cli.Status().Patch(ctx, run, client.Merge)
+}
+
+// GenerateSubWorkflowRunName generates the name of the child workflow run created by the sub-workflow step
+func GenerateSubWorkflowRunName(parent, step string) string {
+	return fmt.Sprintf("%s-%s", parent, step)
+}
+
+// GetParentWorkflowRunName returns the name of the workflow run which creates the child workflow run by its
+// sub-workflow step, it's empty if the run is not a child.
+func GetParentWorkflowRunName(run metav1.Object) string {
+	for _, owner := range run.GetOwnerReferences() {
+		if owner.Kind == v1alpha1.WorkflowRunKind {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// IsSubWorkflowOf checks whether the child workflow run is created by the sub-workflow step of the parent, the
+// generated names of the children may collide, e.g. the step "c" of the parent "a-b" and the step "b-c" of "a".
+func IsSubWorkflowOf(child, parent metav1.Object) bool {
+	owner := metav1.GetControllerOf(child)
+	return owner != nil && owner.Kind == v1alpha1.WorkflowRunKind && owner.UID == parent.GetUID()
+}
+
+// TerminateSubWorkflows terminates the unfinished child workflow runs created by the sub-workflow steps of the run
+func TerminateSubWorkflows(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) error {
+	children := &v1alpha1.WorkflowRunList{}
+	if err := cli.List(ctx, children, client.InNamespace(run.Namespace), client.MatchingLabels{types.LabelParentWorkflowRunUID: string(run.UID)}); err != nil {
+		return errors.WithMessage(err, "list sub workflow runs")
+	}
+	for i := range children.Items {
+		child := &children.Items[i]
+		if !IsSubWorkflowOf(child, run) || child.Status.Finished || child.Status.Terminated || child.DeletionTimestamp != nil {
+			continue
+		}
+		if err := TerminateWorkflow(ctx, cli, child); err != nil {
+			return errors.WithMessagef(err, "terminate sub workflow run %s", child.Name)
+		}
+	}
+	return nil
+}
+
+// TerminateWorkflowStatus marks the status of the workflow run as terminated without updating it
+func TerminateWorkflowStatus(status *v1alpha1.WorkflowRunStatus) {
+	status.Terminated = true
+	status.Suspend = false
+	status.SuspendState = ""
+	types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		terminateStepStatus(&step.StepStatus)
+		return true
+	})
+}
+
+// SuspendWorkflowStatus marks the status of the workflow run as suspended manually without updating it
+func SuspendWorkflowStatus(status *v1alpha1.WorkflowRunStatus) {
+	status.Suspend = true
+	status.SuspendState = types.SuspendStateManual
+}
+
+// ResumeWorkflowStatus resumes the workflow run which is suspended manually without updating it, the running
+// suspend steps keep suspending the workflow until they are resumed.
+func ResumeWorkflowStatus(status *v1alpha1.WorkflowRunStatus) {
+	status.Suspend = hasRunningSuspendStep(status)
+	status.SuspendState = ""
+}
+
+// ResumeWorkflowStep resumes or rejects the running suspend step identified by the name or id in the resume. The
+// decision is stored in the workflow context and consumed by the suspend step in its next execution, the payload of
+// the decision becomes the outputs of the step. It returns false if the step is not waiting for resume or there's
+// already a pending decision of the step.
+func ResumeWorkflowStep(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, resume v1alpha1.StepResume) (bool, error) {
+	id := getRunningSuspendStepID(run.Status, resume.Name)
+	if id == "" {
+		return false, nil
+	}
+	wfCtx, err := wfContext.LoadContext(cli, run.Namespace, run.Name)
+	if err != nil {
+		return false, errors.WithMessage(err, "load workflow context")
+	}
+	if wfCtx.GetMutableValue(types.ContextPrefixResume, id) != "" {
+		return false, nil
+	}
+	b, err := json.Marshal(resume)
+	if err != nil {
+		return false, err
+	}
+	wfCtx.SetMutableValue(string(b), types.ContextPrefixResume, id)
+	if err := wfCtx.Commit(); err != nil {
+		return false, errors.WithMessage(err, "save the resume decision")
+	}
+	return true, nil
+}
+
+// getRunningSuspendStepID returns the id of the running suspend step whose name or id is the given one
+func getRunningSuspendStepID(status v1alpha1.WorkflowRunStatus, name string) string {
+	match := func(ss v1alpha1.StepStatus) bool {
+		return (ss.Name == name || ss.ID == name) && ss.Type == types.WorkflowStepTypeSuspend && ss.Phase == v1alpha1.WorkflowStepPhaseRunning
+	}
+	id := ""
+	types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		if match(step.StepStatus) {
+			id = step.ID
+			return false
+		}
+		return true
+	})
+	return id
+}
+
+// ContinueWorkflowStep stores the decision to continue the step paused at the breakpoint in the workflow context,
+// it returns false if the step is not paused or the decision of it is pending.
+func ContinueWorkflowStep(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, decision types.StepContinue) (bool, error) {
+	id := getPausedStepID(run.Status, decision.Name)
+	if id == "" {
+		return false, nil
+	}
+	wfCtx, err := wfContext.LoadContext(cli, run.Namespace, run.Name)
+	if err != nil {
+		return false, errors.WithMessage(err, "load workflow context")
+	}
+	if wfCtx.GetMutableValue(types.ContextPrefixContinue, id) != "" {
+		return false, nil
+	}
+	b, err := json.Marshal(decision)
+	if err != nil {
+		return false, err
+	}
+	wfCtx.SetMutableValue(string(b), types.ContextPrefixContinue, id)
+	if err := wfCtx.Commit(); err != nil {
+		return false, errors.WithMessage(err, "save the decision at the breakpoint")
+	}
+	return true, nil
+}
+
+// getPausedStepID returns the id of the step paused at the breakpoint whose name or id is the given one
+func getPausedStepID(status v1alpha1.WorkflowRunStatus, name string) string {
+	id := ""
+	types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		if (step.Name == name || step.ID == name) && step.Phase == v1alpha1.WorkflowStepPhaseRunning && step.Reason == types.StatusReasonBreakpoint {
+			id = step.ID
+			return false
+		}
+		return true
+	})
+	return id
+}
+
+func hasRunningSuspendStep(status *v1alpha1.WorkflowRunStatus) bool {
+	return !types.RangeStepStatus(status.Steps, func(step *v1alpha1.WorkflowStepStatus) bool {
+		return step.Type != types.WorkflowStepTypeSuspend || step.Phase != v1alpha1.WorkflowStepPhaseRunning
+	})
+}
+
+func terminateStepStatus(status *v1alpha1.StepStatus) {
+	status.Retrying = false
+	switch status.Phase {
+	case v1alpha1.WorkflowStepPhaseFailed:
+		if status.Reason != types.StatusReasonFailedAfterRetries && status.Reason != types.StatusReasonTimeout &&
+			status.Reason != types.StatusReasonReject {
+			status.Reason = types.StatusReasonTerminate
+		}
+	case v1alpha1.WorkflowStepPhaseRunning:
+		status.Phase = v1alpha1.WorkflowStepPhaseFailed
+		status.Reason = types.StatusReasonTerminate
+	default:
+	}
+}
+
+// RestartWorkflowFromStep restarts the workflow run from the step, the status of the step and the steps depending
+// on it is reset while the other steps keep their status and outputs. The run is restarted from the first failed
+// step if the step is empty.
+func RestartWorkflowFromStep(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, step string) error {
+	if step == "" {
+		step = getFirstFailedStep(run.Status.Steps)
+		if step == "" {
+			return errors.New("no failed step to restart from")
+		}
+	}
+	steps, err := getWorkflowSteps(ctx, cli, run)
+	if err != nil {
+		return err
+	}
+	status := &run.Status
+	path := findStepPath(status.Steps, step)
+	if path == nil {
+		return errors.Errorf("step %s is not found in the status of workflow run %s", step, run.Name)
+	}
+	// the ids of the reset steps, whose retry and backoff counters are cleared
+	var resetIDs []string
+	stepStatus := resetSteps(steps, status.Steps, path, status.Mode.Steps, status.Mode.SubSteps, &resetIDs)
+	// the exit handlers are executed again after the restarted steps finished
+	types.RangeStepStatus(status.ExitHandlers, func(ss *v1alpha1.WorkflowStepStatus) bool {
+		resetIDs = append(resetIDs, ss.ID)
+		return true
+	})
+	if err := deleteSubWorkflows(ctx, cli, run, resetIDs); err != nil {
+		return err
+	}
+
+	status.Steps = stepStatus
+	status.ExitHandlers = nil
+	status.Phase = v1alpha1.WorkflowStateExecuting
+	status.Message = ""
+	status.Suspend = false
+	status.SuspendState = ""
+	status.Terminated = false
+	status.Finished = false
+	// the timeout of the workflow is counted from the restart
+	status.StartTime = metav1.Now()
+	status.EndTime = metav1.Time{}
+	status.RestartCount++
+	if wfCtx, err := wfContext.LoadContext(cli, run.Namespace, run.Name); err == nil {
+		for _, id := range resetIDs {
+			wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffTimes, id)
+			wfCtx.DeleteValueInMemory(types.ContextPrefixBackoffReason, id)
+			wfCtx.DeleteValueInMemory(types.ContextPrefixFailedTimes, id)
+			wfCtx.DeleteMutableValue(types.ContextPrefixContinue, id)
+		}
+		if err := wfCtx.Commit(); err != nil {
+			return errors.WithMessage(err, "commit workflow context")
+		}
+	}
+	return cli.Status().Update(ctx, run)
+}
+
+// ClearSpecControls clears the controls in the spec which are applied before the workflow run is restarted, so that
+// the restarted run isn't terminated or suspended again and the restarted suspend steps wait for the new decisions.
+// The decisions of the suspend steps which are still running are kept.
+func ClearSpecControls(run *v1alpha1.WorkflowRun) {
+	run.Spec.Terminate = false
+	run.Spec.Suspend = false
+	var resumeSteps []v1alpha1.StepResume
+	for _, resume := range run.Spec.ResumeSteps {
+		if getRunningSuspendStepID(run.Status, resume.Name) != "" {
+			resumeSteps = append(resumeSteps, resume)
+		}
+	}
+	run.Spec.ResumeSteps = resumeSteps
+}
+
+// deleteSubWorkflows deletes the child workflow runs of the reset sub-workflow steps, so that the steps create new
+// ones instead of mirroring the finished ones. The workflow runs with the same names which are not created by the
+// run are left untouched.
+func deleteSubWorkflows(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun, resetIDs []string) error {
+	reset := make(map[string]bool, len(resetIDs))
+	for _, id := range resetIDs {
+		reset[id] = true
+	}
+	var names []string
+	for _, statuses := range [][]v1alpha1.WorkflowStepStatus{run.Status.Steps, run.Status.ExitHandlers} {
+		types.RangeStepStatus(statuses, func(ss *v1alpha1.WorkflowStepStatus) bool {
+			if reset[ss.ID] && ss.Type == types.WorkflowStepTypeSubWorkflow {
+				names = append(names, GenerateSubWorkflowRunName(run.Name, ss.Name))
+			}
+			return true
+		})
+	}
+	for _, name := range names {
+		child := &v1alpha1.WorkflowRun{}
+		if err := cli.Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: name}, child); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return errors.WithMessagef(err, "get sub workflow run %s", name)
+		}
+		if !IsSubWorkflowOf(child, run) {
+			continue
+		}
+		// the precondition avoids deleting the workflow run which is recreated with the same name after the get
+		if err := cli.Delete(ctx, child, client.PropagationPolicy(metav1.DeletePropagationBackground), client.Preconditions{UID: &child.UID}); client.IgnoreNotFound(err) != nil {
+			return errors.WithMessagef(err, "delete sub workflow run %s", name)
+		}
+	}
+	return nil
+}
+
+// findStepPath returns the names of the step groups from the top level to the step, followed by the step itself
+func findStepPath(steps []v1alpha1.WorkflowStepStatus, name string) []string {
+	for _, ss := range steps {
+		if ss.Name == name {
+			return []string{name}
+		}
+		if path := findStepPath(ss.SubStepsStatus, name); path != nil {
+			return append([]string{ss.Name}, path...)
+		}
+	}
+	return nil
+}
+
+// resetSteps removes the status of the last step in the path and the steps after it in the same level, the step
+// groups in the path are re-executed to run their reset sub steps.
+func resetSteps(steps []v1alpha1.WorkflowStep, statuses []v1alpha1.WorkflowStepStatus, path []string, mode, subMode v1alpha1.WorkflowMode, resetIDs *[]string) []v1alpha1.WorkflowStepStatus {
+	resets := getDownstreamSteps(steps, path[0], mode)
+	if len(path) > 1 {
+		// only the sub steps of the parent group are reset, the group itself is re-executed to run them
+		delete(resets, path[0])
+	}
+	var result []v1alpha1.WorkflowStepStatus
+	for _, ss := range statuses {
+		if resets[ss.Name] {
+			types.RangeStepStatus([]v1alpha1.WorkflowStepStatus{ss}, func(reset *v1alpha1.WorkflowStepStatus) bool {
+				*resetIDs = append(*resetIDs, reset.ID)
+				return true
+			})
+			continue
+		}
+		if len(path) > 1 && ss.Name == path[0] {
+			var group v1alpha1.WorkflowStep
+			for _, s := range steps {
+				if s.Name == path[0] {
+					group = s
+				}
+			}
+			groupMode := subMode
+			if group.Mode != "" {
+				groupMode = group.Mode
+			}
+			ss.SubStepsStatus = resetSteps(group.SubSteps, ss.SubStepsStatus, path[1:], groupMode, subMode, resetIDs)
+			ss.Phase = v1alpha1.WorkflowStepPhaseRunning
+			ss.Reason = ""
+			ss.Message = ""
+		}
+		result = append(result, ss)
+	}
+	return result
+}
+
+// getFirstFailedStep returns the first failed step, the failed sub steps are returned rather than their groups
+func getFirstFailedStep(steps []v1alpha1.WorkflowStepStatus) string {
+	for _, ss := range steps {
+		if name := getFirstFailedStep(ss.SubStepsStatus); name != "" {
+			return name
+		}
+		if ss.Phase == v1alpha1.WorkflowStepPhaseFailed {
+			return ss.Name
+		}
+	}
+	return ""
+}
+
+func getWorkflowSteps(ctx context.Context, cli client.Client, run *v1alpha1.WorkflowRun) ([]v1alpha1.WorkflowStep, error) {
+	if run.Spec.WorkflowSpec != nil {
+		return run.Spec.WorkflowSpec.Steps, nil
+	}
+	workflow := &v1alpha1.Workflow{}
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: run.Namespace, Name: run.Spec.WorkflowRef}, workflow); err != nil {
+		return nil, errors.WithMessagef(err, "get workflow %s", run.Spec.WorkflowRef)
+	}
+	return workflow.Steps, nil
+}
+
+// getDownstreamSteps returns the step and the steps after it, the steps depending on it are returned in DAG mode.
+func getDownstreamSteps(steps []v1alpha1.WorkflowStep, name string, mode v1alpha1.WorkflowMode) map[string]bool {
+	downstream := map[string]bool{name: true}
+	if mode != v1alpha1.WorkflowModeDAG {
+		after := false
+		for _, step := range steps {
+			after = after || step.Name == name
+			if after {
+				downstream[step.Name] = true
+			}
+		}
+		return downstream
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, step := range steps {
+			if downstream[step.Name] {
+				continue
+			}
+			for _, dependsOn := range step.DependsOn {
+				if downstream[dependsOn] {
+					downstream[step.Name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return downstream
+}
@@ -22,11 +22,18 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/kubevela/workflow/api/v1alpha1"
 	"github.com/kubevela/workflow/pkg/cue/model/sets"
+	"github.com/kubevela/workflow/pkg/types"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -166,3 +173,389 @@ func TestGetStepLogConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartWorkflowFromStep(t *testing.T) {
+	step := func(name string, dependsOn ...string) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: "apply", DependsOn: dependsOn}}
+	}
+	stepStatus := func(name string, phase v1alpha1.WorkflowStepPhase, subs ...v1alpha1.WorkflowStepStatus) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{ID: name, Name: name, Phase: phase}, SubStepsStatus: subs}
+	}
+	group := step("group")
+	group.SubSteps = []v1alpha1.WorkflowStep{step("sub1"), step("sub2", "sub1"), step("sub3")}
+	testCases := map[string]struct {
+		mode        v1alpha1.WorkflowMode
+		step        string
+		expected    []string
+		expectedSub []string
+		expectedErr string
+	}{
+		"first-failed-step": {
+			mode:     v1alpha1.WorkflowModeStep,
+			expected: []string{"step1", "group"},
+			// sub2 depends on the failed sub1 in the default dag mode of sub steps
+			expectedSub: []string{"sub3"},
+		},
+		"step-mode": {
+			mode:     v1alpha1.WorkflowModeStep,
+			step:     "group",
+			expected: []string{"step1"},
+		},
+		"dag-mode": {
+			mode:        v1alpha1.WorkflowModeDAG,
+			step:        "step1",
+			expected:    []string{"group"},
+			expectedSub: []string{"sub1", "sub2", "sub3"},
+		},
+		"not-found": {
+			mode:        v1alpha1.WorkflowModeStep,
+			step:        "not-found",
+			expectedErr: "step not-found is not found",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+			s := runtime.NewScheme()
+			r.NoError(scheme.AddToScheme(s))
+			r.NoError(v1alpha1.AddToScheme(s))
+			run := &v1alpha1.WorkflowRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+				Spec: v1alpha1.WorkflowRunSpec{
+					WorkflowSpec: &v1alpha1.WorkflowSpec{
+						Steps: []v1alpha1.WorkflowStep{step("step1"), group, step("step2", "step1")},
+					},
+				},
+				Status: v1alpha1.WorkflowRunStatus{
+					Mode:       v1alpha1.WorkflowExecuteMode{Steps: tc.mode, SubSteps: v1alpha1.WorkflowModeDAG},
+					Phase:      v1alpha1.WorkflowStateFailed,
+					Terminated: true,
+					Finished:   true,
+					Steps: []v1alpha1.WorkflowStepStatus{
+						stepStatus("step1", v1alpha1.WorkflowStepPhaseSucceeded),
+						stepStatus("group", v1alpha1.WorkflowStepPhaseFailed,
+							stepStatus("sub1", v1alpha1.WorkflowStepPhaseFailed),
+							stepStatus("sub2", v1alpha1.WorkflowStepPhaseSkipped),
+							stepStatus("sub3", v1alpha1.WorkflowStepPhaseSucceeded)),
+						stepStatus("step2", v1alpha1.WorkflowStepPhaseSkipped),
+					},
+					ExitHandlers: []v1alpha1.WorkflowStepStatus{stepStatus("notify", v1alpha1.WorkflowStepPhaseSucceeded)},
+				},
+			}
+			cli := fake.NewClientBuilder().WithScheme(s).WithObjects(run).Build()
+			err := RestartWorkflowFromStep(ctx, cli, run, tc.step)
+			if tc.expectedErr != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tc.expectedErr)
+				return
+			}
+			r.NoError(err)
+			updated := &v1alpha1.WorkflowRun{}
+			r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), updated))
+			r.False(updated.Status.Finished)
+			r.False(updated.Status.Terminated)
+			r.Equal(1, updated.Status.RestartCount)
+			r.Equal(v1alpha1.WorkflowStateExecuting, updated.Status.Phase)
+			r.Equal(0, len(updated.Status.ExitHandlers))
+			var steps, subSteps []string
+			for _, ss := range updated.Status.Steps {
+				steps = append(steps, ss.Name)
+				for _, sub := range ss.SubStepsStatus {
+					subSteps = append(subSteps, sub.Name)
+				}
+			}
+			r.Equal(tc.expected, steps)
+			r.Equal(tc.expectedSub, subSteps)
+		})
+	}
+}
+
+func TestRestartWorkflowFromNestedStep(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	r.NoError(scheme.AddToScheme(s))
+	r.NoError(v1alpha1.AddToScheme(s))
+	step := func(name string, subs ...v1alpha1.WorkflowStep) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: "apply"}, Mode: v1alpha1.WorkflowModeStep, SubSteps: subs}
+	}
+	stepStatus := func(name string, phase v1alpha1.WorkflowStepPhase, subs ...v1alpha1.WorkflowStepStatus) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{ID: name, Name: name, Phase: phase}, SubStepsStatus: subs}
+	}
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{
+					step("stage", step("env", step("cluster1"), step("cluster2")), step("check")),
+					step("notify"),
+				},
+			},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Mode:       v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeStep, SubSteps: v1alpha1.WorkflowModeDAG},
+			Phase:      v1alpha1.WorkflowStateFailed,
+			Terminated: true,
+			Finished:   true,
+			Steps: []v1alpha1.WorkflowStepStatus{
+				stepStatus("stage", v1alpha1.WorkflowStepPhaseFailed,
+					stepStatus("env", v1alpha1.WorkflowStepPhaseFailed,
+						stepStatus("cluster1", v1alpha1.WorkflowStepPhaseFailed),
+						stepStatus("cluster2", v1alpha1.WorkflowStepPhaseSkipped)),
+					stepStatus("check", v1alpha1.WorkflowStepPhaseSkipped)),
+				stepStatus("notify", v1alpha1.WorkflowStepPhaseSkipped),
+			},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(run).Build()
+	r.NoError(RestartWorkflowFromStep(ctx, cli, run, ""))
+	updated := &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKeyFromObject(run), updated))
+	// the groups in the path are re-executed, the steps after the restarted ones in step-by-step mode are reset
+	r.Equal(1, len(updated.Status.Steps))
+	stage := updated.Status.Steps[0]
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, stage.Phase)
+	r.Equal(1, len(stage.SubStepsStatus))
+	r.Equal("env", stage.SubStepsStatus[0].Name)
+	r.Equal(v1alpha1.WorkflowStepPhaseRunning, stage.SubStepsStatus[0].Phase)
+	r.Equal(0, len(stage.SubStepsStatus[0].SubStepsStatus))
+}
+
+func TestSubWorkflows(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	r.NoError(scheme.AddToScheme(s))
+	r.NoError(v1alpha1.AddToScheme(s))
+	step := func(name string) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: types.WorkflowStepTypeSubWorkflow}}
+	}
+	stepStatus := func(name string, phase v1alpha1.WorkflowStepPhase) v1alpha1.WorkflowStepStatus {
+		return v1alpha1.WorkflowStepStatus{StepStatus: v1alpha1.StepStatus{ID: name, Name: name, Type: types.WorkflowStepTypeSubWorkflow, Phase: phase}}
+	}
+	child := func(name string, finished bool, owner k8stypes.UID) *v1alpha1.WorkflowRun {
+		return &v1alpha1.WorkflowRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       k8stypes.UID(name + "-uid"),
+				Labels:    map[string]string{types.LabelParentWorkflowRunUID: string(owner)},
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.WorkflowRunKind, Name: "run", UID: owner, Controller: pointer.Bool(true)},
+				},
+			},
+			Status: v1alpha1.WorkflowRunStatus{Finished: finished},
+		}
+	}
+	run := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default", UID: "run-uid"},
+		Spec: v1alpha1.WorkflowRunSpec{
+			WorkflowSpec: &v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{step("sub1"), step("sub2"), step("sub3")}},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Mode:  v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeStep},
+			Phase: v1alpha1.WorkflowStateExecuting,
+			Steps: []v1alpha1.WorkflowStepStatus{
+				stepStatus("sub1", v1alpha1.WorkflowStepPhaseSucceeded),
+				stepStatus("sub2", v1alpha1.WorkflowStepPhaseRunning),
+				stepStatus("sub3", v1alpha1.WorkflowStepPhaseRunning),
+			},
+		},
+	}
+	// the workflow run with the colliding name "run-sub3" is created by another parent
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(run, child("run-sub1", true, run.UID), child("run-sub2", false, run.UID), child("run-sub3", false, "other-uid")).Build()
+
+	// the unfinished children are terminated with the parent
+	r.NoError(TerminateSubWorkflows(ctx, cli, run))
+	sub1, sub2, sub3 := &v1alpha1.WorkflowRun{}, &v1alpha1.WorkflowRun{}, &v1alpha1.WorkflowRun{}
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub1"}, sub1))
+	r.False(sub1.Status.Terminated)
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub2"}, sub2))
+	r.True(sub2.Status.Terminated)
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub3"}, sub3))
+	r.False(sub3.Status.Terminated)
+
+	// the children of the reset steps are deleted on restart, the others are left untouched
+	r.NoError(RestartWorkflowFromStep(ctx, cli, run, "sub2"))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub1"}, sub1))
+	r.True(kerrors.IsNotFound(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub2"}, sub2)))
+	r.NoError(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "run-sub3"}, sub3))
+}
+
+func TestResumeWorkflowStep(t *testing.T) {
+	suspendStep := func(name string) v1alpha1.StepStatus {
+		return v1alpha1.StepStatus{ID: name + "-id", Name: name, Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning}
+	}
+	testCases := map[string]struct {
+		resume   v1alpha1.StepResume
+		stored   bool
+		expected string
+	}{
+		"resume-by-name": {
+			resume:   v1alpha1.StepResume{Name: "step1", Approver: "admin"},
+			stored:   true,
+			expected: "step1-id",
+		},
+		"reject-sub-step-by-id": {
+			resume:   v1alpha1.StepResume{Name: "sub1-id", Reject: true},
+			stored:   true,
+			expected: "sub1-id",
+		},
+		"already-stored": {
+			resume: v1alpha1.StepResume{Name: "step2"},
+		},
+		"not-suspend-step": {
+			resume: v1alpha1.StepResume{Name: "group"},
+		},
+		"not-found": {
+			resume: v1alpha1.StepResume{Name: "not-found"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+			run := &v1alpha1.WorkflowRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+				Status: v1alpha1.WorkflowRunStatus{
+					Suspend: true,
+					Steps: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: suspendStep("step1")},
+						{StepStatus: suspendStep("step2")},
+						{
+							StepStatus:     v1alpha1.StepStatus{ID: "group-id", Name: "group", Type: "step-group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+							SubStepsStatus: []v1alpha1.WorkflowStepStatus{{StepStatus: suspendStep("sub1")}},
+						},
+					},
+				},
+			}
+			cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "workflow-run-context", Namespace: "default"},
+				Data:       map[string]string{"resume.step2-id": `{"name":"step2"}`},
+			}).Build()
+			stored, err := ResumeWorkflowStep(ctx, cli, run, tc.resume)
+			r.NoError(err)
+			r.Equal(tc.stored, stored)
+			if !tc.stored {
+				return
+			}
+			cm := &corev1.ConfigMap{}
+			r.NoError(cli.Get(ctx, client.ObjectKey{Name: "workflow-run-context", Namespace: "default"}, cm))
+			resume := v1alpha1.StepResume{}
+			r.NoError(json.Unmarshal([]byte(cm.Data["resume."+tc.expected]), &resume))
+			r.Equal(tc.resume, resume)
+		})
+	}
+}
+
+func TestClearSpecControls(t *testing.T) {
+	r := require.New(t)
+	run := &v1alpha1.WorkflowRun{
+		Spec: v1alpha1.WorkflowRunSpec{
+			Suspend:     true,
+			Terminate:   true,
+			ResumeSteps: []v1alpha1.StepResume{{Name: "step1"}, {Name: "step2", Reject: true}},
+		},
+		Status: v1alpha1.WorkflowRunStatus{
+			Steps: []v1alpha1.WorkflowStepStatus{
+				{StepStatus: v1alpha1.StepStatus{ID: "step1-id", Name: "step1", Type: "suspend", Phase: v1alpha1.WorkflowStepPhaseRunning}},
+			},
+		},
+	}
+	ClearSpecControls(run)
+	r.False(run.Spec.Suspend)
+	r.False(run.Spec.Terminate)
+	// the decision of the step reset by the restart is consumed
+	r.Equal([]v1alpha1.StepResume{{Name: "step1"}}, run.Spec.ResumeSteps)
+}
+
+func TestContinueWorkflowStep(t *testing.T) {
+	pausedStep := func(name string) v1alpha1.StepStatus {
+		return v1alpha1.StepStatus{ID: name + "-id", Name: name, Type: "apply-object", Phase: v1alpha1.WorkflowStepPhaseRunning, Reason: types.StatusReasonBreakpoint}
+	}
+	testCases := map[string]struct {
+		decision types.StepContinue
+		stored   bool
+		expected string
+	}{
+		"continue-by-name": {
+			decision: types.StepContinue{Name: "step1", Parameter: &runtime.RawExtension{Raw: []byte(`{"replicas":2}`)}},
+			stored:   true,
+			expected: "step1-id",
+		},
+		"skip-sub-step-by-id": {
+			decision: types.StepContinue{Name: "sub1-id", Skip: true},
+			stored:   true,
+			expected: "sub1-id",
+		},
+		"already-stored": {
+			decision: types.StepContinue{Name: "step2"},
+		},
+		"not-paused": {
+			decision: types.StepContinue{Name: "group"},
+		},
+		"not-found": {
+			decision: types.StepContinue{Name: "not-found"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+			run := &v1alpha1.WorkflowRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+				Status: v1alpha1.WorkflowRunStatus{
+					Steps: []v1alpha1.WorkflowStepStatus{
+						{StepStatus: pausedStep("step1")},
+						{StepStatus: pausedStep("step2")},
+						{
+							StepStatus:     v1alpha1.StepStatus{ID: "group-id", Name: "group", Type: "step-group", Phase: v1alpha1.WorkflowStepPhaseRunning},
+							SubStepsStatus: []v1alpha1.WorkflowStepStatus{{StepStatus: pausedStep("sub1")}},
+						},
+					},
+				},
+			}
+			cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "workflow-run-context", Namespace: "default"},
+				Data:       map[string]string{"continue.step2-id": `{"name":"step2"}`},
+			}).Build()
+			stored, err := ContinueWorkflowStep(ctx, cli, run, tc.decision)
+			r.NoError(err)
+			r.Equal(tc.stored, stored)
+			if !tc.stored {
+				return
+			}
+			cm := &corev1.ConfigMap{}
+			r.NoError(cli.Get(ctx, client.ObjectKey{Name: "workflow-run-context", Namespace: "default"}, cm))
+			decision := types.StepContinue{}
+			r.NoError(json.Unmarshal([]byte(cm.Data["continue."+tc.expected]), &decision))
+			r.Equal(tc.decision, decision)
+		})
+	}
+}
+
+func TestGetPodListFromResources(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	pod := func(name, ns string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels}}
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		pod("named", "default", nil),
+		pod("labeled", "default", map[string]string{"app": "test"}),
+		pod("labeled", "other", map[string]string{"app": "test"}),
+	).Build()
+	pods, err := GetPodListFromResources(ctx, cli, []types.Resource{
+		{Name: "named", Namespace: "default"},
+		{Namespace: "default", LabelSelector: map[string]string{"app": "test"}},
+	})
+	r.NoError(err)
+	r.Equal(2, len(pods))
+	r.Equal("named", pods[0].Name)
+	r.Equal("labeled", pods[1].Name)
+	r.Equal("default", pods[1].Namespace)
+
+	_, err = GetPodListFromResources(ctx, cli, []types.Resource{{Name: "not-found", Namespace: "default"}})
+	r.Error(err)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakeup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+var (
+	// InformerSyncTimeout is the timeout to start the informer of an object, the informer is stopped if it times
+	// out, e.g. the controller is not allowed to watch the kind, and it's started again in the next watch.
+	InformerSyncTimeout = time.Minute
+	// EventBufferSize is the buffer size of the events to wake up the workflow runs, the events are dropped if the
+	// buffer is full and the workflow runs are woken up by the backoff instead
+	EventBufferSize = 1024
+)
+
+type objectKey struct {
+	gvk schema.GroupVersionKind
+	key ktypes.NamespacedName
+}
+
+// objectInformer is the informer of a watched object
+type objectInformer struct {
+	stop context.CancelFunc
+}
+
+// Watcher wakes up the workflow runs when the objects read by their waiting steps are changed. Each watched object
+// is watched by an informer of its metadata selected by its name, the informer is started when the object is
+// watched by the first workflow run and stopped when it's not watched by any workflow run.
+type Watcher struct {
+	client metadata.Interface
+	mapper meta.RESTMapper
+	events chan event.GenericEvent
+
+	mu sync.Mutex
+	// informers records the informers of the watched objects
+	informers map[objectKey]*objectInformer
+	// runs records the workflow runs waiting for the object
+	runs map[objectKey]map[ktypes.NamespacedName]bool
+	// objects records the objects watched by the workflow run
+	objects map[ktypes.NamespacedName][]objectKey
+}
+
+// New creates the watcher which watches the metadata of the objects by the client, the resources of the kinds of
+// the objects are found by the mapper
+func New(client metadata.Interface, mapper meta.RESTMapper) *Watcher {
+	return &Watcher{
+		client:    client,
+		mapper:    mapper,
+		events:    make(chan event.GenericEvent, EventBufferSize),
+		informers: make(map[objectKey]*objectInformer),
+		runs:      make(map[objectKey]map[ktypes.NamespacedName]bool),
+		objects:   make(map[ktypes.NamespacedName][]objectKey),
+	}
+}
+
+// Source returns the source of the events to wake up the workflow runs, the events only have the name and
+// namespace of the workflow runs.
+func (w *Watcher) Source() source.Source {
+	return &source.Channel{Source: w.events}
+}
+
+// Watch replaces the objects watched by the workflow run, the run is unwatched if there is no object.
+func (w *Watcher) Watch(run ktypes.NamespacedName, objects []wfContext.WatchedObject) {
+	keys := make([]objectKey, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, objectKey{
+			gvk: schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind),
+			key: ktypes.NamespacedName{Namespace: obj.Namespace, Name: obj.Name},
+		})
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// the new objects are watched before the old ones are unwatched, so that the informers of the objects watched
+	// in both are not restarted
+	for _, key := range keys {
+		if w.runs[key] == nil {
+			w.runs[key] = make(map[ktypes.NamespacedName]bool)
+		}
+		w.runs[key][run] = true
+		if w.informers[key] == nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			informer := &objectInformer{stop: cancel}
+			w.informers[key] = informer
+			go w.runInformer(ctx, key, informer)
+		}
+	}
+	w.unwatch(run, keys)
+	if len(keys) > 0 {
+		w.objects[run] = keys
+	}
+}
+
+// Unwatch stops watching the objects of the workflow run
+func (w *Watcher) Unwatch(run ktypes.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unwatch(run, nil)
+}
+
+// unwatch stops watching the objects of the workflow run except the kept ones, the informers of the objects are
+// stopped if they are not watched by any workflow run.
+func (w *Watcher) unwatch(run ktypes.NamespacedName, kept []objectKey) {
+	keep := make(map[objectKey]bool, len(kept))
+	for _, key := range kept {
+		keep[key] = true
+	}
+	for _, key := range w.objects[run] {
+		if keep[key] {
+			continue
+		}
+		delete(w.runs[key], run)
+		if len(w.runs[key]) == 0 {
+			delete(w.runs, key)
+			if informer := w.informers[key]; informer != nil {
+				informer.stop()
+				delete(w.informers, key)
+			}
+		}
+	}
+	delete(w.objects, run)
+}
+
+// runInformer runs the informer of the object until the context is done. The object listed by the informer is
+// handled as an added one, so that the changes before the informer is started are not missed.
+func (w *Watcher) runInformer(ctx context.Context, key objectKey, informer *objectInformer) {
+	mapping, err := w.mapper.RESTMapping(key.gvk.GroupKind(), key.gvk.Version)
+	if err != nil {
+		klog.ErrorS(err, "Failed to find the resource of the object watched by waiting steps", "kind", key.gvk, "object", key.key)
+		w.removeInformer(key, informer)
+		return
+	}
+	namespace := key.key.Namespace
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		namespace = metav1.NamespaceAll
+	}
+	shared := metadatainformer.NewFilteredMetadataInformer(w.client, mapping.Resource, namespace, 0, toolscache.Indexers{}, func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", key.key.Name).String()
+	}).Informer()
+	shared.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.wakeUp(key, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok := oldObj.(metav1.Object)
+			n, ok2 := newObj.(metav1.Object)
+			// skip the resync of the informer
+			if ok && ok2 && o.GetResourceVersion() == n.GetResourceVersion() {
+				return
+			}
+			w.wakeUp(key, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			w.wakeUp(key, obj)
+		},
+	})
+	go shared.Run(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, InformerSyncTimeout)
+	defer cancel()
+	if !toolscache.WaitForCacheSync(syncCtx.Done(), shared.HasSynced) && ctx.Err() == nil {
+		klog.ErrorS(syncCtx.Err(), "Failed to start the informer to watch the object of waiting steps", "kind", key.gvk, "object", key.key)
+		w.removeInformer(key, informer)
+	}
+}
+
+// removeInformer stops the informer which fails to start, so that it's started again in the next watch
+func (w *Watcher) removeInformer(key objectKey, informer *objectInformer) {
+	informer.stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.informers[key] == informer {
+		delete(w.informers, key)
+	}
+}
+
+// wakeUp enqueues the workflow runs waiting for the object, the events are dropped if the buffer is full so that
+// the informers are not blocked
+func (w *Watcher) wakeUp(key objectKey, obj interface{}) {
+	if o, ok := obj.(metav1.Object); !ok || o.GetName() != key.key.Name {
+		return
+	}
+	w.mu.Lock()
+	runs := make([]ktypes.NamespacedName, 0, len(w.runs[key]))
+	for run := range w.runs[key] {
+		runs = append(runs, run)
+	}
+	w.mu.Unlock()
+	for _, run := range runs {
+		e := event.GenericEvent{Object: &v1alpha1.WorkflowRun{ObjectMeta: metav1.ObjectMeta{Namespace: run.Namespace, Name: run.Name}}}
+		select {
+		case w.events <- e:
+			klog.V(4).InfoS("Wake up the workflow run by the change of the watched object", "workflowrun", run, "kind", key.gvk, "object", key.key)
+		default:
+			klog.V(4).InfoS("Drop the event to wake up the workflow run since the buffer is full", "workflowrun", run, "kind", key.gvk, "object", key.key)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakeup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	wfContext "github.com/kubevela/workflow/pkg/context"
+)
+
+func newDeployment(name, resourceVersion string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: resourceVersion},
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	r := require.New(t)
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+	scheme := runtime.NewScheme()
+	r.NoError(metav1.AddMetaToScheme(scheme))
+	cli := fake.NewSimpleMetadataClient(scheme, newDeployment("app", "1"), newDeployment("other", "1"))
+	deployments := cli.Resource(gvr).Namespace("default").(fake.MetadataClient)
+
+	w := New(cli, mapper)
+	run := ktypes.NamespacedName{Namespace: "default", Name: "run"}
+	another := ktypes.NamespacedName{Namespace: "default", Name: "another"}
+	objects := []wfContext.WatchedObject{{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app"}}
+	w.Watch(run, objects)
+
+	// the existing object is listed after the informer is started
+	r.Eventually(func() bool { return len(w.events) == 1 }, 5*time.Second, 10*time.Millisecond)
+	e := <-w.events
+	r.Equal("run", e.Object.GetName())
+	r.Equal("default", e.Object.GetNamespace())
+
+	// the informer of the object is shared by the workflow runs
+	w.Watch(another, objects)
+	r.Equal(1, len(w.informers))
+	_, err := deployments.UpdateFake(newDeployment("other", "2"), metav1.UpdateOptions{})
+	r.NoError(err)
+	_, err = deployments.UpdateFake(newDeployment("app", "2"), metav1.UpdateOptions{})
+	r.NoError(err)
+	r.Eventually(func() bool { return len(w.events) == 2 }, 5*time.Second, 10*time.Millisecond)
+	<-w.events
+	<-w.events
+
+	// the informer is stopped once the object is not watched by any workflow run
+	w.Unwatch(another)
+	r.Equal(1, len(w.informers))
+	w.Watch(run, nil)
+	r.Empty(w.informers)
+	r.Empty(w.runs)
+	r.Empty(w.objects)
+	r.NoError(deployments.Delete(context.Background(), "app", metav1.DeleteOptions{}))
+	time.Sleep(100 * time.Millisecond)
+	r.Equal(0, len(w.events))
+}
+
+func TestWakeUpNotBlocked(t *testing.T) {
+	r := require.New(t)
+	w := New(nil, nil)
+	w.events = make(chan event.GenericEvent, 1)
+	key := objectKey{
+		gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		key: ktypes.NamespacedName{Namespace: "default", Name: "app"},
+	}
+	w.runs[key] = map[ktypes.NamespacedName]bool{{Namespace: "default", Name: "a"}: true, {Namespace: "default", Name: "b"}: true}
+	// the events which exceed the buffer are dropped
+	w.wakeUp(key, newDeployment("app", "1"))
+	r.Equal(1, len(w.events))
+	w.wakeUp(key, newDeployment("other", "1"))
+	r.Equal(1, len(w.events))
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+)
+
+// CronWorkflowValidatingHandler validates the CronWorkflow
+type CronWorkflowValidatingHandler struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+var _ admission.Handler = &CronWorkflowValidatingHandler{}
+
+// Handle validates the run template of the cron workflow on creation and update
+func (h *CronWorkflowValidatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.ValidationResponse(true, "")
+	}
+	cron := &v1alpha1.CronWorkflow{}
+	if err := h.Decoder.Decode(req, cron); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if errs := h.Validate(ctx, cron, req.UserInfo); len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.ValidationResponse(true, "")
+}
+
+// Validate validates the spec of the workflow runs created by the cron workflow, the runs are created by the
+// controller, so the user of the cron workflow must be able to get the secrets in the other namespaces which are
+// referenced by the inputs.
+func (h *CronWorkflowValidatingHandler) Validate(ctx context.Context, cron *v1alpha1.CronWorkflow, user authenticationv1.UserInfo) field.ErrorList {
+	return validateRunSpec(ctx, h.Client, &cron.Spec.RunTemplate.Spec, cron.Namespace, user, field.NewPath("spec", "runTemplate", "spec"))
+}
+
+var _ inject.Client = &CronWorkflowValidatingHandler{}
+
+// InjectClient injects the client into the CronWorkflowValidatingHandler
+func (h *CronWorkflowValidatingHandler) InjectClient(c client.Client) error {
+	h.Client = c
+	return nil
+}
+
+var _ admission.DecoderInjector = &CronWorkflowValidatingHandler{}
+
+// InjectDecoder injects the decoder into the CronWorkflowValidatingHandler
+func (h *CronWorkflowValidatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.Decoder = d
+	return nil
+}
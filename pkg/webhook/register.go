@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// WorkflowRunValidatingPath is the path of the validating webhook of WorkflowRun
+	WorkflowRunValidatingPath = "/validating-core-oam-dev-v1alpha1-workflowruns"
+	// WorkflowValidatingPath is the path of the validating webhook of Workflow
+	WorkflowValidatingPath = "/validating-core-oam-dev-v1alpha1-workflows"
+	// CronWorkflowValidatingPath is the path of the validating webhook of CronWorkflow
+	CronWorkflowValidatingPath = "/validating-core-oam-dev-v1alpha1-cronworkflows"
+	// WorkflowRunMutatingPath is the path of the mutating webhook of WorkflowRun
+	WorkflowRunMutatingPath = "/mutating-core-oam-dev-v1alpha1-workflowruns"
+)
+
+// Register registers the validating webhooks of Workflow, WorkflowRun and CronWorkflow and the mutating webhook of
+// WorkflowRun to the webhook server of the manager
+func Register(mgr manager.Manager) {
+	server := mgr.GetWebhookServer()
+	server.Register(WorkflowRunValidatingPath, &crwebhook.Admission{Handler: &WorkflowRunValidatingHandler{}})
+	server.Register(WorkflowValidatingPath, &crwebhook.Admission{Handler: &WorkflowValidatingHandler{}})
+	server.Register(CronWorkflowValidatingPath, &crwebhook.Admission{Handler: &CronWorkflowValidatingHandler{}})
+	server.Register(WorkflowRunMutatingPath, &crwebhook.Admission{Handler: &WorkflowRunMutatingHandler{}})
+}
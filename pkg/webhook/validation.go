@@ -0,0 +1,482 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/tasks/builtin"
+	"github.com/kubevela/workflow/pkg/tasks/template"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+var builtinStepTypes = map[string]bool{
+	types.WorkflowStepTypeSuspend:     true,
+	types.WorkflowStepTypeStepGroup:   true,
+	types.WorkflowStepTypeForeach:     true,
+	types.WorkflowStepTypeSubWorkflow: true,
+}
+
+// SpecValidator validates the steps of the workflow spec, the types of the steps are resolved through the template
+// loader in the namespace of the workflow.
+type SpecValidator struct {
+	loader    template.Loader
+	namespace string
+	mode      v1alpha1.WorkflowExecuteMode
+	// vars is the variables which can be referenced by the inputs, the inputs are not validated if it's nil
+	vars map[string]bool
+	// types caches the errors of the type resolution
+	types map[string]error
+	// authorizer reviews the access of the user to the secrets in the other namespaces, the access is not reviewed
+	// if it's nil
+	authorizer client.Client
+	user       authenticationv1.UserInfo
+	// secrets caches the reviewed access to the secrets
+	secrets map[string]bool
+}
+
+// NewSpecValidator creates the validator of the workflow spec
+func NewSpecValidator(loader template.Loader, namespace string) *SpecValidator {
+	return &SpecValidator{
+		loader:    loader,
+		namespace: namespace,
+		mode:      v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeStep, SubSteps: v1alpha1.WorkflowModeDAG},
+		types:     make(map[string]error),
+	}
+}
+
+// WithMode sets the execute mode of the steps, the unset modes keep the default ones.
+func (v *SpecValidator) WithMode(mode *v1alpha1.WorkflowExecuteMode) *SpecValidator {
+	if mode != nil && mode.Steps != "" {
+		v.mode.Steps = mode.Steps
+	}
+	if mode != nil && mode.SubSteps != "" {
+		v.mode.SubSteps = mode.SubSteps
+	}
+	return v
+}
+
+// WithInputs enables the validation of the inputs, the inputs can reference the outputs of the earlier steps and
+// the variables in the initial context.
+func (v *SpecValidator) WithInputs(vars map[string]bool) *SpecValidator {
+	v.vars = make(map[string]bool)
+	for k := range vars {
+		v.vars[k] = true
+	}
+	return v
+}
+
+// WithUser enables the review of the inputs from the secrets in the other namespaces, the secrets are read by the
+// controller, so the user must be able to get them.
+func (v *SpecValidator) WithUser(authorizer client.Client, user authenticationv1.UserInfo) *SpecValidator {
+	v.authorizer = authorizer
+	v.user = user
+	v.secrets = make(map[string]bool)
+	return v
+}
+
+// Validate validates the workflow spec
+func (v *SpecValidator) Validate(ctx context.Context, spec *v1alpha1.WorkflowSpec, path *field.Path) field.ErrorList {
+	ctx = types.SetNamespaceInCtx(ctx, v.namespace)
+	errs := validateStepNames(spec, path)
+	errs = append(errs, v.validateSteps(ctx, spec.Steps, v.mode.Steps, path.Child("steps"))...)
+	// the exit handlers run one by one after the workflow steps, and they can't reference the outputs of each other
+	vars := v.vars
+	for _, handler := range getExitHandlers(spec)[1:] {
+		if vars != nil {
+			v.WithInputs(vars)
+		}
+		errs = append(errs, v.validateSteps(ctx, handler.steps, v1alpha1.WorkflowModeStep, path.Child(handler.name))...)
+	}
+	return errs
+}
+
+type namedSteps struct {
+	name  string
+	steps []v1alpha1.WorkflowStep
+}
+
+// getExitHandlers returns the workflow steps followed by the exit handlers
+func getExitHandlers(spec *v1alpha1.WorkflowSpec) []namedSteps {
+	return []namedSteps{
+		{name: "steps", steps: spec.Steps},
+		{name: "onSuccess", steps: spec.OnSuccess},
+		{name: "onFailure", steps: spec.OnFailure},
+		{name: "finally", steps: spec.Finally},
+	}
+}
+
+// validateStepNames checks that the names of the steps and the exit handlers are unique in any level, since the
+// exit handlers read the status of the workflow steps and the outcome of the workflow by the names.
+func validateStepNames(spec *v1alpha1.WorkflowSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]bool)
+	hasExitHandlers := len(spec.OnSuccess)+len(spec.OnFailure)+len(spec.Finally) > 0
+	check := func(name string, p *field.Path) {
+		if name == "" {
+			errs = append(errs, field.Required(p, "the name of the step is required"))
+			return
+		}
+		if hasExitHandlers && name == types.StatusKeyWorkflow {
+			errs = append(errs, field.Invalid(p, name, "the name is reserved for the outcome of the workflow read by the exit handlers"))
+			return
+		}
+		if names[name] {
+			errs = append(errs, field.Duplicate(p, name))
+		}
+		names[name] = true
+	}
+	var checkSteps func(steps []v1alpha1.WorkflowStep, p *field.Path)
+	checkSteps = func(steps []v1alpha1.WorkflowStep, p *field.Path) {
+		for i, step := range steps {
+			check(step.Name, p.Index(i).Child("name"))
+			checkSteps(step.SubSteps, p.Index(i).Child("subSteps"))
+		}
+	}
+	for _, list := range getExitHandlers(spec) {
+		checkSteps(list.steps, path.Child(list.name))
+	}
+	return errs
+}
+
+func (v *SpecValidator) validateSteps(ctx context.Context, steps []v1alpha1.WorkflowStep, mode v1alpha1.WorkflowMode, path *field.Path) field.ErrorList {
+	errs := validateDependencies(steps, path)
+	errs = append(errs, v.validateStepList(ctx, steps, mode, path)...)
+	return errs
+}
+
+// validateStepList validates the steps in the same level, the sub steps of the step groups are validated recursively
+func (v *SpecValidator) validateStepList(ctx context.Context, steps []v1alpha1.WorkflowStep, mode v1alpha1.WorkflowMode, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if mode == v1alpha1.WorkflowModeDAG {
+		// the steps in dag mode wait for the inputs, so that the outputs of all the steps can be referenced
+		types.RangeSteps(steps, func(step v1alpha1.WorkflowStep) {
+			v.addOutputs(step.WorkflowStepBase)
+		})
+	}
+	for i, step := range steps {
+		p := path.Index(i)
+		errs = append(errs, v.validateStep(ctx, step.WorkflowStepBase, p)...)
+		switch {
+		case step.Type == types.WorkflowStepTypeStepGroup:
+			subMode := v.mode.SubSteps
+			if step.Mode != "" {
+				subMode = step.Mode
+				if err := validateWorkflowMode(step.Mode, p.Child("mode")); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			errs = append(errs, v.validateStepList(ctx, step.SubSteps, subMode, p.Child("subSteps"))...)
+		case len(step.SubSteps) > 0:
+			errs = append(errs, field.Forbidden(p.Child("subSteps"), "only the step-group step can have sub steps"))
+		case step.Mode != "":
+			errs = append(errs, field.Forbidden(p.Child("mode"), "only the step-group step can have the mode of sub steps"))
+		}
+		v.addOutputs(step.WorkflowStepBase)
+	}
+	return errs
+}
+
+func (v *SpecValidator) addOutputs(step v1alpha1.WorkflowStepBase) {
+	if v.vars == nil {
+		return
+	}
+	for _, output := range step.Outputs {
+		v.vars[output.Name] = true
+	}
+}
+
+func (v *SpecValidator) validateStep(ctx context.Context, step v1alpha1.WorkflowStepBase, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := v.validateType(ctx, step.Type, path.Child("type")); err != nil {
+		errs = append(errs, err)
+	}
+	if step.Timeout != "" {
+		if _, err := time.ParseDuration(step.Timeout); err != nil {
+			errs = append(errs, field.Invalid(path.Child("timeout"), step.Timeout, err.Error()))
+		}
+	}
+	if step.Retry != nil {
+		if _, err := time.ParseDuration(step.Retry.Delay); step.Retry.Delay != "" && err != nil {
+			errs = append(errs, field.Invalid(path.Child("retry", "delay"), step.Retry.Delay, err.Error()))
+		}
+		if _, err := time.ParseDuration(step.Retry.MaxDelay); step.Retry.MaxDelay != "" && err != nil {
+			errs = append(errs, field.Invalid(path.Child("retry", "maxDelay"), step.Retry.MaxDelay, err.Error()))
+		}
+	}
+	if step.Cache != nil && step.Cache.TTL != "" {
+		if _, err := time.ParseDuration(step.Cache.TTL); err != nil {
+			errs = append(errs, field.Invalid(path.Child("cache", "ttl"), step.Cache.TTL, err.Error()))
+		}
+	}
+	durationFromInput := false
+	for i, input := range step.Inputs {
+		inputPath := path.Child("inputs").Index(i)
+		if input.FromSecret != nil {
+			errs = append(errs, validateInputFromSecret(step.Type, input.ParameterKey, input.From, input.FromSecret, inputPath)...)
+			if err := v.reviewSecretAccess(ctx, input.FromSecret, inputPath.Child("fromSecret", "namespace")); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if input.ParameterKey == "duration" {
+			durationFromInput = true
+		}
+		if input.From == "" {
+			errs = append(errs, field.Required(inputPath.Child("from"), "either from or fromSecret is required"))
+			continue
+		}
+		if v.vars != nil && !v.hasVar(input.From) {
+			errs = append(errs, field.Invalid(inputPath.Child("from"), input.From,
+				"not provided by the outputs of the earlier steps or the context"))
+		}
+	}
+	if step.Type == types.WorkflowStepTypeSuspend && !durationFromInput {
+		if _, err := builtin.GetSuspendStepDurationWaiting(v1alpha1.WorkflowStep{WorkflowStepBase: step}); err != nil {
+			errs = append(errs, field.Invalid(path.Child("properties", "duration"), string(step.Properties.Raw), err.Error()))
+		}
+	}
+	return errs
+}
+
+func validateInputFromSecret(typ, parameterKey, from string, selector *v1alpha1.SecretKeySelector, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if from != "" {
+		errs = append(errs, field.Invalid(path.Child("from"), from, "from and fromSecret are mutually exclusive"))
+	}
+	if builtinStepTypes[typ] {
+		errs = append(errs, field.Invalid(path.Child("fromSecret"), selector.Name,
+			fmt.Sprintf("the inputs from secrets are not supported by the %s step", typ)))
+	}
+	if parameterKey == "" {
+		errs = append(errs, field.Required(path.Child("parameterKey"), "the parameter key of the input from secret is required"))
+	}
+	if selector.Name == "" {
+		errs = append(errs, field.Required(path.Child("fromSecret", "name"), "the name of the secret is required"))
+	}
+	if selector.Key == "" {
+		errs = append(errs, field.Required(path.Child("fromSecret", "key"), "the key of the secret is required"))
+	}
+	return errs
+}
+
+// reviewSecretAccess checks whether the user can get the secret in the other namespace by the SubjectAccessReview
+func (v *SpecValidator) reviewSecretAccess(ctx context.Context, selector *v1alpha1.SecretKeySelector, path *field.Path) *field.Error {
+	if v.authorizer == nil || selector.Namespace == "" || selector.Namespace == v.namespace {
+		return nil
+	}
+	key := selector.Namespace + "/" + selector.Name
+	allowed, ok := v.secrets[key]
+	if !ok {
+		extra := make(map[string]authorizationv1.ExtraValue, len(v.user.Extra))
+		for k, val := range v.user.Extra {
+			extra[k] = authorizationv1.ExtraValue(val)
+		}
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   v.user.Username,
+				Groups: v.user.Groups,
+				UID:    v.user.UID,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: selector.Namespace,
+					Verb:      "get",
+					Resource:  "secrets",
+					Name:      selector.Name,
+				},
+			},
+		}
+		if err := v.authorizer.Create(ctx, review); err != nil {
+			return field.InternalError(path, errors.WithMessagef(err, "review the access to secret %s", key))
+		}
+		allowed = review.Status.Allowed
+		v.secrets[key] = allowed
+	}
+	if !allowed {
+		return field.Forbidden(path, fmt.Sprintf("user %s can't get secret %s", v.user.Username, key))
+	}
+	return nil
+}
+
+func (v *SpecValidator) hasVar(from string) bool {
+	for name := range v.vars {
+		if from == name || strings.HasPrefix(from, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *SpecValidator) validateType(ctx context.Context, typ string, path *field.Path) *field.Error {
+	if typ == "" {
+		return field.Required(path, "the type of the step is required")
+	}
+	if builtinStepTypes[typ] {
+		return nil
+	}
+	err, ok := v.types[typ]
+	if !ok {
+		_, err = v.loader.LoadTemplate(ctx, typ)
+		v.types[typ] = err
+	}
+	switch {
+	case err == nil:
+		return nil
+	case kerrors.IsNotFound(err):
+		return field.Invalid(path, typ, "unknown step type")
+	default:
+		return field.InternalError(path, err)
+	}
+}
+
+// validateDependencies checks that the steps depend on the existing steps in any level without cycles, the sub
+// steps can depend on the steps outside of their step groups. The step group waits for its sub steps, so it
+// implicitly depends on them in the check of the cycles.
+func validateDependencies(steps []v1alpha1.WorkflowStep, path *field.Path) field.ErrorList {
+	type node struct {
+		name      string
+		path      *field.Path
+		dependsOn []string
+		edges     []string
+	}
+	var nodes []node
+	var flatten func(steps []v1alpha1.WorkflowStep, path *field.Path)
+	flatten = func(steps []v1alpha1.WorkflowStep, path *field.Path) {
+		for i, step := range steps {
+			n := node{name: step.Name, path: path.Index(i), dependsOn: step.DependsOn}
+			n.edges = append(n.edges, step.DependsOn...)
+			for _, sub := range step.SubSteps {
+				n.edges = append(n.edges, sub.Name)
+			}
+			nodes = append(nodes, n)
+			flatten(step.SubSteps, path.Index(i).Child("subSteps"))
+		}
+	}
+	flatten(steps, path)
+
+	var errs field.ErrorList
+	index := make(map[string]int)
+	for i, n := range nodes {
+		index[n.name] = i
+	}
+	for _, n := range nodes {
+		for j, dep := range n.dependsOn {
+			if _, ok := index[dep]; !ok {
+				errs = append(errs, field.NotFound(n.path.Child("dependsOn").Index(j), dep))
+			}
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(nodes))
+	var stack []string
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		state[i] = visiting
+		stack = append(stack, nodes[i].name)
+		for _, dep := range nodes[i].edges {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			if state[j] == visiting {
+				cycle := append(stack[indexOf(stack, dep):], dep)
+				errs = append(errs, field.Invalid(nodes[j].path.Child("dependsOn"), nodes[j].dependsOn,
+					fmt.Sprintf("dependency cycle: %s", strings.Join(cycle, " -> "))))
+				return true
+			}
+			if state[j] == unvisited && visit(j) {
+				return true
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		return false
+	}
+	for i := range nodes {
+		if state[i] == unvisited && visit(i) {
+			break
+		}
+	}
+	return errs
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// validateMode checks the execute mode of the steps and sub steps
+func validateMode(mode *v1alpha1.WorkflowExecuteMode, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if mode == nil {
+		return errs
+	}
+	if err := validateWorkflowMode(mode.Steps, path.Child("steps")); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateWorkflowMode(mode.SubSteps, path.Child("subSteps")); err != nil {
+		errs = append(errs, err)
+	}
+	if mode.MaxParallelism < 0 {
+		errs = append(errs, field.Invalid(path.Child("maxParallelism"), mode.MaxParallelism, "must be greater than or equal to 0"))
+	}
+	return errs
+}
+
+func validateWorkflowMode(mode v1alpha1.WorkflowMode, path *field.Path) *field.Error {
+	if mode == "" || mode == v1alpha1.WorkflowModeDAG || mode == v1alpha1.WorkflowModeStep {
+		return nil
+	}
+	return field.NotSupported(path, mode, []string{string(v1alpha1.WorkflowModeDAG), string(v1alpha1.WorkflowModeStep)})
+}
+
+// getContextVars returns the top level variables in the initial context
+func getContextVars(raw *runtime.RawExtension, path *field.Path) (map[string]bool, *field.Error) {
+	vars := make(map[string]bool)
+	if raw == nil || len(raw.Raw) == 0 {
+		return vars, nil
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(raw.Raw, &m); err != nil {
+		return nil, field.Invalid(path, string(raw.Raw), "the context must be an object")
+	}
+	for k := range m {
+		vars[k] = true
+	}
+	return vars, nil
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+type testLoader struct{}
+
+func (l *testLoader) LoadTemplate(_ context.Context, name string) (string, error) {
+	if name == "apply" {
+		return "", nil
+	}
+	return "", kerrors.NewNotFound(schema.GroupResource{Resource: "workflowstepdefinitions"}, name)
+}
+
+func TestSpecValidator(t *testing.T) {
+	step := func(name, typ string, dependsOn ...string) v1alpha1.WorkflowStep {
+		return v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: name, Type: typ, DependsOn: dependsOn}}
+	}
+	withInputs := func(s v1alpha1.WorkflowStep, from ...string) v1alpha1.WorkflowStep {
+		for _, f := range from {
+			s.Inputs = append(s.Inputs, v1alpha1.StepInputs{{From: f, ParameterKey: "p"}}...)
+		}
+		return s
+	}
+	withOutputs := func(s v1alpha1.WorkflowStep, names ...string) v1alpha1.WorkflowStep {
+		for _, n := range names {
+			s.Outputs = append(s.Outputs, v1alpha1.StepOutputs{{Name: n, ValueFrom: n}}...)
+		}
+		return s
+	}
+	group := step("group", "step-group")
+	group.SubSteps = []v1alpha1.WorkflowStep{
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "sub1", Type: "apply", DependsOn: []string{"sub2"}}},
+		{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "sub2", Type: "apply", DependsOn: []string{"sub1"}}},
+	}
+	nested := step("stage", "step-group")
+	nested.Mode = v1alpha1.WorkflowModeStep
+	env := step("env", "step-group")
+	env.Mode = "Parallel"
+	env.SubSteps = []v1alpha1.WorkflowStep{step("cluster1", "apply", "prepare"), step("cluster2", "apply", "stage")}
+	notGroup := step("cluster3", "apply")
+	notGroup.Mode = v1alpha1.WorkflowModeDAG
+	nested.SubSteps = []v1alpha1.WorkflowStep{env, notGroup}
+	suspend := step("suspend", "suspend")
+	suspend.Properties = &runtime.RawExtension{Raw: []byte(`{"duration":"1x"}`)}
+	timeout := step("timeout", "apply")
+	timeout.Timeout = "invalid"
+	secretInputs := step("step1", "apply")
+	secretInputs.Inputs = v1alpha1.StepInputs{
+		{ParameterKey: "password", FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "password"}},
+		{From: "env", FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp"}},
+		{ParameterKey: "p"},
+	}
+	secretSuspend := step("step2", "suspend")
+	secretSuspend.Inputs = v1alpha1.StepInputs{{ParameterKey: "duration", FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "duration"}}}
+
+	testCases := map[string]struct {
+		spec     v1alpha1.WorkflowSpec
+		mode     *v1alpha1.WorkflowExecuteMode
+		expected []string
+	}{
+		"valid": {
+			spec: v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{
+					withOutputs(step("step1", "apply"), "out"),
+					withInputs(step("step2", "suspend"), "out.value", "env"),
+				},
+				Finally: []v1alpha1.WorkflowStep{withInputs(step("notify", "apply"), "out")},
+			},
+		},
+		"duplicate-names": {
+			spec: v1alpha1.WorkflowSpec{
+				Steps:   []v1alpha1.WorkflowStep{step("step1", "apply"), group},
+				Finally: []v1alpha1.WorkflowStep{step("sub1", "apply")},
+			},
+			expected: []string{"finally[0].name: Duplicate value: \"sub1\"", "dependency cycle: sub1 -> sub2 -> sub1"},
+		},
+		"reserved-names": {
+			spec: v1alpha1.WorkflowSpec{
+				Steps:     []v1alpha1.WorkflowStep{step("workflow", "apply")},
+				OnFailure: []v1alpha1.WorkflowStep{step("workflow", "apply")},
+			},
+			expected: []string{
+				"steps[0].name: Invalid value: \"workflow\"",
+				"onFailure[0].name: Invalid value: \"workflow\"",
+			},
+		},
+		"dependencies": {
+			mode: &v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeDAG},
+			spec: v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{step("step1", "apply", "not-found"), step("step2", "apply", "step2")},
+			},
+			expected: []string{"steps[0].dependsOn[0]: Not found: \"not-found\"", "dependency cycle: step2 -> step2"},
+		},
+		"nested-groups": {
+			spec: v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{step("prepare", "apply"), nested}},
+			expected: []string{
+				"steps[1].subSteps[0].mode: Unsupported value: \"Parallel\"",
+				"steps[1].subSteps[1].mode: Forbidden: only the step-group step can have the mode of sub steps",
+				"dependency cycle: stage -> env -> cluster2 -> stage",
+			},
+		},
+		"durations": {
+			spec:     v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{suspend, timeout}},
+			expected: []string{"steps[0].properties.duration", "steps[1].timeout: Invalid value: \"invalid\""},
+		},
+		"inputs": {
+			spec: v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{
+					withInputs(step("step1", "apply"), "out"),
+					withOutputs(step("step2", "apply"), "out"),
+				},
+			},
+			expected: []string{"steps[0].inputs[0].from: Invalid value: \"out\""},
+		},
+		"inputs-in-dag": {
+			mode: &v1alpha1.WorkflowExecuteMode{Steps: v1alpha1.WorkflowModeDAG},
+			spec: v1alpha1.WorkflowSpec{
+				Steps: []v1alpha1.WorkflowStep{
+					withInputs(step("step1", "apply"), "out"),
+					withOutputs(step("step2", "apply"), "out"),
+				},
+			},
+		},
+		"inputs-from-secret": {
+			spec: v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{secretInputs, secretSuspend}},
+			expected: []string{
+				"steps[0].inputs[1].from: Invalid value: \"env\": from and fromSecret are mutually exclusive",
+				"steps[0].inputs[1].parameterKey: Required value",
+				"steps[0].inputs[1].fromSecret.key: Required value",
+				"steps[0].inputs[2].from: Required value",
+				"steps[1].inputs[0].fromSecret: Invalid value: \"smtp\": the inputs from secrets are not supported by the suspend step",
+			},
+		},
+		"unknown-type": {
+			spec:     v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{step("step1", "not-exist"), step("step2", "")}},
+			expected: []string{"steps[0].type: Invalid value: \"not-exist\": unknown step type", "steps[1].type: Required value"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			validator := NewSpecValidator(&testLoader{}, "default").WithMode(tc.mode).WithInputs(map[string]bool{"env": true})
+			errs := validator.Validate(context.Background(), &tc.spec, nil)
+			r.Equal(len(tc.expected), len(errs), errs.ToAggregate())
+			for _, expected := range tc.expected {
+				r.Contains(errs.ToAggregate().Error(), expected)
+			}
+		})
+	}
+}
+
+func TestSpecValidatorSecretAccess(t *testing.T) {
+	r := require.New(t)
+	reviews := 0
+	cli := &test.MockClient{
+		MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+			review := obj.(*authorizationv1.SubjectAccessReview)
+			reviews++
+			attrs := review.Spec.ResourceAttributes
+			review.Status.Allowed = review.Spec.User == "alice" && attrs.Verb == "get" && attrs.Resource == "secrets" && attrs.Namespace == "vela-system"
+			return nil
+		},
+	}
+	step := v1alpha1.WorkflowStep{WorkflowStepBase: v1alpha1.WorkflowStepBase{Name: "step1", Type: "apply"}}
+	step.Inputs = v1alpha1.StepInputs{
+		{ParameterKey: "password", FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "password", Namespace: "vela-system"}},
+		{ParameterKey: "user", FromSecret: &v1alpha1.SecretKeySelector{Name: "smtp", Key: "user", Namespace: "vela-system"}},
+		{ParameterKey: "token", FromSecret: &v1alpha1.SecretKeySelector{Name: "token", Key: "token", Namespace: "default"}},
+	}
+	spec := &v1alpha1.WorkflowSpec{Steps: []v1alpha1.WorkflowStep{step}}
+
+	// the access to the secret in the other namespace is reviewed once, the secrets in the same namespace are not
+	errs := NewSpecValidator(&testLoader{}, "default").WithUser(cli, authenticationv1.UserInfo{Username: "alice"}).Validate(context.Background(), spec, nil)
+	r.Empty(errs)
+	r.Equal(1, reviews)
+
+	errs = NewSpecValidator(&testLoader{}, "default").WithUser(cli, authenticationv1.UserInfo{Username: "bob"}).Validate(context.Background(), spec, nil)
+	r.Equal(2, len(errs))
+	r.Contains(errs.ToAggregate().Error(), "steps[0].inputs[0].fromSecret.namespace: Forbidden: user bob can't get secret vela-system/smtp")
+
+	// the workflow runs of the cron workflow are created by the controller, the user of the cron workflow is reviewed
+	cli.MockGet = test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "apply"))
+	cron := &v1alpha1.CronWorkflow{ObjectMeta: metav1.ObjectMeta{Name: "cron", Namespace: "default"}}
+	cron.Spec.RunTemplate.Spec.WorkflowSpec = spec
+	errs = (&CronWorkflowValidatingHandler{Client: cli}).Validate(context.Background(), cron, authenticationv1.UserInfo{Username: "bob"})
+	r.Contains(errs.ToAggregate().Error(), "spec.runTemplate.spec.workflowSpec.steps[0].inputs[0].fromSecret.namespace: Forbidden")
+}
+
+func TestValidateMode(t *testing.T) {
+	r := require.New(t)
+	errs := validateMode(&v1alpha1.WorkflowExecuteMode{Steps: "Parallel", SubSteps: v1alpha1.WorkflowModeStep, MaxParallelism: -1}, field.NewPath("spec", "mode"))
+	r.Equal(2, len(errs))
+	r.Contains(errs.ToAggregate().Error(), "spec.mode.steps: Unsupported value: \"Parallel\"")
+	r.Contains(errs.ToAggregate().Error(), "spec.mode.maxParallelism: Invalid value: -1")
+}
+
+func TestWorkflowRunValidateUpdate(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	r.NoError(err)
+	handler := &WorkflowRunValidatingHandler{Decoder: decoder}
+
+	old := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		Spec:       v1alpha1.WorkflowRunSpec{WorkflowRef: "wf"},
+		Status:     v1alpha1.WorkflowRunStatus{StartTime: metav1.Now()},
+	}
+	request := func(run *v1alpha1.WorkflowRun) admission.Request {
+		raw, err := json.Marshal(run)
+		r.NoError(err)
+		oldRaw, err := json.Marshal(old)
+		r.NoError(err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		}}
+	}
+
+	run := old.DeepCopy()
+	run.Spec.Suspend = true
+	run.Spec.ResumeSteps = []v1alpha1.StepResume{{Name: "approve"}}
+	resp := handler.Handle(context.Background(), request(run))
+	r.True(resp.Allowed)
+
+	run.Spec.WorkflowRef = "another"
+	resp = handler.Handle(context.Background(), request(run))
+	r.False(resp.Allowed)
+	r.Contains(string(resp.Result.Reason), "the spec of a started workflow run is immutable")
+
+	// the spec of the run which is not started can be changed
+	old.Status.StartTime = metav1.Time{}
+	resp = handler.Handle(context.Background(), request(run))
+	r.True(resp.Allowed)
+}
+
+func TestWorkflowRunMutate(t *testing.T) {
+	r := require.New(t)
+	scheme := runtime.NewScheme()
+	r.NoError(v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	r.NoError(err)
+	handler := &WorkflowRunMutatingHandler{Decoder: decoder}
+
+	old := &v1alpha1.WorkflowRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "run",
+			Namespace:   "default",
+			Annotations: map[string]string{types.AnnotationWorkflowRunControlUser: "alice"},
+		},
+		Spec: v1alpha1.WorkflowRunSpec{WorkflowRef: "wf"},
+	}
+	request := func(run *v1alpha1.WorkflowRun) admission.Request {
+		raw, err := json.Marshal(run)
+		r.NoError(err)
+		oldRaw, err := json.Marshal(old)
+		r.NoError(err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+			UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+		}}
+	}
+
+	// the user who changes the controls is stamped
+	run := old.DeepCopy()
+	run.Spec.Terminate = true
+	resp := handler.Handle(context.Background(), request(run))
+	r.True(resp.Allowed)
+	r.Equal(1, len(resp.Patches))
+	r.Equal("bob", resp.Patches[0].Value)
+
+	// the annotation can't be forged without changing the controls
+	run = old.DeepCopy()
+	run.Annotations[types.AnnotationWorkflowRunControlUser] = "admin"
+	resp = handler.Handle(context.Background(), request(run))
+	r.True(resp.Allowed)
+	r.Equal(1, len(resp.Patches))
+	r.Equal("alice", resp.Patches[0].Value)
+
+	resp = handler.Handle(context.Background(), request(old.DeepCopy()))
+	r.True(resp.Allowed)
+	r.Empty(resp.Patches)
+}
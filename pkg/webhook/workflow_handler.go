@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/tasks/template"
+)
+
+// WorkflowValidatingHandler validates the Workflow
+type WorkflowValidatingHandler struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+var _ admission.Handler = &WorkflowValidatingHandler{}
+
+// Handle validates the steps of the workflow on creation and update
+func (h *WorkflowValidatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.ValidationResponse(true, "")
+	}
+	wf := &v1alpha1.Workflow{}
+	if err := h.Decoder.Decode(req, wf); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if errs := h.Validate(ctx, wf, req.UserInfo); len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.ValidationResponse(true, "")
+}
+
+// Validate validates the steps of the workflow, the inputs are not validated since they may come from the context
+// of the workflow runs, but the user must be able to get the secrets in the other namespaces which are referenced.
+func (h *WorkflowValidatingHandler) Validate(ctx context.Context, wf *v1alpha1.Workflow, user authenticationv1.UserInfo) field.ErrorList {
+	validator := NewSpecValidator(template.NewWorkflowStepTemplateLoader(h.Client), wf.Namespace).WithUser(h.Client, user)
+	return validator.Validate(ctx, &wf.WorkflowSpec, nil)
+}
+
+var _ inject.Client = &WorkflowValidatingHandler{}
+
+// InjectClient injects the client into the WorkflowValidatingHandler
+func (h *WorkflowValidatingHandler) InjectClient(c client.Client) error {
+	h.Client = c
+	return nil
+}
+
+var _ admission.DecoderInjector = &WorkflowValidatingHandler{}
+
+// InjectDecoder injects the decoder into the WorkflowValidatingHandler
+func (h *WorkflowValidatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.Decoder = d
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/tasks/template"
+)
+
+// WorkflowRunValidatingHandler validates the WorkflowRun
+type WorkflowRunValidatingHandler struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+var _ admission.Handler = &WorkflowRunValidatingHandler{}
+
+// Handle validates the workflow run on creation and the update of its spec
+func (h *WorkflowRunValidatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	run := &v1alpha1.WorkflowRun{}
+	if err := h.Decoder.Decode(req, run); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var errs field.ErrorList
+	switch req.Operation {
+	case admissionv1.Create:
+		errs = h.ValidateCreate(ctx, run, req.UserInfo)
+	case admissionv1.Update:
+		old := &v1alpha1.WorkflowRun{}
+		if err := h.Decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = h.ValidateUpdate(ctx, run, old, req.UserInfo)
+	default:
+	}
+	if len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.ValidationResponse(true, "")
+}
+
+// ValidateCreate validates the spec of the workflow run, the user must be able to get the secrets in the other
+// namespaces which are referenced by the inputs.
+func (h *WorkflowRunValidatingHandler) ValidateCreate(ctx context.Context, run *v1alpha1.WorkflowRun, user authenticationv1.UserInfo) field.ErrorList {
+	return validateRunSpec(ctx, h.Client, &run.Spec, run.Namespace, user, field.NewPath("spec"))
+}
+
+func validateRunSpec(ctx context.Context, cli client.Client, spec *v1alpha1.WorkflowRunSpec, namespace string, user authenticationv1.UserInfo, path *field.Path) field.ErrorList {
+	errs := validateMode(spec.Mode, path.Child("mode"))
+	if spec.Timeout != "" {
+		if _, err := time.ParseDuration(spec.Timeout); err != nil {
+			errs = append(errs, field.Invalid(path.Child("timeout"), spec.Timeout, err.Error()))
+		}
+	}
+	if spec.WorkflowSpec == nil {
+		if spec.WorkflowRef == "" {
+			errs = append(errs, field.Required(path.Child("workflowRef"), "either workflowSpec or workflowRef is required"))
+		}
+		return errs
+	}
+	validator := NewSpecValidator(template.NewWorkflowStepTemplateLoader(cli), namespace).WithMode(spec.Mode).WithUser(cli, user)
+	vars, err := getContextVars(spec.Context, path.Child("context"))
+	if err != nil {
+		return append(errs, err)
+	}
+	validator.WithInputs(vars)
+	return append(errs, validator.Validate(ctx, spec.WorkflowSpec, path.Child("workflowSpec"))...)
+}
+
+// ValidateUpdate validates the update of the workflow run, the spec of a started run is immutable except the
+// controls and the ttl.
+func (h *WorkflowRunValidatingHandler) ValidateUpdate(ctx context.Context, run, old *v1alpha1.WorkflowRun, user authenticationv1.UserInfo) field.ErrorList {
+	if equality.Semantic.DeepEqual(run.Spec, old.Spec) {
+		return nil
+	}
+	if old.Status.StartTime.IsZero() {
+		return h.ValidateCreate(ctx, run, user)
+	}
+	spec := old.Spec.DeepCopy()
+	spec.Suspend = run.Spec.Suspend
+	spec.Terminate = run.Spec.Terminate
+	spec.ResumeSteps = run.Spec.ResumeSteps
+	spec.TTLSecondsAfterFinished = run.Spec.TTLSecondsAfterFinished
+	if !equality.Semantic.DeepEqual(run.Spec, *spec) {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec"),
+			"the spec of a started workflow run is immutable except suspend, terminate, resumeSteps and ttlSecondsAfterFinished")}
+	}
+	return nil
+}
+
+var _ inject.Client = &WorkflowRunValidatingHandler{}
+
+// InjectClient injects the client into the WorkflowRunValidatingHandler
+func (h *WorkflowRunValidatingHandler) InjectClient(c client.Client) error {
+	h.Client = c
+	return nil
+}
+
+var _ admission.DecoderInjector = &WorkflowRunValidatingHandler{}
+
+// InjectDecoder injects the decoder into the WorkflowRunValidatingHandler
+func (h *WorkflowRunValidatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.Decoder = d
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kubevela/workflow/api/v1alpha1"
+	"github.com/kubevela/workflow/pkg/types"
+)
+
+// WorkflowRunMutatingHandler records the user who changes the controls in the spec of the WorkflowRun
+type WorkflowRunMutatingHandler struct {
+	Decoder *admission.Decoder
+}
+
+var _ admission.Handler = &WorkflowRunMutatingHandler{}
+
+// Handle stamps the requesting user into the control user annotation if the suspend, terminate or resumeSteps in
+// the spec is changed, the annotation set by the others is reverted so that it can't be forged.
+func (h *WorkflowRunMutatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	run := &v1alpha1.WorkflowRun{}
+	if err := h.Decoder.Decode(req, run); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	old := &v1alpha1.WorkflowRun{}
+	if req.Operation == admissionv1.Update {
+		if err := h.Decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	user, ok := old.Annotations[types.AnnotationWorkflowRunControlUser]
+	if controlsChanged(run, old) {
+		user, ok = req.UserInfo.Username, true
+	}
+	if current, set := run.Annotations[types.AnnotationWorkflowRunControlUser]; current == user && set == ok {
+		return admission.Allowed("")
+	}
+	if ok {
+		if run.Annotations == nil {
+			run.Annotations = map[string]string{}
+		}
+		run.Annotations[types.AnnotationWorkflowRunControlUser] = user
+	} else {
+		delete(run.Annotations, types.AnnotationWorkflowRunControlUser)
+	}
+	raw, err := json.Marshal(run)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+func controlsChanged(run, old *v1alpha1.WorkflowRun) bool {
+	return run.Spec.Suspend != old.Spec.Suspend || run.Spec.Terminate != old.Spec.Terminate ||
+		!equality.Semantic.DeepEqual(run.Spec.ResumeSteps, old.Spec.ResumeSteps)
+}
+
+var _ admission.DecoderInjector = &WorkflowRunMutatingHandler{}
+
+// InjectDecoder injects the decoder into the WorkflowRunMutatingHandler
+func (h *WorkflowRunMutatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.Decoder = d
+	return nil
+}